@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"compiler/msg"
+	"compiler/parser"
+	"sort"
+)
+
+// Backend 是一个代码生成目标
+type Backend interface {
+	// Name 返回 --target 使用的名字
+	Name() string
+	// Extension 返回输出文件扩展名（含点号）
+	Extension() string
+	// Features 返回该后端支持的语言特性
+	Features() Feature
+	Generate(ast *parser.AST) ([]byte, error)
+}
+
+var registry = make(map[string]Backend)
+
+// Register 注册一个后端，通常在后端包的 init 中调用
+func Register(b Backend) {
+	if _, dup := registry[b.Name()]; dup {
+		panic("backend: 重复注册后端 " + b.Name())
+	}
+	registry[b.Name()] = b
+}
+
+// Lookup 按名字查找已注册的后端
+func Lookup(name string) (Backend, bool) {
+	b, ok := registry[name]
+	return b, ok
+}
+
+// Names 返回所有已注册后端的名字（按字母排序）
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check 检查程序用到的特性是否都被后端支持
+func Check(b Backend, ast *parser.AST) error {
+	missing := Used(ast) &^ b.Features()
+	if missing == 0 {
+		return nil
+	}
+	return msg.Errorf(msg.GEN_UNSUPPORTED_FEATURES, b.Name(), missing)
+}
+
+// Generate 在能力检查通过后调用后端生成代码
+func Generate(b Backend, ast *parser.AST) ([]byte, error) {
+	if err := Check(b, ast); err != nil {
+		return nil, err
+	}
+	return b.Generate(ast)
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"compiler/msg"
+	"compiler/parser"
+	"strconv"
+	"strings"
+)
+
+// Feature 是语言特性的位集合
+type Feature uint64
+
+const (
+	FEATURE_PRINT Feature = 1 << iota
+	FEATURE_INPUT
+	FEATURE_IF
+	FEATURE_WHILE
+	FEATURE_DIVISION
+	FEATURE_COMPARISON
+	FEATURE_BOOLEAN
+	FEATURE_FOR
+	FEATURE_BREAK // break 和 continue
+	FEATURE_DO_WHILE
+	FEATURE_SWITCH
+	FEATURE_BITWISE // & | ^ ~ << >>
+	FEATURE_CONST
+	FEATURE_CHAR // char 类型和字符常量
+	FEATURE_LONG // long 类型和超出 16 位的数字
+)
+
+// FEATURE_ALL 包含目前语言的全部特性
+const FEATURE_ALL = FEATURE_PRINT | FEATURE_INPUT | FEATURE_IF | FEATURE_WHILE |
+	FEATURE_DIVISION | FEATURE_COMPARISON | FEATURE_BOOLEAN | FEATURE_FOR | FEATURE_BREAK |
+	FEATURE_DO_WHILE | FEATURE_SWITCH | FEATURE_BITWISE | FEATURE_CONST | FEATURE_CHAR | FEATURE_LONG
+
+var featureToString = map[Feature]msg.Code{
+	FEATURE_PRINT:      msg.GEN_FEATURE_PRINT,
+	FEATURE_INPUT:      msg.GEN_FEATURE_INPUT,
+	FEATURE_IF:         msg.GEN_FEATURE_IF,
+	FEATURE_WHILE:      msg.GEN_FEATURE_WHILE,
+	FEATURE_DIVISION:   msg.GEN_FEATURE_DIVISION,
+	FEATURE_COMPARISON: msg.GEN_FEATURE_COMPARISON,
+	FEATURE_BOOLEAN:    msg.GEN_FEATURE_BOOLEAN,
+	FEATURE_FOR:        msg.GEN_FEATURE_FOR,
+	FEATURE_BREAK:      msg.GEN_FEATURE_BREAK,
+	FEATURE_DO_WHILE:   msg.GEN_FEATURE_DO_WHILE,
+	FEATURE_SWITCH:     msg.GEN_FEATURE_SWITCH,
+	FEATURE_BITWISE:    msg.GEN_FEATURE_BITWISE,
+	FEATURE_CONST:      msg.GEN_FEATURE_CONST,
+	FEATURE_CHAR:       msg.GEN_FEATURE_CHAR,
+	FEATURE_LONG:       msg.GEN_FEATURE_LONG,
+}
+
+// Names 按语言 lang 返回集合中每个特性的名字
+func (f Feature) Names(lang msg.Lang) []string {
+	var names []string
+	for bit := Feature(1); bit != 0 && bit <= f; bit <<= 1 {
+		if f&bit != 0 {
+			names = append(names, msg.Format(lang, featureToString[bit]))
+		}
+	}
+	return names
+}
+
+// Localize 按语言 lang 列出集合中的特性，用作消息参数
+func (f Feature) Localize(lang msg.Lang) string {
+	return strings.Join(f.Names(lang), ", ")
+}
+
+// Used 统计程序用到的全部特性
+func Used(ast *parser.AST) Feature {
+	var f Feature
+	for _, stmt := range ast.Statements {
+		f |= usedByStatement(stmt)
+	}
+	return f
+}
+
+func usedByStatement(stmt parser.Statement) Feature {
+	var f Feature
+	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		f |= FEATURE_CONST | usedByExpr(s.Value)
+	case *parser.VarDeclaration:
+		switch s.Type {
+		case parser.TYPE_CHAR:
+			f |= FEATURE_CHAR
+		case parser.TYPE_LONG:
+			f |= FEATURE_LONG
+		}
+		f |= usedByExpr(s.Desugar().Value)
+	case *parser.Assignment:
+		f |= usedByExpr(s.Value)
+	case *parser.CompoundAssignment:
+		f |= usedByExpr(s.Desugar().Value)
+	case *parser.PrintStatement:
+		f |= FEATURE_PRINT | usedByExpr(s.Expr)
+	case *parser.InputStatement:
+		f |= FEATURE_INPUT
+	case *parser.IfStatement:
+		f |= FEATURE_IF | usedByExpr(s.Condition)
+		for _, stmt := range s.Then {
+			f |= usedByStatement(stmt)
+		}
+		for _, stmt := range s.Else {
+			f |= usedByStatement(stmt)
+		}
+	case *parser.WhileStatement:
+		f |= FEATURE_WHILE | usedByExpr(s.Condition)
+		for _, stmt := range s.Body {
+			f |= usedByStatement(stmt)
+		}
+	case *parser.DoWhileStatement:
+		f |= FEATURE_DO_WHILE | usedByExpr(s.Condition)
+		for _, stmt := range s.Body {
+			f |= usedByStatement(stmt)
+		}
+	case *parser.SwitchStatement:
+		f |= FEATURE_SWITCH | usedByExpr(s.Value)
+		for _, c := range s.Cases {
+			for _, stmt := range c.Body {
+				f |= usedByStatement(stmt)
+			}
+		}
+	case *parser.ForStatement:
+		f |= FEATURE_FOR
+		if s.Init != nil {
+			f |= usedByStatement(s.Init)
+		}
+		if s.Condition != nil {
+			f |= usedByExpr(s.Condition)
+		}
+		if s.Step != nil {
+			f |= usedByStatement(s.Step)
+		}
+		for _, stmt := range s.Body {
+			f |= usedByStatement(stmt)
+		}
+	case *parser.BreakStatement, *parser.ContinueStatement:
+		f |= FEATURE_BREAK
+	}
+	return f
+}
+
+func usedByExpr(expr parser.Expr) Feature {
+	var f Feature
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		if _, err := strconv.ParseInt(e.Value, 10, 16); err != nil {
+			f |= FEATURE_LONG
+		}
+	case *parser.BooleanExpr:
+		f |= FEATURE_BOOLEAN
+	case *parser.CharExpr:
+		f |= FEATURE_CHAR
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case "/", "%":
+			f |= FEATURE_DIVISION
+		case "&", "|", "^", "<<", ">>":
+			f |= FEATURE_BITWISE
+		}
+		f |= usedByExpr(e.Left) | usedByExpr(e.Right)
+	case *parser.ComparisonExpr:
+		f |= FEATURE_COMPARISON | usedByExpr(e.Left) | usedByExpr(e.Right)
+	case *parser.UnaryExpr:
+		f |= FEATURE_BITWISE | usedByExpr(e.Operand)
+	}
+	return f
+}
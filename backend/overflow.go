@@ -0,0 +1,48 @@
+package backend
+
+import "compiler/msg"
+
+// Overflow 是整数运算溢出时的处理方式
+type Overflow int
+
+const (
+	OVERFLOW_WRAP Overflow = iota // 按补码回绕，不做检查
+	OVERFLOW_TRAP                 // 运行时检查，溢出或输入超出范围时报告出错的源代码行并退出
+)
+
+var overflowNames = map[Overflow]string{
+	OVERFLOW_WRAP: "wrap",
+	OVERFLOW_TRAP: "trap",
+}
+
+func (o Overflow) String() string {
+	return overflowNames[o]
+}
+
+// LookupOverflow 返回 --overflow 选项值对应的处理方式
+func LookupOverflow(name string) (Overflow, bool) {
+	for o, n := range overflowNames {
+		if n == name {
+			return o, true
+		}
+	}
+	return OVERFLOW_WRAP, false
+}
+
+// OverflowTrapper 是能在运行时检查整数溢出的后端
+type OverflowTrapper interface {
+	// TrapOverflow 返回生成溢出检查代码的同名后端
+	TrapOverflow() Backend
+}
+
+// WithOverflow 返回按 o 处理溢出的后端，后端不支持时返回错误
+func WithOverflow(b Backend, o Overflow) (Backend, error) {
+	if o == OVERFLOW_WRAP {
+		return b, nil
+	}
+	t, ok := b.(OverflowTrapper)
+	if !ok {
+		return nil, msg.Errorf(msg.GEN_NO_OVERFLOW_TRAP, b.Name())
+	}
+	return t.TrapOverflow(), nil
+}
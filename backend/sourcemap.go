@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"compiler/msg"
+	"compiler/parser"
+	"encoding/json"
+)
+
+// Mapping 把生成代码中的一段行（从 1 开始，含两端）对应到一条源语句。
+// 复合语句的范围包含其内部语句的范围，查找时取最内层的一条。
+type Mapping struct {
+	AsmStart int `json:"asmStart"`
+	AsmEnd   int `json:"asmEnd"`
+	Line     int `json:"line"`
+	Column   int `json:"column"`
+}
+
+// SourceMap 是生成代码到源代码的映射，按 JSON 输出
+type SourceMap struct {
+	Version  int       `json:"version"`
+	Source   string    `json:"source,omitempty"` // 源文件名
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Annotator 是能在输出中插入源代码注释并生成源码映射的后端
+type Annotator interface {
+	// GenerateAnnotated 在每条语句的代码前插入该语句所在的源代码行作为注释。
+	// source 是完整的源代码。
+	GenerateAnnotated(ast *parser.AST, source string) ([]byte, []Mapping, error)
+}
+
+// GenerateAnnotated 在能力检查通过后生成带源代码注释的代码和源码映射
+func GenerateAnnotated(b Backend, ast *parser.AST, source string) ([]byte, []Mapping, error) {
+	a, ok := b.(Annotator)
+	if !ok {
+		return nil, nil, msg.Errorf(msg.GEN_NO_ANNOTATE, b.Name())
+	}
+	if err := Check(b, ast); err != nil {
+		return nil, nil, err
+	}
+	return a.GenerateAnnotated(ast, source)
+}
+
+// MarshalSourceMap 把源码映射编码为 JSON，sourceFile 为源文件名，可以为空
+func MarshalSourceMap(sourceFile string, mappings []Mapping) ([]byte, error) {
+	if mappings == nil {
+		mappings = []Mapping{}
+	}
+	return json.MarshalIndent(SourceMap{Version: 1, Source: sourceFile, Mappings: mappings}, "", "  ")
+}
@@ -0,0 +1,28 @@
+package bytecode
+
+import (
+	"compiler/backend"
+	"compiler/parser"
+)
+
+// bytecodeBackend 输出可由 `compiler vm` 执行的 .bc 文件
+type bytecodeBackend struct{}
+
+func init() {
+	backend.Register(bytecodeBackend{})
+}
+
+func (bytecodeBackend) Name() string      { return "bytecode" }
+func (bytecodeBackend) Extension() string { return ".bc" }
+func (bytecodeBackend) Features() backend.Feature {
+	// 虚拟机的值都是 16 位整数，不支持 long
+	return backend.FEATURE_ALL &^ backend.FEATURE_LONG
+}
+
+func (bytecodeBackend) Generate(ast *parser.AST) ([]byte, error) {
+	prog, err := Compile(ast)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(prog)
+}
@@ -0,0 +1,415 @@
+package bytecode
+
+import (
+	"compiler/backend"
+	"compiler/msg"
+	"compiler/parser"
+	"strconv"
+)
+
+// Program 是编译后的字节码程序
+type Program struct {
+	Constants []int16  // 常量池
+	Globals   []string // 全局变量名，下标即变量槽位
+	Code      []byte
+}
+
+type Compiler struct {
+	prog     *Program
+	constMap map[int16]int
+	slotMap  map[string]int
+	consts   map[string]int16       // 常量的值，使用常量的地方直接压入常量池中的值
+	types    map[string]parser.Type // 变量和常量的类型，决定 print 输出数字还是字符
+	loops    []*loop                // 正在编译的循环和 switch，最内层在最后
+}
+
+// loop 记录循环（或 switch）中 break 和 continue 生成的跳转，循环编译完后回填
+type loop struct {
+	breaks    []int
+	continues []int
+}
+
+func NewCompiler() *Compiler {
+	return &Compiler{
+		prog:     &Program{},
+		constMap: make(map[int16]int),
+		slotMap:  make(map[string]int),
+		consts:   make(map[string]int16),
+		types:    make(map[string]parser.Type),
+	}
+}
+
+// DefineVar 声明在程序之外已经定义的 t 类型的变量（如 REPL 中之前输入的变量）
+func (c *Compiler) DefineVar(name string, t parser.Type) {
+	c.types[name] = t
+}
+
+// DefineConst 声明在程序之外已经声明的常量（如 REPL 中之前输入的常量）
+func (c *Compiler) DefineConst(name string, t parser.Type, value int16) {
+	c.consts[name] = value
+	c.types[name] = t
+}
+
+// Types 返回编译过的程序中所有变量和常量的类型，包括之前声明的
+func (c *Compiler) Types() map[string]parser.Type {
+	return c.types
+}
+
+// Compile 将 AST 编译为字节码程序
+func Compile(ast *parser.AST) (*Program, error) {
+	c := NewCompiler()
+	return c.Compile(ast)
+}
+
+func (c *Compiler) Compile(ast *parser.AST) (*Program, error) {
+	if err := backend.Check(bytecodeBackend{}, ast); err != nil {
+		return nil, err
+	}
+	checker := parser.NewChecker(nil)
+	for name, t := range c.types {
+		if v, ok := c.consts[name]; ok {
+			checker.DefineConst(name, t, v)
+		} else {
+			checker.DefineVar(name, t)
+		}
+	}
+	c.types = checker.Infer(ast)
+	for _, stmt := range ast.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	c.emit(OP_HALT)
+	if len(c.prog.Code) > 0xFFFF {
+		return nil, msg.Errorf(msg.BC_CODE_TOO_LONG)
+	}
+	return c.prog, nil
+}
+
+func (c *Compiler) compileStatement(stmt parser.Statement) error {
+	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		v, err := parser.EvalConst(s.Value, c.consts)
+		if err != nil {
+			return err
+		}
+		c.consts[s.Name] = v
+	case *parser.VarDeclaration:
+		return c.compileStatement(s.Desugar())
+	case *parser.Assignment:
+		if err := c.compileExpr(s.Value); err != nil {
+			return err
+		}
+		c.emitOperand(OP_STORE, c.slot(s.Ident))
+	case *parser.CompoundAssignment:
+		return c.compileStatement(s.Desugar())
+	case *parser.IncDecStatement:
+		return c.compileStatement(s.Desugar())
+	case *parser.PrintStatement:
+		if err := c.compileExpr(s.Expr); err != nil {
+			return err
+		}
+		if parser.TypeOf(s.Expr, c.types) == parser.TYPE_CHAR {
+			c.emit(OP_PRINT_CHAR)
+		} else {
+			c.emit(OP_PRINT)
+		}
+	case *parser.InputStatement:
+		c.emit(OP_INPUT)
+		c.emitOperand(OP_STORE, c.slot(s.Ident))
+	case *parser.IfStatement:
+		if err := c.compileExpr(s.Condition); err != nil {
+			return err
+		}
+		elseJump := c.emitJump(OP_JMP_IF_FALSE)
+		if err := c.compileBlock(s.Then); err != nil {
+			return err
+		}
+		endJump := c.emitJump(OP_JMP)
+		c.patchJump(elseJump)
+		if err := c.compileBlock(s.Else); err != nil {
+			return err
+		}
+		c.patchJump(endJump)
+	case *parser.WhileStatement:
+		start := len(c.prog.Code)
+		if err := c.compileExpr(s.Condition); err != nil {
+			return err
+		}
+		endJump := c.emitJump(OP_JMP_IF_FALSE)
+		l, err := c.compileLoopBody(s.Body)
+		if err != nil {
+			return err
+		}
+		c.patchJumps(l.continues, start)
+		c.emitOperand(OP_JMP, start)
+		c.patchJump(endJump)
+		c.patchJumps(l.breaks, len(c.prog.Code))
+	case *parser.DoWhileStatement:
+		start := len(c.prog.Code)
+		l, err := c.compileLoopBody(s.Body)
+		if err != nil {
+			return err
+		}
+		c.patchJumps(l.continues, len(c.prog.Code))
+		if err := c.compileExpr(s.Condition); err != nil {
+			return err
+		}
+		endJump := c.emitJump(OP_JMP_IF_FALSE)
+		c.emitOperand(OP_JMP, start)
+		c.patchJump(endJump)
+		c.patchJumps(l.breaks, len(c.prog.Code))
+	case *parser.ForStatement:
+		if s.Init != nil {
+			if err := c.compileStatement(s.Init); err != nil {
+				return err
+			}
+		}
+		start := len(c.prog.Code)
+		endJump := -1
+		if s.Condition != nil {
+			if err := c.compileExpr(s.Condition); err != nil {
+				return err
+			}
+			endJump = c.emitJump(OP_JMP_IF_FALSE)
+		}
+		l, err := c.compileLoopBody(s.Body)
+		if err != nil {
+			return err
+		}
+		c.patchJumps(l.continues, len(c.prog.Code))
+		if s.Step != nil {
+			if err := c.compileStatement(s.Step); err != nil {
+				return err
+			}
+		}
+		c.emitOperand(OP_JMP, start)
+		if endJump >= 0 {
+			c.patchJump(endJump)
+		}
+		c.patchJumps(l.breaks, len(c.prog.Code))
+	case *parser.SwitchStatement:
+		return c.compileSwitch(s)
+	case *parser.BreakStatement:
+		l := c.loops[len(c.loops)-1]
+		l.breaks = append(l.breaks, c.emitJump(OP_JMP))
+	case *parser.ContinueStatement:
+		l := c.loops[len(c.loops)-1]
+		l.continues = append(l.continues, c.emitJump(OP_JMP))
+	default:
+		return msg.Errorf(msg.BC_UNSUPPORTED_STATEMENT, stmt)
+	}
+	return nil
+}
+
+func (c *Compiler) compileBlock(stmts []parser.Statement) error {
+	for _, stmt := range stmts {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileLoopBody 编译循环体，返回其中 break 和 continue 待回填的跳转
+func (c *Compiler) compileLoopBody(body []parser.Statement) (*loop, error) {
+	l := &loop{}
+	c.loops = append(c.loops, l)
+	defer func() { c.loops = c.loops[:len(c.loops)-1] }()
+	if err := c.compileBlock(body); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// compileSwitch 用比较链编译 switch：要比较的值留在栈顶，
+// 依次与各个 case 比较，跳到分支后先弹出它
+func (c *Compiler) compileSwitch(s *parser.SwitchStatement) error {
+	if err := c.compileExpr(s.Value); err != nil {
+		return err
+	}
+	matches := make([][]int, len(s.Cases))
+	for i, clause := range s.Cases {
+		for _, v := range clause.Values {
+			c.emit(OP_DUP)
+			if err := c.compileExpr(v); err != nil {
+				return err
+			}
+			c.emit(OP_NE)
+			matches[i] = append(matches[i], c.emitJump(OP_JMP_IF_FALSE))
+		}
+	}
+	c.emit(OP_POP)
+	defaultJump := c.emitJump(OP_JMP)
+	hasDefault := false
+
+	l := &loop{}
+	c.loops = append(c.loops, l)
+	defer func() { c.loops = c.loops[:len(c.loops)-1] }()
+	var endJumps []int
+	for i, clause := range s.Cases {
+		if len(clause.Values) == 0 {
+			c.patchJump(defaultJump)
+			hasDefault = true
+		} else {
+			c.patchJumps(matches[i], len(c.prog.Code))
+			c.emit(OP_POP)
+		}
+		if err := c.compileBlock(clause.Body); err != nil {
+			return err
+		}
+		if i < len(s.Cases)-1 {
+			endJumps = append(endJumps, c.emitJump(OP_JMP))
+		}
+	}
+	if !hasDefault {
+		c.patchJump(defaultJump)
+	}
+	c.patchJumps(endJumps, len(c.prog.Code))
+	c.patchJumps(l.breaks, len(c.prog.Code))
+	// continue 作用于外层循环
+	if len(l.continues) > 0 {
+		outer := c.loops[len(c.loops)-2]
+		outer.continues = append(outer.continues, l.continues...)
+	}
+	return nil
+}
+
+func (c *Compiler) compileExpr(expr parser.Expr) error {
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		n, err := strconv.ParseInt(e.Value, 10, 16)
+		if err != nil {
+			return msg.Errorf(msg.BC_NUMBER_RANGE, e.Value)
+		}
+		c.emitOperand(OP_PUSH_CONST, c.constant(int16(n)))
+	case *parser.BooleanExpr:
+		if e.Value {
+			c.emitOperand(OP_PUSH_CONST, c.constant(1))
+		} else {
+			c.emitOperand(OP_PUSH_CONST, c.constant(0))
+		}
+	case *parser.CharExpr:
+		c.emitOperand(OP_PUSH_CONST, c.constant(int16(e.Value)))
+	case *parser.IdentExpr:
+		if v, ok := c.consts[e.Name]; ok {
+			c.emitOperand(OP_PUSH_CONST, c.constant(v))
+			break
+		}
+		c.emitOperand(OP_LOAD, c.slot(e.Name))
+	case *parser.BinaryExpr:
+		if err := c.compileExpr(e.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpr(e.Right); err != nil {
+			return err
+		}
+		switch e.Op {
+		case "+":
+			c.emit(OP_ADD)
+		case "-":
+			c.emit(OP_SUB)
+		case "*":
+			c.emit(OP_MUL)
+		case "/":
+			c.emit(OP_DIV)
+		case "%":
+			c.emit(OP_MOD)
+		case "&":
+			c.emit(OP_AND)
+		case "|":
+			c.emit(OP_OR)
+		case "^":
+			c.emit(OP_XOR)
+		case "<<":
+			c.emit(OP_SHL)
+		case ">>":
+			c.emit(OP_SHR)
+		default:
+			return msg.Errorf(msg.BC_UNSUPPORTED_OPERATOR, e.Op)
+		}
+	case *parser.ComparisonExpr:
+		if err := c.compileExpr(e.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpr(e.Right); err != nil {
+			return err
+		}
+		switch e.Op {
+		case "==":
+			c.emit(OP_EQ)
+		case "!=":
+			c.emit(OP_NE)
+		case "<":
+			c.emit(OP_LT)
+		case ">":
+			c.emit(OP_GT)
+		case "<=":
+			c.emit(OP_LE)
+		case ">=":
+			c.emit(OP_GE)
+		default:
+			return msg.Errorf(msg.BC_UNSUPPORTED_COMPARISON, e.Op)
+		}
+	case *parser.UnaryExpr:
+		if err := c.compileExpr(e.Operand); err != nil {
+			return err
+		}
+		if e.Op != "~" {
+			return msg.Errorf(msg.BC_UNSUPPORTED_OPERATOR, e.Op)
+		}
+		c.emit(OP_NOT)
+	default:
+		return msg.Errorf(msg.BC_UNSUPPORTED_EXPR, expr)
+	}
+	return nil
+}
+
+func (c *Compiler) emit(op Opcode) {
+	c.prog.Code = append(c.prog.Code, byte(op))
+}
+
+func (c *Compiler) emitOperand(op Opcode, operand int) {
+	c.prog.Code = append(c.prog.Code, byte(op), byte(operand), byte(operand>>8))
+}
+
+// emitJump 生成一条目标地址待回填的跳转指令，返回操作数所在位置
+func (c *Compiler) emitJump(op Opcode) int {
+	c.emitOperand(op, 0xFFFF)
+	return len(c.prog.Code) - 2
+}
+
+// patchJump 将跳转目标回填为当前代码末尾
+func (c *Compiler) patchJump(pos int) {
+	target := len(c.prog.Code)
+	c.prog.Code[pos] = byte(target)
+	c.prog.Code[pos+1] = byte(target >> 8)
+}
+
+// patchJumps 将 positions 处的跳转目标回填为 target
+func (c *Compiler) patchJumps(positions []int, target int) {
+	for _, pos := range positions {
+		c.prog.Code[pos] = byte(target)
+		c.prog.Code[pos+1] = byte(target >> 8)
+	}
+}
+
+func (c *Compiler) constant(v int16) int {
+	if idx, ok := c.constMap[v]; ok {
+		return idx
+	}
+	idx := len(c.prog.Constants)
+	c.prog.Constants = append(c.prog.Constants, v)
+	c.constMap[v] = idx
+	return idx
+}
+
+func (c *Compiler) slot(name string) int {
+	if idx, ok := c.slotMap[name]; ok {
+		return idx
+	}
+	idx := len(c.prog.Globals)
+	c.prog.Globals = append(c.prog.Globals, name)
+	c.slotMap[name] = idx
+	return idx
+}
@@ -0,0 +1,55 @@
+package bytecode
+
+import (
+	"compiler/msg"
+	"fmt"
+	"strings"
+)
+
+// Disassemble 将程序反汇编为可读文本，注释使用 msg 包的默认语言
+func Disassemble(prog *Program) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "; %s\n", msg.Get(msg.BC_DIS_CONSTANTS))
+	for i, v := range prog.Constants {
+		fmt.Fprintf(&sb, ";   #%d = %d\n", i, v)
+	}
+	fmt.Fprintf(&sb, "; %s\n", msg.Get(msg.BC_DIS_GLOBALS))
+	for i, name := range prog.Globals {
+		fmt.Fprintf(&sb, ";   $%d = %s\n", i, name)
+	}
+	sb.WriteString("\n")
+
+	for pc := 0; pc < len(prog.Code); {
+		op := Opcode(prog.Code[pc])
+		if pc+1+op.OperandWidth() > len(prog.Code) {
+			fmt.Fprintf(&sb, "%04x  %-12s ; %s\n", pc, op, msg.Get(msg.RT_TRUNCATED))
+			break
+		}
+		if op.OperandWidth() == 0 {
+			fmt.Fprintf(&sb, "%04x  %s\n", pc, op)
+			pc++
+			continue
+		}
+		operand := int(prog.Code[pc+1]) | int(prog.Code[pc+2])<<8
+		fmt.Fprintf(&sb, "%04x  %-12s %-5d%s\n", pc, op, operand, operandComment(prog, op, operand))
+		pc += 3
+	}
+	return sb.String()
+}
+
+func operandComment(prog *Program, op Opcode, operand int) string {
+	switch op {
+	case OP_PUSH_CONST:
+		if operand < len(prog.Constants) {
+			return fmt.Sprintf(" ; %d", prog.Constants[operand])
+		}
+	case OP_LOAD, OP_STORE:
+		if operand < len(prog.Globals) {
+			return fmt.Sprintf(" ; %s", prog.Globals[operand])
+		}
+	case OP_JMP, OP_JMP_IF_FALSE, OP_CALL:
+		return fmt.Sprintf(" ; -> %04x", operand)
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package bytecode
+
+import (
+	"bytes"
+	"compiler/msg"
+	"encoding/binary"
+	"io"
+)
+
+// .bc 文件格式（所有整数均为小端序）：
+//
+//	头部    magic "SBC\x1a" | version u16 | 常量数 u16 | 变量数 u16 | 代码长度 u32
+//	常量池  常量数 × i16
+//	变量表  变量数 × (名字长度 u8 | 名字)
+//	代码    代码长度 × u8
+const (
+	Magic   = "SBC\x1a"
+	Version = 1
+)
+
+type header struct {
+	Magic      [4]byte
+	Version    uint16
+	NumConsts  uint16
+	NumGlobals uint16
+	CodeLen    uint32
+}
+
+// Encode 将程序序列化为 .bc 文件内容
+func Encode(prog *Program) ([]byte, error) {
+	if len(prog.Constants) > 0xFFFF || len(prog.Globals) > 0xFFFF {
+		return nil, msg.Errorf(msg.BC_TOO_MANY)
+	}
+	var buf bytes.Buffer
+	h := header{
+		Version:    Version,
+		NumConsts:  uint16(len(prog.Constants)),
+		NumGlobals: uint16(len(prog.Globals)),
+		CodeLen:    uint32(len(prog.Code)),
+	}
+	copy(h.Magic[:], Magic)
+	binary.Write(&buf, binary.LittleEndian, h)
+	binary.Write(&buf, binary.LittleEndian, prog.Constants)
+	for _, name := range prog.Globals {
+		if len(name) > 0xFF {
+			return nil, msg.Errorf(msg.BC_NAME_TOO_LONG, name)
+		}
+		buf.WriteByte(byte(len(name)))
+		buf.WriteString(name)
+	}
+	buf.Write(prog.Code)
+	return buf.Bytes(), nil
+}
+
+// Decode 从 .bc 文件内容还原程序
+func Decode(data []byte) (*Program, error) {
+	r := bytes.NewReader(data)
+	var h header
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return nil, msg.Errorf(msg.BC_BAD_HEADER)
+	}
+	if string(h.Magic[:]) != Magic {
+		return nil, msg.Errorf(msg.BC_BAD_MAGIC)
+	}
+	if h.Version != Version {
+		return nil, msg.Errorf(msg.BC_BAD_VERSION, h.Version)
+	}
+
+	// 代码长度来自文件，分配前先确认剩余数据足够，避免截断或伪造的文件导致巨大的分配
+	if h.CodeLen > uint32(r.Len()) {
+		return nil, msg.Errorf(msg.BC_BAD_CODE)
+	}
+
+	prog := &Program{
+		Constants: make([]int16, h.NumConsts),
+		Globals:   make([]string, h.NumGlobals),
+		Code:      make([]byte, h.CodeLen),
+	}
+	if err := binary.Read(r, binary.LittleEndian, prog.Constants); err != nil {
+		return nil, msg.Errorf(msg.BC_BAD_CONSTANTS)
+	}
+	for i := range prog.Globals {
+		n, err := r.ReadByte()
+		if err != nil {
+			return nil, msg.Errorf(msg.BC_BAD_GLOBALS)
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, msg.Errorf(msg.BC_BAD_GLOBALS)
+		}
+		prog.Globals[i] = string(name)
+	}
+	if _, err := io.ReadFull(r, prog.Code); err != nil {
+		return nil, msg.Errorf(msg.BC_BAD_CODE)
+	}
+	return prog, nil
+}
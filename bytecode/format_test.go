@@ -0,0 +1,81 @@
+package bytecode
+
+import (
+	"compiler/msg"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	prog := compileSource(t, "int x = 1000; char c = 'z'; while (x > 998) { print x; x--; } print c;")
+	data, err := Encode(prog)
+	if err != nil {
+		t.Fatalf("Encode 出错：%v", err)
+	}
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode 出错：%v", err)
+	}
+	if !reflect.DeepEqual(got.Constants, prog.Constants) {
+		t.Errorf("常量池 %v，期望 %v", got.Constants, prog.Constants)
+	}
+	if !reflect.DeepEqual(got.Globals, prog.Globals) {
+		t.Errorf("变量表 %v，期望 %v", got.Globals, prog.Globals)
+	}
+	if !reflect.DeepEqual(got.Code, prog.Code) {
+		t.Errorf("代码 %v，期望 %v", got.Code, prog.Code)
+	}
+
+	want, _ := run(t, prog, "")
+	out, err := run(t, got, "")
+	if err != nil {
+		t.Fatalf("运行出错：%v", err)
+	}
+	if out != want {
+		t.Errorf("还原的程序输出 %q，期望 %q", out, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid, err := Encode(compileSource(t, "int x = 5; print x;"))
+	if err != nil {
+		t.Fatalf("Encode 出错：%v", err)
+	}
+	// 代码长度声称约 4 GiB 的文件头，后面没有任何数据
+	huge := append([]byte(nil), valid[:18]...)
+	binary.LittleEndian.PutUint16(huge[6:], 0)
+	binary.LittleEndian.PutUint16(huge[8:], 0)
+	binary.LittleEndian.PutUint32(huge[10:], 0xFFFFFFF0)
+
+	badMagic := append([]byte(nil), valid...)
+	badMagic[0] = 'X'
+	badVersion := append([]byte(nil), valid...)
+	badVersion[4] = 99
+
+	tests := []struct {
+		name string
+		data []byte
+		code msg.Code
+	}{
+		{"empty", nil, msg.BC_BAD_HEADER},
+		{"short_header", valid[:10], msg.BC_BAD_HEADER},
+		{"bad_magic", badMagic, msg.BC_BAD_MAGIC},
+		{"bad_version", badVersion, msg.BC_BAD_VERSION},
+		{"truncated_code", valid[:len(valid)-1], msg.BC_BAD_CODE},
+		{"huge_code_len", huge, msg.BC_BAD_CODE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			var me *msg.Error
+			if !errors.As(err, &me) {
+				t.Fatalf("错误 %v，期望 *msg.Error", err)
+			}
+			if me.Code != tt.code {
+				t.Errorf("错误编号 %s，期望 %s", me.Code, tt.code)
+			}
+		})
+	}
+}
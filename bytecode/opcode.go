@@ -0,0 +1,86 @@
+package bytecode
+
+type Opcode byte
+
+func (op Opcode) String() string {
+	if name, ok := opcodeToString[op]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// OperandWidth 返回指令操作数所占的字节数（0 或 2）
+func (op Opcode) OperandWidth() int {
+	switch op {
+	case OP_PUSH_CONST, OP_LOAD, OP_STORE, OP_JMP, OP_JMP_IF_FALSE, OP_CALL:
+		return 2
+	}
+	return 0
+}
+
+var opcodeToString = map[Opcode]string{
+	OP_PUSH_CONST:   "PUSH_CONST",
+	OP_LOAD:         "LOAD",
+	OP_STORE:        "STORE",
+	OP_POP:          "POP",
+	OP_ADD:          "ADD",
+	OP_SUB:          "SUB",
+	OP_MUL:          "MUL",
+	OP_DIV:          "DIV",
+	OP_EQ:           "EQ",
+	OP_NE:           "NE",
+	OP_LT:           "LT",
+	OP_GT:           "GT",
+	OP_LE:           "LE",
+	OP_GE:           "GE",
+	OP_JMP:          "JMP",
+	OP_JMP_IF_FALSE: "JMP_IF_FALSE",
+	OP_PRINT:        "PRINT",
+	OP_INPUT:        "INPUT",
+	OP_CALL:         "CALL",
+	OP_RET:          "RET",
+	OP_HALT:         "HALT",
+	OP_DUP:          "DUP",
+	OP_MOD:          "MOD",
+	OP_AND:          "AND",
+	OP_OR:           "OR",
+	OP_XOR:          "XOR",
+	OP_SHL:          "SHL",
+	OP_SHR:          "SHR",
+	OP_NOT:          "NOT",
+	OP_PRINT_CHAR:   "PRINT_CHAR",
+}
+
+// 指令集：栈式虚拟机，所有值均为 16 位有符号整数
+const (
+	OP_PUSH_CONST Opcode = iota // PUSH_CONST idx：将常量池第 idx 项压栈
+	OP_LOAD                     // LOAD slot：将全局变量压栈
+	OP_STORE                    // STORE slot：弹出栈顶写入全局变量
+	OP_POP                      // 丢弃栈顶
+	OP_ADD                      // 弹出 b、a，压入 a + b
+	OP_SUB                      // 弹出 b、a，压入 a - b
+	OP_MUL                      // 弹出 b、a，压入 a * b
+	OP_DIV                      // 弹出 b、a，压入 a / b（除数为 0 时报运行时错误）
+	OP_EQ                       // 比较指令：结果为 1 或 0
+	OP_NE
+	OP_LT
+	OP_GT
+	OP_LE
+	OP_GE
+	OP_JMP          // JMP addr：无条件跳转
+	OP_JMP_IF_FALSE // JMP_IF_FALSE addr：弹出栈顶，为 0 时跳转
+	OP_PRINT        // 弹出栈顶并输出一行
+	OP_INPUT        // 读入一个整数并压栈
+	OP_CALL         // CALL addr：压入返回地址后跳转
+	OP_RET          // 返回到最近一次 CALL 之后
+	OP_HALT         // 停机
+	OP_DUP          // 复制栈顶（新指令排在最后，旧的 .bc 文件仍然可以运行）
+	OP_MOD          // 弹出 b、a，压入 a % b（余数的符号与 a 相同，除数为 0 时报运行时错误）
+	OP_AND          // 弹出 b、a，压入 a & b
+	OP_OR           // 弹出 b、a，压入 a | b
+	OP_XOR          // 弹出 b、a，压入 a ^ b
+	OP_SHL          // 弹出 b、a，压入 a << b（只取 b 的低 4 位）
+	OP_SHR          // 弹出 b、a，压入 a >> b（算术右移，只取 b 的低 4 位）
+	OP_NOT          // 弹出 a，压入 ~a
+	OP_PRINT_CHAR   // 弹出栈顶，把低 8 位作为 ASCII 字符输出一行
+)
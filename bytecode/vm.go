@@ -0,0 +1,316 @@
+package bytecode
+
+import (
+	"bufio"
+	"compiler/msg"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	stackSize     = 1024
+	callStackSize = 256
+)
+
+// RuntimeError 是虚拟机执行时的错误，消息由 Code 和 Args 在输出时按语言生成
+type RuntimeError struct {
+	Code msg.Code
+	Args []interface{}
+	PC   int
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Localize(msg.Current())
+}
+
+// Localize 按语言 lang 生成错误信息
+func (e *RuntimeError) Localize(lang msg.Lang) string {
+	return msg.Format(lang, msg.DIAG_RUNTIME, e.Code, e.PC, msg.Format(lang, e.Code, e.Args...))
+}
+
+type VM struct {
+	prog    *Program
+	globals []int16
+	stack   []int16
+	calls   []int
+	pc      int
+	cur     int // 当前正在执行的指令地址
+	in      *bufio.Reader
+	out     io.Writer
+}
+
+// NewVM 创建虚拟机。in 若已是 *bufio.Reader 则直接使用，
+// 这样调用者可以与虚拟机共用同一个输入缓冲（如 REPL）。
+func NewVM(prog *Program, in io.Reader, out io.Writer) *VM {
+	br, ok := in.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(in)
+	}
+	return &VM{
+		prog:    prog,
+		globals: make([]int16, len(prog.Globals)),
+		stack:   make([]int16, 0, stackSize),
+		calls:   make([]int, 0, callStackSize),
+		in:      br,
+		out:     out,
+	}
+}
+
+// SetGlobal 设置全局变量的值，程序中没有该变量时忽略
+func (vm *VM) SetGlobal(name string, v int16) {
+	for i, g := range vm.prog.Globals {
+		if g == name {
+			vm.globals[i] = v
+		}
+	}
+}
+
+// Globals 返回全部全局变量的当前值
+func (vm *VM) Globals() map[string]int16 {
+	values := make(map[string]int16, len(vm.globals))
+	for i, name := range vm.prog.Globals {
+		values[name] = vm.globals[i]
+	}
+	return values
+}
+
+// checkInterval 是 RunContext 检查 ctx 是否被取消的指令间隔
+const checkInterval = 1024
+
+// Run 从地址 0 开始执行，直到 HALT、顶层 RET 或代码末尾
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext 与 Run 相同，但 ctx 被取消时停止执行并返回 ctx.Err()。
+// 阻塞在 input 上时能否停止取决于输入的 Reader。
+func (vm *VM) RunContext(ctx context.Context) error {
+	code := vm.prog.Code
+	vm.pc = 0
+	for steps := 0; vm.pc < len(code); steps++ {
+		if steps%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		pc := vm.pc
+		vm.cur = pc
+		op := Opcode(code[pc])
+		operand := 0
+		if w := op.OperandWidth(); w > 0 {
+			if pc+w >= len(code) {
+				return vm.errorf(msg.RT_TRUNCATED)
+			}
+			operand = int(code[pc+1]) | int(code[pc+2])<<8
+		}
+		vm.pc += 1 + op.OperandWidth()
+
+		switch op {
+		case OP_PUSH_CONST:
+			if operand >= len(vm.prog.Constants) {
+				return vm.errorf(msg.RT_CONST_INDEX, operand)
+			}
+			if err := vm.push(vm.prog.Constants[operand]); err != nil {
+				return err
+			}
+		case OP_LOAD:
+			if operand >= len(vm.globals) {
+				return vm.errorf(msg.RT_SLOT, operand)
+			}
+			if err := vm.push(vm.globals[operand]); err != nil {
+				return err
+			}
+		case OP_STORE:
+			if operand >= len(vm.globals) {
+				return vm.errorf(msg.RT_SLOT, operand)
+			}
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			vm.globals[operand] = v
+		case OP_POP:
+			if _, err := vm.pop(); err != nil {
+				return err
+			}
+		case OP_NOT:
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			if err := vm.push(^v); err != nil {
+				return err
+			}
+		case OP_DUP:
+			v, err := vm.peek()
+			if err != nil {
+				return err
+			}
+			if err := vm.push(v); err != nil {
+				return err
+			}
+		case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_EQ, OP_NE, OP_LT, OP_GT, OP_LE, OP_GE,
+			OP_MOD, OP_AND, OP_OR, OP_XOR, OP_SHL, OP_SHR:
+			b, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			a, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			r, err := vm.binary(op, a, b)
+			if err != nil {
+				return err
+			}
+			vm.push(r)
+		case OP_JMP:
+			vm.pc = operand
+		case OP_JMP_IF_FALSE:
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			if v == 0 {
+				vm.pc = operand
+			}
+		case OP_PRINT:
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(vm.out, v)
+		case OP_PRINT_CHAR:
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(vm.out, "%c\n", byte(v))
+		case OP_INPUT:
+			v, err := vm.readNumber()
+			if err != nil {
+				return err
+			}
+			if err := vm.push(v); err != nil {
+				return err
+			}
+		case OP_CALL:
+			if len(vm.calls) >= callStackSize {
+				return vm.errorf(msg.RT_CALL_OVERFLOW)
+			}
+			vm.calls = append(vm.calls, vm.pc)
+			vm.pc = operand
+		case OP_RET:
+			if len(vm.calls) == 0 {
+				return nil
+			}
+			vm.pc = vm.calls[len(vm.calls)-1]
+			vm.calls = vm.calls[:len(vm.calls)-1]
+		case OP_HALT:
+			return nil
+		default:
+			return vm.errorf(msg.RT_ILLEGAL_OPCODE, byte(op))
+		}
+	}
+	return nil
+}
+
+func (vm *VM) binary(op Opcode, a, b int16) (int16, error) {
+	switch op {
+	case OP_ADD:
+		return a + b, nil
+	case OP_SUB:
+		return a - b, nil
+	case OP_MUL:
+		return a * b, nil
+	case OP_DIV:
+		if b == 0 {
+			return 0, vm.errorf(msg.RT_DIV_ZERO)
+		}
+		return a / b, nil
+	case OP_MOD:
+		if b == 0 {
+			return 0, vm.errorf(msg.RT_DIV_ZERO)
+		}
+		return a % b, nil
+	case OP_AND:
+		return a & b, nil
+	case OP_OR:
+		return a | b, nil
+	case OP_XOR:
+		return a ^ b, nil
+	case OP_SHL:
+		return a << (uint16(b) & 15), nil
+	case OP_SHR:
+		return a >> (uint16(b) & 15), nil
+	case OP_EQ:
+		return boolValue(a == b), nil
+	case OP_NE:
+		return boolValue(a != b), nil
+	case OP_LT:
+		return boolValue(a < b), nil
+	case OP_GT:
+		return boolValue(a > b), nil
+	case OP_LE:
+		return boolValue(a <= b), nil
+	case OP_GE:
+		return boolValue(a >= b), nil
+	}
+	return 0, vm.errorf(msg.RT_ILLEGAL_OPCODE, byte(op))
+}
+
+// readNumber 读入一行整数：可以有一个前导的 '-' 或 '+'，其后只能是数字。
+// 输入不合法或超出 16 位范围时提示重新输入，与 8086 的 read_number 一致
+func (vm *VM) readNumber() (int16, error) {
+	for {
+		line, err := vm.in.ReadString('\n')
+		if err != nil && line == "" {
+			return 0, vm.errorf(msg.RT_READ_INPUT, err)
+		}
+		text := strings.TrimRight(line, "\r\n")
+		if n, perr := strconv.ParseInt(text, 10, 16); perr == nil {
+			return int16(n), nil
+		}
+		fmt.Fprint(vm.out, msg.Get(msg.RT_BAD_INPUT, text))
+	}
+}
+
+func (vm *VM) push(v int16) error {
+	if len(vm.stack) >= stackSize {
+		return vm.errorf(msg.RT_STACK_OVERFLOW)
+	}
+	vm.stack = append(vm.stack, v)
+	return nil
+}
+
+// peek 返回栈顶的值但不弹出
+func (vm *VM) peek() (int16, error) {
+	if len(vm.stack) == 0 {
+		return 0, vm.errorf(msg.RT_STACK_UNDERFLOW)
+	}
+	return vm.stack[len(vm.stack)-1], nil
+}
+
+func (vm *VM) pop() (int16, error) {
+	if len(vm.stack) == 0 {
+		return 0, vm.errorf(msg.RT_STACK_UNDERFLOW)
+	}
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v, nil
+}
+
+// errorf 生成指向当前指令的运行时错误
+func (vm *VM) errorf(code msg.Code, args ...interface{}) error {
+	return &RuntimeError{Code: code, Args: args, PC: vm.cur}
+}
+
+func boolValue(b bool) int16 {
+	if b {
+		return 1
+	}
+	return 0
+}
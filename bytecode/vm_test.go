@@ -0,0 +1,143 @@
+package bytecode
+
+import (
+	"bytes"
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// compileSource 把源代码编译为字节码程序
+func compileSource(t *testing.T, source string) *Program {
+	t.Helper()
+	ast, err := parser.NewParser(lexer.NewLexer(source)).Parse()
+	if err != nil {
+		t.Fatalf("解析失败：%v", err)
+	}
+	prog, err := Compile(ast)
+	if err != nil {
+		t.Fatalf("编译失败：%v", err)
+	}
+	return prog
+}
+
+// run 执行程序，返回输出和错误
+func run(t *testing.T, prog *Program, input string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := NewVM(prog, strings.NewReader(input), &out).Run()
+	return out.String(), err
+}
+
+func TestVMRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		input  string
+		want   string
+	}{
+		{"print", "print 1 + 2 * 3;", "", "7\n"},
+		{"wrap", "int x = 32767; x++; print x; print 300 * 300;", "", "-32768\n24464\n"},
+		{"division", "int a = -7; print a / 2; print a % 2; print 7 % -2;", "", "-3\n-1\n1\n"},
+		{"min_div", "int m = -32768; int n = -1; print m / n; print m % n;", "", "-32768\n0\n"},
+		{"shift", "print 1 << 17; print -16 >> 2;", "", "2\n-4\n"},
+		{"while", "int i = 0; while (i < 3) { print i; i += 1; }", "", "0\n1\n2\n"},
+		{"switch", "int x = 2; switch (x) { case 1: print 10; case 2, 3: print 20; default: print 30; }", "", "20\n"},
+		{"const_case", "const A = 1; const B = A + 1; int x = 2; switch (x) { case A: print 10; case B: print 20; default: print 30; }", "", "20\n"},
+		{"char", "char c = 'A'; print c;", "", "A\n"},
+		{"input", "int x; input x; print x + 1;", "41\n", "42\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(t, compileSource(t, tt.source), tt.input)
+			if err != nil {
+				t.Fatalf("运行出错：%v", err)
+			}
+			if got != tt.want {
+				t.Errorf("输出 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVMInputRetry(t *testing.T) {
+	prog := compileSource(t, "int x; input x; print x;")
+	got, err := run(t, prog, "abc\n1-2\n--5\n40000\n+12\n")
+	if err != nil {
+		t.Fatalf("运行出错：%v", err)
+	}
+	for _, bad := range []string{"abc", "1-2", "--5", "40000"} {
+		if !strings.Contains(got, "'"+bad+"'") {
+			t.Errorf("输入 %q 没有提示重新输入，输出 %q", bad, got)
+		}
+	}
+	if !strings.HasSuffix(got, "12\n") {
+		t.Errorf("输出 %q，期望以 12 结束", got)
+	}
+}
+
+func TestVMRuntimeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		input  string
+		code   msg.Code
+	}{
+		{"div_by_zero", "int z = 0; print 1 / z;", "", msg.RT_DIV_ZERO},
+		{"input_eof", "int x; input x;", "", msg.RT_READ_INPUT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, compileSource(t, tt.source), tt.input)
+			var re *RuntimeError
+			if !errors.As(err, &re) {
+				t.Fatalf("错误 %v，期望 *RuntimeError", err)
+			}
+			if re.Code != tt.code {
+				t.Errorf("错误编号 %s，期望 %s", re.Code, tt.code)
+			}
+		})
+	}
+}
+
+// TestVMStack 用手写的字节码检查 DUP 和 NOT 的结果以及栈空、栈满时报告的错误
+func TestVMStack(t *testing.T) {
+	push := []byte{byte(OP_PUSH_CONST), 0, 0}
+	tests := []struct {
+		name string
+		code []byte
+		want string
+		err  msg.Code
+	}{
+		{"dup", append(push, byte(OP_DUP), byte(OP_ADD), byte(OP_PRINT)), "14\n", ""},
+		{"dup_empty", []byte{byte(OP_DUP)}, "", msg.RT_STACK_UNDERFLOW},
+		{"dup_full", append(push, byte(OP_DUP), byte(OP_JMP), 3, 0), "", msg.RT_STACK_OVERFLOW},
+		{"not", append(push, byte(OP_NOT), byte(OP_PRINT)), "-8\n", ""},
+		{"not_empty", []byte{byte(OP_NOT)}, "", msg.RT_STACK_UNDERFLOW},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			vm := NewVM(&Program{Constants: []int16{7}, Code: tt.code}, strings.NewReader(""), &out)
+			err := vm.Run()
+			var re *RuntimeError
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("运行出错：%v", err)
+			case tt.err != "" && !errors.As(err, &re):
+				t.Fatalf("错误 %v，期望 *RuntimeError", err)
+			case tt.err != "" && re.Code != tt.err:
+				t.Errorf("错误编号 %s，期望 %s", re.Code, tt.err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("输出 %q，期望 %q", got, tt.want)
+			}
+			if tt.err == msg.RT_STACK_OVERFLOW && len(vm.stack) != stackSize {
+				t.Errorf("栈中有 %d 个值，期望 %d", len(vm.stack), stackSize)
+			}
+		})
+	}
+}
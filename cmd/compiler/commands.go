@@ -0,0 +1,311 @@
+package main
+
+import (
+	"compiler"
+	"compiler/backend"
+	"compiler/bytecode"
+	"compiler/format"
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --emit 可选的阶段
+var emitStages = []string{"tokens", "ast", "ast-json", "ir", "target"}
+
+// buildConfig 是 build 命令的选项
+type buildConfig struct {
+	output   string
+	target   string
+	level    int
+	stages   map[string]bool
+	annotate bool
+	overflow backend.Overflow
+}
+
+func cmdBuild(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	output := fs.String("o", "", msg.Get(msg.CLI_FLAG_OUTPUT))
+	target := fs.String("target", "emu8086", msg.Get(msg.CLI_FLAG_TARGET, strings.Join(compiler.Targets(), ", ")))
+	level := fs.Int("O", 0, msg.Get(msg.CLI_FLAG_OPT))
+	emit := fs.String("emit", "target", msg.Get(msg.CLI_FLAG_EMIT, strings.Join(emitStages, ", ")))
+	annotate := fs.Bool("annotate", false, msg.Get(msg.CLI_FLAG_ANNOTATE))
+	overflow := fs.String("overflow", "wrap", msg.Get(msg.CLI_FLAG_OVERFLOW))
+	watchMode := fs.Bool("watch", false, msg.Get(msg.CLI_FLAG_WATCH))
+	run := fs.Bool("run", false, msg.Get(msg.CLI_FLAG_RUN))
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+
+	cfg := buildConfig{output: *output, target: *target, level: *level, annotate: *annotate, stages: make(map[string]bool)}
+	for _, stage := range splitList(*emit) {
+		if !contains(emitStages, stage) {
+			fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_UNKNOWN_STAGE, stage, strings.Join(emitStages, ", ")))
+			return exitUsage
+		}
+		cfg.stages[stage] = true
+	}
+	if _, ok := backend.Lookup(*target); !ok {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_UNKNOWN_TARGET, *target, strings.Join(compiler.Targets(), ", ")))
+		return exitUsage
+	}
+	if cfg.overflow, ok = backend.LookupOverflow(*overflow); !ok {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_UNKNOWN_OVERFLOW, *overflow, "wrap, trap"))
+		return exitUsage
+	}
+	if *run && !*watchMode {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_RUN_NEEDS_WATCH))
+		return exitUsage
+	}
+	if *watchMode {
+		if path == "-" {
+			fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_WATCH_STDIN))
+			return exitUsage
+		}
+		return watch(path, cfg, *run)
+	}
+	_, code = build(path, cfg)
+	return code
+}
+
+// build 编译一次并写出结果，返回编译结果（出错时可能为 nil）和退出码
+func build(path string, cfg buildConfig) (*compiler.Result, int) {
+	source, err := readSourceFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, exitError
+	}
+	opts := compiler.Options{OptLevel: cfg.level, Annotate: cfg.annotate, Overflow: cfg.overflow}
+	if path != "-" {
+		opts.Filename = path
+	}
+	if cfg.stages["target"] {
+		opts.Target = cfg.target
+	}
+	res, err := compiler.Compile(context.Background(), source, opts)
+	if cfg.stages["tokens"] && res != nil {
+		printTokens(res.Tokens)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return res, exitError
+	}
+	if cfg.stages["ast"] {
+		fmt.Print(parser.Dump(res.Optimized))
+	}
+	if cfg.stages["ast-json"] {
+		if err := printASTJSON(res.Optimized); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return res, exitError
+		}
+	}
+	if cfg.stages["ir"] {
+		if res.IR == nil {
+			fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_NO_IR))
+			return res, exitError
+		}
+		fmt.Print(bytecode.Disassemble(res.IR))
+	}
+	if !cfg.stages["target"] {
+		return res, exitOK
+	}
+
+	// 输出目标代码
+	artifact := res.Artifacts[0]
+	outPath := cfg.output
+	if outPath == "" {
+		outPath = "output" + artifact.Extension
+	}
+	if err := writeOutput(outPath, artifact.Data); err != nil {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_CODEGEN_ERROR, err))
+		return res, exitError
+	}
+	if outPath == "-" {
+		return res, exitOK
+	}
+	fmt.Println(msg.Get(msg.CLI_BUILD_OK, outPath))
+	// 其余产物（如源码映射）与主输出文件同名，只替换扩展名
+	base := strings.TrimSuffix(outPath, artifact.Extension)
+	for _, extra := range res.Artifacts[1:] {
+		extraPath := base + extra.Extension
+		if err := writeOutput(extraPath, extra.Data); err != nil {
+			fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_CODEGEN_ERROR, err))
+			return res, exitError
+		}
+		fmt.Println(msg.Get(msg.CLI_EXTRA_OUTPUT, extraPath))
+	}
+	if artifact.Target == "emu8086" {
+		fmt.Println(msg.Get(msg.CLI_EMU8086_HINT))
+	}
+	return res, exitOK
+}
+
+// cmdRun 编译为字节码后直接在虚拟机中执行
+func cmdRun(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	level := fs.Int("O", 0, msg.Get(msg.CLI_FLAG_OPT))
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	res := compileFile(path, compiler.Options{OptLevel: *level})
+	if res == nil {
+		return exitError
+	}
+	if res.IR == nil {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_CANNOT_RUN))
+		return exitError
+	}
+	return runProgram(res.IR)
+}
+
+func cmdCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	if compileFile(path, compiler.Options{}) == nil {
+		return exitError
+	}
+	fmt.Println(msg.Get(msg.CLI_CHECK_OK))
+	return exitOK
+}
+
+// cmdFmt 格式化源文件。默认输出到标准输出；
+// -w 写回原文件，-d 输出 diff，-l 只列出格式不规范的文件
+func cmdFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, msg.Get(msg.CLI_FLAG_FMT_WRITE))
+	diff := fs.Bool("d", false, msg.Get(msg.CLI_FLAG_FMT_DIFF))
+	list := fs.Bool("l", false, msg.Get(msg.CLI_FLAG_FMT_LIST))
+	files, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_FMT_NEED_FILE))
+		return exitUsage
+	}
+
+	status := exitOK
+	for _, path := range files {
+		if *write && path == "-" {
+			fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_FMT_WRITE_STDIN))
+			return exitUsage
+		}
+		source, err := readSourceFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitError
+			continue
+		}
+		formatted, err := format.Source(source)
+		if err != nil {
+			name := path
+			if path == "-" {
+				name = ""
+			}
+			fmt.Fprintln(os.Stderr, compiler.DiagnosticFromError(name, err))
+			status = exitError
+			continue
+		}
+
+		changed := formatted != source
+		if *list && changed {
+			fmt.Println(path)
+		}
+		if *diff && changed {
+			fmt.Print(unifiedDiff(path, source, formatted))
+		}
+		if *write && changed {
+			if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_WRITE_FILE, err))
+				status = exitError
+			}
+		}
+		if !*list && !*diff && !*write {
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
+
+func cmdTokens(args []string) int {
+	fs := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	source, err := readSourceFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	// 只关心词法错误，语法错误不影响输出词法单元
+	res, _ := compiler.Compile(context.Background(), source, compiler.Options{})
+	printTokens(res.Tokens)
+	status := exitOK
+	for _, d := range res.Diagnostics {
+		if d.Stage == compiler.StageLexer {
+			fmt.Fprintln(os.Stderr, d)
+			status = exitError
+		}
+	}
+	return status
+}
+
+func cmdAST(args []string) int {
+	fs := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, msg.Get(msg.CLI_FLAG_AST_JSON))
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	res := compileFile(path, compiler.Options{})
+	if res == nil {
+		return exitError
+	}
+	if *asJSON {
+		if err := printASTJSON(res.AST); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	fmt.Print(parser.Dump(res.AST))
+	return exitOK
+}
+
+func printASTJSON(ast *parser.AST) error {
+	data, err := parser.MarshalJSON(ast)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func printTokens(tokens []lexer.Token) {
+	for _, tok := range tokens {
+		fmt.Printf("%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
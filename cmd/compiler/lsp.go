@@ -0,0 +1,30 @@
+package main
+
+import (
+	"compiler/lsp"
+	"compiler/msg"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// cmdLSP 通过标准输入输出运行语言服务器，供编辑器插件启动
+func cmdLSP(args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	files, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) > 0 {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_NO_FILE_ARGS, "lsp"))
+		return exitUsage
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
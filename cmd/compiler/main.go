@@ -0,0 +1,189 @@
+package main
+
+import (
+	"compiler"
+	"compiler/msg"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// 进程退出码
+const (
+	exitOK    = 0 // 成功
+	exitError = 1 // 词法、语法、语义、代码生成或运行时错误
+	exitUsage = 2 // 命令行用法错误
+)
+
+var commands = map[string]func(args []string) int{
+	"build":  cmdBuild,
+	"run":    cmdRun,
+	"check":  cmdCheck,
+	"fmt":    cmdFmt,
+	"tokens": cmdTokens,
+	"ast":    cmdAST,
+	"repl":   cmdREPL,
+	"lsp":    cmdLSP,
+	"vm":     cmdVM,
+	"dis":    cmdDis,
+}
+
+func main() {
+	os.Exit(realMain(os.Args[1:]))
+}
+
+func realMain(args []string) int {
+	args, lang, ok := parseLang(args)
+	if !ok {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_UNKNOWN_LANG, lang, strings.Join(msg.Langs(), ", ")))
+		return exitUsage
+	}
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, msg.Get(msg.CLI_USAGE))
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(msg.Get(msg.CLI_USAGE))
+		return exitOK
+	}
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd(args[1:])
+	}
+	// 兼容旧用法：compiler [选项] <源文件> 等同于 build
+	return cmdBuild(args)
+}
+
+// parseLang 从命令行的任意位置取出全局选项 --lang，并设置消息语言；
+// 没有 --lang 时按环境变量选择。语言无法识别时返回 ok 为 false 和该语言名。
+func parseLang(args []string) (rest []string, lang string, ok bool) {
+	found := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--lang" || arg == "-lang":
+			if i+1 < len(args) {
+				i++
+				lang = args[i]
+			}
+			found = true
+		case strings.HasPrefix(arg, "--lang=") || strings.HasPrefix(arg, "-lang="):
+			lang = arg[strings.Index(arg, "=")+1:]
+			found = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if !found {
+		msg.SetLang(msg.FromEnv())
+		return rest, "", true
+	}
+	l, ok := msg.ParseLang(lang)
+	if !ok {
+		return rest, lang, false
+	}
+	msg.SetLang(l)
+	return rest, lang, true
+}
+
+var optLevelFlag = regexp.MustCompile(`^--?O([0-9])$`)
+
+// parseArgs 解析命令行，允许选项出现在文件参数之后，
+// 并把 -O2 这样的写法改写为 flag 包能识别的 -O=2
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		if m := optLevelFlag.FindStringSubmatch(arg); m != nil {
+			arg = "-O=" + m[1]
+		}
+		normalized[i] = arg
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(normalized); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		normalized = fs.Args()[1:]
+	}
+}
+
+// parseFileArg 解析选项并要求恰好一个文件参数
+func parseFileArg(fs *flag.FlagSet, args []string) (string, int, bool) {
+	files, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return "", exitOK, false
+	}
+	if err != nil {
+		return "", exitUsage, false
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_NEED_FILE, fs.Name()))
+		return "", exitUsage, false
+	}
+	return files[0], exitOK, true
+}
+
+// readSourceFile 读取源文件，路径为 - 时读取标准输入
+func readSourceFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", msg.Errorf(msg.CLI_READ_SOURCE, err)
+	}
+	return string(data), nil
+}
+
+// compileFile 读取并编译源文件，出错时打印错误并返回 nil
+func compileFile(path string, opts compiler.Options) *compiler.Result {
+	source, err := readSourceFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	if path != "-" {
+		opts.Filename = path
+	}
+	res, err := compiler.Compile(context.Background(), source, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	return res
+}
+
+// writeOutput 写出结果，路径为 - 时写到标准输出
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return msg.Errorf(msg.CLI_WRITE_OUTPUT, err)
+	}
+	return nil
+}
+
+// splitList 拆分逗号分隔的选项值
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
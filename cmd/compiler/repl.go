@@ -0,0 +1,44 @@
+package main
+
+import (
+	"compiler/msg"
+	"compiler/repl"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func cmdREPL(args []string) int {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	history := fs.String("history", defaultHistoryFile(), msg.Get(msg.CLI_FLAG_HISTORY))
+	files, err := parseArgs(fs, args)
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(files) > 0 {
+		fmt.Fprintln(os.Stderr, msg.Get(msg.CLI_NO_FILE_ARGS, "repl"))
+		return exitUsage
+	}
+
+	r := repl.New(os.Stdin, os.Stdout)
+	if *history != "" {
+		r.SetHistoryFile(*history)
+	}
+	if err := r.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".compiler_history")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"compiler/bytecode"
+	"compiler/msg"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// .bc 文件由 build --target=bytecode 生成
+
+// cmdVM 在虚拟机中执行 .bc 文件
+func cmdVM(args []string) int {
+	fs := flag.NewFlagSet("vm", flag.ContinueOnError)
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	prog, err := readBytecodeFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return runProgram(prog)
+}
+
+// cmdDis 反汇编 .bc 文件
+func cmdDis(args []string) int {
+	fs := flag.NewFlagSet("dis", flag.ContinueOnError)
+	path, code, ok := parseFileArg(fs, args)
+	if !ok {
+		return code
+	}
+	prog, err := readBytecodeFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Print(bytecode.Disassemble(prog))
+	return exitOK
+}
+
+func runProgram(prog *bytecode.Program) int {
+	vm := bytecode.NewVM(prog, os.Stdin, os.Stdout)
+	if err := vm.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func readBytecodeFile(path string) (*bytecode.Program, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, msg.Errorf(msg.CLI_READ_BYTECODE, err)
+	}
+	return bytecode.Decode(data)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"compiler"
+	"compiler/bytecode"
+	"compiler/msg"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// 测试中改为更短的时间
+var (
+	pollInterval = 200 * time.Millisecond // 检查文件修改的间隔
+	debounce     = 100 * time.Millisecond // 最后一次修改后等待多久才重新编译
+)
+
+// fileStamp 用修改时间和大小判断文件是否变化，文件不存在时为零值
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[path] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// restrict 返回 files 中每个文件的状态，stamps 中没有的文件重新读取
+func restrict(stamps map[string]fileStamp, files []string) map[string]fileStamp {
+	result := stat(files)
+	for _, path := range files {
+		if s, ok := stamps[path]; ok {
+			result[path] = s
+		}
+	}
+	return result
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, s := range a {
+		if b[path] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// watch 编译一次，然后每当源文件变化就清屏并重新编译，直到按下 Ctrl+C。
+// run 为真时每次编译成功后在虚拟机中运行程序，文件再次变化时先停止上一次运行。
+func watch(path string, cfg buildConfig, run bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var input *lineReader
+	if run {
+		input = newLineReader(os.Stdin)
+	}
+
+	files := []string{path}
+	for {
+		clearScreen()
+		fmt.Println(msg.Get(msg.CLI_WATCH_BUILDING, time.Now().Format("15:04:05"), path))
+		// 在编译前记录状态，编译期间的修改也会触发下一次编译
+		stamps := stat(files)
+		res, _ := build(path, cfg)
+		if res != nil && len(res.Files) > 0 {
+			// 同时监视导入的文件，新导入的文件从现在开始监视
+			files = res.Files
+			stamps = restrict(stamps, files)
+		}
+
+		// 程序在单独的 goroutine 中运行，重新编译前取消并等待它退出
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		if run && res != nil && res.IR != nil {
+			go func() {
+				defer close(done)
+				runInWatch(runCtx, res, input)
+			}()
+		} else {
+			close(done)
+		}
+		fmt.Println(msg.Get(msg.CLI_WATCH_WAITING))
+
+		changed := waitForChange(ctx, files, stamps)
+		cancel()
+		<-done
+		if !changed {
+			fmt.Println()
+			return exitOK
+		}
+	}
+}
+
+// waitForChange 轮询文件直到有修改且在 debounce 时间内不再变化，ctx 被取消时返回 false
+func waitForChange(ctx context.Context, files []string, stamps map[string]fileStamp) bool {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		current := stat(files)
+		if sameStamps(current, stamps) {
+			continue
+		}
+		// 编辑器保存时可能分几次写入，等文件稳定后再编译
+		for {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(debounce):
+			}
+			next := stat(files)
+			if sameStamps(next, current) {
+				return true
+			}
+			current = next
+		}
+	}
+}
+
+func runInWatch(ctx context.Context, res *compiler.Result, input *lineReader) {
+	fmt.Println(msg.Get(msg.CLI_WATCH_RUN))
+	vm := bytecode.NewVM(res.IR, input.withContext(ctx), os.Stdout)
+	err := vm.RunContext(ctx)
+	switch {
+	case ctx.Err() != nil:
+		// 文件被修改或按下了 Ctrl+C
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+	default:
+		fmt.Println(msg.Get(msg.CLI_WATCH_RUN_DONE))
+	}
+}
+
+// clearScreen 在终端中清屏，输出被重定向时什么也不做
+func clearScreen() {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print("\033[H\033[2J")
+	}
+}
+
+// lineReader 在整个监视期间用一个 goroutine 读取标准输入，
+// 每次运行通过 withContext 得到可以被取消的 Reader，
+// 这样阻塞在 input 上的程序也能在文件修改时停止，且不会每次运行都留下一个读取 goroutine。
+type lineReader struct {
+	lines chan string
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	lr := &lineReader{lines: make(chan string)}
+	go func() {
+		defer close(lr.lines)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				lr.lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lr
+}
+
+func (lr *lineReader) withContext(ctx context.Context) io.Reader {
+	return &ctxReader{ctx: ctx, lines: lr.lines}
+}
+
+type ctxReader struct {
+	ctx   context.Context
+	lines <-chan string
+	buf   string
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if r.buf == "" {
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case line, ok := <-r.lines:
+			if !ok {
+				return 0, io.EOF
+			}
+			r.buf = line
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
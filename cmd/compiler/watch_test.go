@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fastPolling 在测试期间缩短轮询间隔和等待文件稳定的时间
+func fastPolling(t *testing.T) {
+	oldPoll, oldDebounce := pollInterval, debounce
+	pollInterval, debounce = 5*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { pollInterval, debounce = oldPoll, oldDebounce })
+}
+
+// waitResult 在另一个 goroutine 中调用 waitForChange，超时则测试失败
+func waitResult(t *testing.T, ctx context.Context, files []string) bool {
+	t.Helper()
+	result := make(chan bool, 1)
+	go func() { result <- waitForChange(ctx, files, stat(files)) }()
+	select {
+	case changed := <-result:
+		return changed
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForChange 没有返回")
+		return false
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	fastPolling(t)
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.src"), filepath.Join(dir, "b.src")
+	if err := os.WriteFile(a, []byte("print 1;\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改其中一个文件
+	time.AfterFunc(20*time.Millisecond, func() { os.WriteFile(a, []byte("print 12;\n"), 0600) })
+	if !waitResult(t, context.Background(), []string{a, b}) {
+		t.Error("修改文件后没有返回 true")
+	}
+
+	// 出现原来不存在的文件
+	time.AfterFunc(20*time.Millisecond, func() { os.WriteFile(b, []byte("print 2;\n"), 0600) })
+	if !waitResult(t, context.Background(), []string{a, b}) {
+		t.Error("创建文件后没有返回 true")
+	}
+
+	// 连续多次写入时等最后一次写入之后才返回
+	const writes = 10
+	if err := os.WriteFile(a, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < writes; i++ {
+			time.Sleep(5 * time.Millisecond)
+			f, err := os.OpenFile(a, os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				return
+			}
+			f.Write([]byte{'\n'})
+			f.Close()
+		}
+	}()
+	if !waitResult(t, context.Background(), []string{a}) {
+		t.Error("连续写入后没有返回 true")
+	}
+	if info, err := os.Stat(a); err != nil || info.Size() != writes {
+		t.Errorf("返回时文件还在写入：%v %v", info.Size(), err)
+	}
+
+	// 没有修改时取消
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if waitResult(t, ctx, []string{a, b}) {
+		t.Error("取消后返回 true")
+	}
+}
+
+// TestCtxReader 检查取消后 Read 立即返回，且不会取走下一次运行要读的行
+func TestCtxReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	lr := newLineReader(pr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := lr.withContext(ctx).Read(make([]byte, 16))
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("取消后 Read 返回 %v，期望 context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后 Read 没有返回")
+	}
+
+	go pw.Write([]byte("42\n"))
+	r := lr.withContext(context.Background())
+	var got []byte
+	buf := make([]byte, 2)
+	for len(got) < 3 {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "42\n" {
+		t.Errorf("读到 %q，期望 %q", got, "42\n")
+	}
+}
@@ -0,0 +1,49 @@
+package codegen
+
+import (
+	"compiler/backend"
+	"compiler/parser"
+	"strings"
+)
+
+// asmBackend 将某种方言和格式的 CodeGenerator 包装为一个目标。
+// 使用方言默认格式的目标直接以方言命名，其余加上 -com / -exe 后缀。
+type asmBackend struct {
+	dialect Dialect
+	format  Format
+	trap    bool
+}
+
+func init() {
+	for _, d := range []Dialect{DIALECT_EMU8086, DIALECT_MASM, DIALECT_TASM, DIALECT_NASM} {
+		backend.Register(asmBackend{dialect: d, format: FORMAT_COM})
+		backend.Register(asmBackend{dialect: d, format: FORMAT_EXE})
+	}
+}
+
+func (b asmBackend) Name() string {
+	if b.format == b.dialect.DefaultFormat() {
+		return b.dialect.String()
+	}
+	return b.dialect.String() + "-" + b.format.String()
+}
+
+func (b asmBackend) Extension() string         { return ".asm" }
+func (b asmBackend) Features() backend.Feature { return backend.FEATURE_ALL }
+
+// TrapOverflow 返回生成溢出检查代码的同名目标
+func (b asmBackend) TrapOverflow() backend.Backend {
+	b.trap = true
+	return b
+}
+
+func (b asmBackend) Generate(ast *parser.AST) ([]byte, error) {
+	data, _, err := b.GenerateAnnotated(ast, "")
+	return data, err
+}
+
+func (b asmBackend) GenerateAnnotated(ast *parser.AST, source string) ([]byte, []backend.Mapping, error) {
+	cg := NewCodeGeneratorWithOptions(Options{Dialect: b.dialect, Format: b.format, Source: source, Trap: b.trap})
+	lines := cg.Generate(ast)
+	return []byte(strings.Join(lines, "\n") + "\n"), cg.SourceMap(), nil
+}
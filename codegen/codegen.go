@@ -1,124 +1,259 @@
 package codegen
 
 import (
+	"compiler/backend"
 	"compiler/parser"
 	"fmt"
+	"strings"
 )
 
 type CodeGenerator struct {
 	code    []string
 	varCount int
 	varMap  map[string]string
+	consts  map[string]int16 // 常量的值，常量用 EQU 定义，不占用存储
+	constNames []string      // 常量名，按声明顺序排列
+	types   map[string]parser.Type // 变量和常量的类型，char 变量只占一个字节
+	long    bool                   // 程序用到 long，需要 32 位运算的辅助函数
+	trap    bool                   // 检查算术溢出和输入范围，见 trap.go
+	traps   []trapSite             // 已用到的运行时错误桩代码，按出现顺序排列
 	labelCount int
+	dialect Dialect
+	format  Format
+	source  []string          // 源代码各行，为空时不插入注释
+	mappings []backend.Mapping
+	loops   []loopLabels      // 正在生成的循环和 switch，最内层在最后
+	switches map[*parser.SwitchStatement]*switchPlan
+	tables   []*switchPlan // 使用跳转表的 switch，按出现顺序排列
+}
+
+// loopLabels 是 break 和 continue 跳转的目标
+type loopLabels struct {
+	breakLabel    string
+	continueLabel string
+}
+
+// switchPlan 是在生成代码之前为 switch 确定的标号。跳转表放在数据段中，
+// 生成数据段时表中的标号就必须已经确定。
+type switchPlan struct {
+	labels       []string // 各分支的标号，与 Cases 一一对应
+	defaultLabel string   // 没有 case 匹配时跳转的标号，没有 default 时为 endLabel
+	endLabel     string
+	table        string   // 跳转表的名字，使用比较链时为空
+	min          int64    // 跳转表第一项对应的值
+	entries      []string // 跳转表的各项
+}
+
+// 使用跳转表的条件：case 值至少有 jumpTableMinCases 个，
+// 且最小值到最大值的范围不超过 jumpTableMaxSize，至少一半的表项是某个 case
+const (
+	jumpTableMinCases = 4
+	jumpTableMaxSize  = 256
+)
+
+// Options 控制代码生成的输出形式
+type Options struct {
+	Dialect Dialect
+	Format  Format
+	// Source 非空时，在每条语句的代码前插入 "; line 7: x = x - 1;" 形式的注释
+	Source string
+	// Trap 为真时在运行时检查算术溢出和输入范围，出错时输出源代码行号并退出
+	Trap bool
 }
 
 func NewCodeGenerator() *CodeGenerator {
-	return &CodeGenerator{
+	return NewCodeGeneratorWithOptions(Options{})
+}
+
+func NewCodeGeneratorWithOptions(opts Options) *CodeGenerator {
+	if opts.Format == FORMAT_DEFAULT {
+		opts.Format = opts.Dialect.DefaultFormat()
+	}
+	cg := &CodeGenerator{
 		code:    make([]string, 0),
 		varMap:  make(map[string]string),
+		consts:  make(map[string]int16),
+		types:   make(map[string]parser.Type),
+		switches: make(map[*parser.SwitchStatement]*switchPlan),
 		labelCount: 0,
+		dialect: opts.Dialect,
+		format:  opts.Format,
+		trap:    opts.Trap,
+	}
+	if opts.Source != "" {
+		cg.source = strings.Split(opts.Source, "\n")
 	}
+	return cg
 }
 
-func (cg *CodeGenerator) Generate(ast *parser.AST) []string {
-	// Initial COM header and jump to main execution
-	cg.code = append(cg.code,
-		"#make_COM#",
-		"ORG 100h",
-		"",
-		"jmp main_start", // Jump over data and procedures
-		"",
-		".DATA",
-		"    msg_div_by_zero db 'Error: Division by zero!$'",
-		"    newline db 13, 10, '$'",
-	)
+// DefineVar 声明在程序之外已经定义的 t 类型的变量（如 REPL 中之前输入的变量）
+func (cg *CodeGenerator) DefineVar(name string, t parser.Type) {
+	cg.types[name] = t
+}
 
-	// Declare variables in .DATA section
-	cg.collectVars(ast)
-	for name := range cg.varMap {
-		cg.code = append(cg.code, fmt.Sprintf("    %s dw 0", name))
+// DefineConst 声明在程序之外已经声明的常量（如 REPL 中之前输入的常量），
+// 与程序中声明的常量一样用 EQU 定义
+func (cg *CodeGenerator) DefineConst(name string, t parser.Type, value int16) {
+	cg.consts[name] = value
+	cg.constNames = append(cg.constNames, name)
+	cg.types[name] = t
+}
+
+// SourceMap 返回上一次 Generate 输出的代码行到源语句的映射
+func (cg *CodeGenerator) SourceMap() []backend.Mapping {
+	return cg.mappings
+}
+
+func (cg *CodeGenerator) Generate(ast *parser.AST) []string {
+	// Declare variables in the data section
+	checker := parser.NewChecker(nil)
+	for name, t := range cg.types {
+		if v, ok := cg.consts[name]; ok {
+			checker.DefineConst(name, t, v)
+		} else {
+			checker.DefineVar(name, t)
+		}
 	}
-	cg.code = append(cg.code, "")
+	cg.types = checker.Infer(ast)
+	cg.long = backend.Used(ast)&backend.FEATURE_LONG != 0
+	cg.collectVars(ast)
+	cg.genHeader()
+	cg.genData()
 
-	// Add helper procedures to the .CODE section before main_start
-	cg.code = append(cg.code, ".CODE")
+	// Add helper procedures to the code section before main_start
+	cg.genCodeSegment()
 	cg.AddHelperFunctions()
+	if cg.long {
+		cg.addLongHelpers()
+	}
+	if cg.trap {
+		cg.addTrapHelpers()
+	}
 
-	cg.code = append(cg.code,
-		"main_start:", // Actual start of the main program logic
-		"    mov ax, @data",
-		"    mov ds, ax",
-	)
+	cg.genEntry()
 
 	// Generate all statements (main program logic)
 	for _, stmt := range ast.Statements {
 		cg.genStatement(stmt)
 	}
 
-	cg.code = append(cg.code,
-		"    mov ah, 4Ch", // Program exit
-		"    int 21h",
-	)
+	cg.genExit()
+	cg.relaxJumps()
 
 	return cg.code
 }
 
 func (cg *CodeGenerator) collectVars(ast *parser.AST) {
 	for _, stmt := range ast.Statements {
-		switch s := stmt.(type) {
-		case *parser.Assignment:
-			cg.varMap[s.Ident] = s.Ident
-			cg.collectVarsFromExpr(s.Value)
-		case *parser.PrintStatement:
-			cg.collectVarsFromExpr(s.Expr)
-		case *parser.InputStatement:
-			cg.varMap[s.Ident] = s.Ident
-		case *parser.IfStatement:
-			cg.collectVarsFromExpr(s.Condition)
-			for _, stmt := range s.Then {
-				cg.collectVarsFromStatement(stmt)
-			}
-			for _, stmt := range s.Else {
-				cg.collectVarsFromStatement(stmt)
-			}
-		case *parser.WhileStatement:
-			cg.collectVarsFromExpr(s.Condition)
-			for _, stmt := range s.Body {
-				cg.collectVarsFromStatement(stmt)
-			}
-		}
+		cg.collectVarsFromStatement(stmt)
 	}
 }
 
 func (cg *CodeGenerator) collectVarsFromExpr(expr parser.Expr) {
 	switch e := expr.(type) {
 	case *parser.IdentExpr:
-		cg.varMap[e.Name] = e.Name
+		if _, ok := cg.consts[e.Name]; !ok {
+			cg.varMap[e.Name] = cg.varName(e.Name)
+		}
 	case *parser.BinaryExpr:
 		cg.collectVarsFromExpr(e.Left)
 		cg.collectVarsFromExpr(e.Right)
 	case *parser.ComparisonExpr:
 		cg.collectVarsFromExpr(e.Left)
 		cg.collectVarsFromExpr(e.Right)
+	case *parser.UnaryExpr:
+		cg.collectVarsFromExpr(e.Operand)
 	}
 }
 
 func (cg *CodeGenerator) collectVarsFromStatement(stmt parser.Statement) {
 	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		// 常量只能在顶层声明，按顺序收集时用到的常量都已经确定
+		v, _ := parser.EvalConst(s.Value, cg.consts)
+		cg.consts[s.Name] = v
+		cg.constNames = append(cg.constNames, s.Name)
+	case *parser.VarDeclaration:
+		cg.varMap[s.Name] = cg.varName(s.Name)
+		if s.Value != nil {
+			cg.collectVarsFromExpr(s.Value)
+		}
 	case *parser.Assignment:
-		cg.varMap[s.Ident] = s.Ident
+		cg.varMap[s.Ident] = cg.varName(s.Ident)
 		cg.collectVarsFromExpr(s.Value)
+	case *parser.CompoundAssignment:
+		cg.varMap[s.Ident] = cg.varName(s.Ident)
+		cg.collectVarsFromExpr(s.Value)
+	case *parser.IncDecStatement:
+		cg.varMap[s.Ident] = cg.varName(s.Ident)
 	case *parser.PrintStatement:
 		cg.collectVarsFromExpr(s.Expr)
 	case *parser.InputStatement:
-		cg.varMap[s.Ident] = s.Ident
+		cg.varMap[s.Ident] = cg.varName(s.Ident)
+	case *parser.IfStatement:
+		cg.collectVarsFromExpr(s.Condition)
+		for _, stmt := range s.Then {
+			cg.collectVarsFromStatement(stmt)
+		}
+		for _, stmt := range s.Else {
+			cg.collectVarsFromStatement(stmt)
+		}
+	case *parser.WhileStatement:
+		cg.collectVarsFromExpr(s.Condition)
+		for _, stmt := range s.Body {
+			cg.collectVarsFromStatement(stmt)
+		}
+	case *parser.DoWhileStatement:
+		for _, stmt := range s.Body {
+			cg.collectVarsFromStatement(stmt)
+		}
+		cg.collectVarsFromExpr(s.Condition)
+	case *parser.SwitchStatement:
+		// 数据段在语句之前生成，这里顺便确定 switch 的标号和跳转表
+		cg.planSwitch(s)
+		cg.collectVarsFromExpr(s.Value)
+		for _, c := range s.Cases {
+			for _, stmt := range c.Body {
+				cg.collectVarsFromStatement(stmt)
+			}
+		}
+	case *parser.ForStatement:
+		if s.Init != nil {
+			cg.collectVarsFromStatement(s.Init)
+		}
+		if s.Condition != nil {
+			cg.collectVarsFromExpr(s.Condition)
+		}
+		if s.Step != nil {
+			cg.collectVarsFromStatement(s.Step)
+		}
+		for _, stmt := range s.Body {
+			cg.collectVarsFromStatement(stmt)
+		}
 	}
 }
 
 func (cg *CodeGenerator) genStatement(stmt parser.Statement) {
+	// 先占位，内层语句的映射排在外层之后
+	pos := stmt.Position()
+	index := len(cg.mappings)
+	cg.mappings = append(cg.mappings, backend.Mapping{AsmStart: len(cg.code) + 1, Line: pos.Line, Column: pos.Column})
+	cg.annotate(pos.Line)
+	defer func() {
+		cg.mappings[index].AsmEnd = len(cg.code)
+	}()
+
 	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		// 常量已在数据段中用 EQU 定义，不生成代码
+	case *parser.VarDeclaration:
+		cg.genAssignment(s.Desugar())
 	case *parser.Assignment:
 		cg.genAssignment(s)
+	case *parser.CompoundAssignment:
+		cg.genCompoundAssignment(s)
+	case *parser.IncDecStatement:
+		cg.genIncDec(s)
 	case *parser.PrintStatement:
 		cg.genPrint(s)
 	case *parser.InputStatement:
@@ -127,30 +262,156 @@ func (cg *CodeGenerator) genStatement(stmt parser.Statement) {
 		cg.genIf(s)
 	case *parser.WhileStatement:
 		cg.genWhile(s)
+	case *parser.DoWhileStatement:
+		cg.genDoWhile(s)
+	case *parser.ForStatement:
+		cg.genFor(s)
+	case *parser.SwitchStatement:
+		cg.genSwitch(s)
+	case *parser.BreakStatement:
+		cg.code = append(cg.code, fmt.Sprintf("    jmp %s", cg.loops[len(cg.loops)-1].breakLabel))
+	case *parser.ContinueStatement:
+		cg.code = append(cg.code, fmt.Sprintf("    jmp %s", cg.loops[len(cg.loops)-1].continueLabel))
+	}
+}
+
+// annotate 插入源代码第 line 行的内容作为注释
+func (cg *CodeGenerator) annotate(line int) {
+	if cg.source == nil || line < 1 || line > len(cg.source) {
+		return
 	}
+	text := strings.TrimSpace(strings.TrimSuffix(cg.source[line-1], "\r"))
+	cg.code = append(cg.code, fmt.Sprintf("    ; line %d: %s", line, text))
 }
 
 func (cg *CodeGenerator) genAssignment(a *parser.Assignment) {
+	if cg.types[a.Ident] == parser.TYPE_LONG {
+		cg.genLong(a.Value)
+		cg.code = append(cg.code,
+			fmt.Sprintf("    mov %s, ax", cg.longMem(a.Ident, 0)),
+			fmt.Sprintf("    mov %s, dx", cg.longMem(a.Ident, 2)),
+		)
+		return
+	}
 	cg.genExpr(a.Value, "ax")
-	cg.code = append(cg.code, fmt.Sprintf("    mov %s, ax", a.Ident))
+	if cg.types[a.Ident] == parser.TYPE_CHAR {
+		cg.code = append(cg.code, fmt.Sprintf("    mov %s, al", cg.mem(a.Ident)))
+		return
+	}
+	cg.code = append(cg.code, fmt.Sprintf("    mov %s, ax", cg.mem(a.Ident)))
+}
+
+// genCompoundAssignment 生成复合赋值。加减直接作用于内存中的变量，
+// 右侧是常量时用立即数；乘除需要借助 AX，计算完再写回。long 变量都按普通赋值计算
+func (cg *CodeGenerator) genCompoundAssignment(c *parser.CompoundAssignment) {
+	if cg.types[c.Ident] == parser.TYPE_LONG {
+		cg.genAssignment(c.Desugar())
+		return
+	}
+	switch c.Op {
+	case "+", "-":
+		instr := "add"
+		if c.Op == "-" {
+			instr = "sub"
+		}
+		if v, ok := cg.constOperand(c.Value); ok {
+			cg.code = append(cg.code, fmt.Sprintf("    %s %s, %s", instr, cg.wordMem(c.Ident), v))
+		} else {
+			cg.genExpr(c.Value, "ax")
+			cg.code = append(cg.code, fmt.Sprintf("    %s %s, ax", instr, cg.mem(c.Ident)))
+		}
+		cg.trapIf("jo", trapOverflow, c.Pos)
+	default:
+		cg.genAssignment(c.Desugar())
+	}
+}
+
+// genIncDec 生成自增自减，直接作用于内存中的变量。
+// long 变量的低字用 add/sub，进位或借位再加到高字上（inc/dec 不影响 CF）
+func (cg *CodeGenerator) genIncDec(i *parser.IncDecStatement) {
+	if cg.types[i.Ident] == parser.TYPE_LONG {
+		instr, carry := "add", "adc"
+		if i.Op == "--" {
+			instr, carry = "sub", "sbb"
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    %s %s, 1", instr, cg.longMem(i.Ident, 0)),
+			fmt.Sprintf("    %s %s, 0", carry, cg.longMem(i.Ident, 2)),
+		)
+		cg.trapIf("jo", trapOverflow, i.Pos)
+		return
+	}
+	instr := "inc"
+	if i.Op == "--" {
+		instr = "dec"
+	}
+	cg.code = append(cg.code, fmt.Sprintf("    %s %s", instr, cg.wordMem(i.Ident)))
+	cg.trapIf("jo", trapOverflow, i.Pos)
+}
+
+// constOperand 返回可以直接作为立即数的常量表达式的写法
+func (cg *CodeGenerator) constOperand(expr parser.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		return e.Value, true
+	case *parser.IdentExpr:
+		if _, ok := cg.consts[e.Name]; ok {
+			return cg.varName(e.Name), true
+		}
+	case *parser.BooleanExpr:
+		if e.Value {
+			return "1", true
+		}
+		return "0", true
+	case *parser.CharExpr:
+		return fmt.Sprint(e.Value), true
+	}
+	return "", false
 }
 
 func (cg *CodeGenerator) genPrint(p *parser.PrintStatement) {
-	cg.genExpr(p.Expr, "ax")
+	switch parser.TypeOf(p.Expr, cg.types) {
+	case parser.TYPE_LONG:
+		cg.genLong(p.Expr)
+		cg.code = append(cg.code, "    call print_long")
+	case parser.TYPE_CHAR:
+		cg.genExpr(p.Expr, "ax")
+		// 字符用 INT 21h/AH=2 直接输出
+		cg.code = append(cg.code,
+			"    mov dl, al",
+			"    mov ah, 2",
+			"    int 21h",
+		)
+	default:
+		cg.genExpr(p.Expr, "ax")
+		cg.code = append(cg.code, "    call print_number")
+	}
 	cg.code = append(cg.code,
-		"    push ax",
-		"    call print_number",
-		"    mov dx, offset newline",
+		"    mov dx, "+cg.offset("newline"),
 		"    mov ah, 9",
 		"    int 21h",
 	)
 }
 
 func (cg *CodeGenerator) genInput(i *parser.InputStatement) {
+	if cg.types[i.Ident] == parser.TYPE_LONG {
+		cg.code = append(cg.code, "    call read_long")
+		cg.trapIf("jc", trapInput, i.Pos)
+		cg.code = append(cg.code,
+			fmt.Sprintf("    mov %s, ax", cg.longMem(i.Ident, 0)),
+			fmt.Sprintf("    mov %s, dx", cg.longMem(i.Ident, 2)),
+			"    mov dx, "+cg.offset("newline"),
+			"    mov ah, 9",
+			"    int 21h",
+		)
+		return
+	}
+	// 检查溢出时 read_number 在输入超出 int 范围时置 CF
+	cg.code = append(cg.code, "    call read_number")
+	cg.trapIf("jc", trapInput, i.Pos)
 	cg.code = append(cg.code,
-		"    call read_number",
-		fmt.Sprintf("    mov %s, ax", i.Ident),
-		"    mov dx, offset newline",
+		fmt.Sprintf("    mov %s, ax", cg.mem(i.Ident)),
+		"    mov dx, "+cg.offset("newline"),
 		"    mov ah, 9",
 		"    int 21h",
 	)
@@ -194,22 +455,194 @@ func (cg *CodeGenerator) genWhile(w *parser.WhileStatement) {
 		fmt.Sprintf("    je %s", endLabel),
 	)
 
-	for _, stmt := range w.Body {
-		cg.genStatement(stmt)
+	cg.genLoopBody(w.Body, loopLabels{breakLabel: endLabel, continueLabel: startLabel})
+
+	cg.code = append(cg.code,
+		fmt.Sprintf("    jmp %s", startLabel),
+		fmt.Sprintf("%s:", endLabel),
+	)
+}
+
+// genDoWhile 生成 do-while 循环，条件在循环体之后检查，continue 跳到条件处
+func (cg *CodeGenerator) genDoWhile(d *parser.DoWhileStatement) {
+	startLabel := cg.newLabel()
+	condLabel := cg.newLabel()
+	endLabel := cg.newLabel()
+
+	cg.code = append(cg.code, fmt.Sprintf("%s:", startLabel))
+
+	cg.genLoopBody(d.Body, loopLabels{breakLabel: endLabel, continueLabel: condLabel})
+
+	cg.code = append(cg.code, fmt.Sprintf("%s:", condLabel))
+	cg.genExpr(d.Condition, "ax")
+	cg.code = append(cg.code,
+		"    cmp ax, 0",
+		fmt.Sprintf("    jne %s", startLabel),
+		fmt.Sprintf("%s:", endLabel),
+	)
+}
+
+func (cg *CodeGenerator) genFor(f *parser.ForStatement) {
+	// 初始化和步进部分与 for 在同一行，不单独插入源代码注释
+	if f.Init != nil {
+		cg.genSimpleStatement(f.Init)
+	}
+	startLabel := cg.newLabel()
+	stepLabel := cg.newLabel()
+	endLabel := cg.newLabel()
+
+	cg.code = append(cg.code, fmt.Sprintf("%s:", startLabel))
+	if f.Condition != nil {
+		cg.genExpr(f.Condition, "ax")
+		cg.code = append(cg.code,
+			"    cmp ax, 0",
+			fmt.Sprintf("    je %s", endLabel),
+		)
 	}
 
+	cg.genLoopBody(f.Body, loopLabels{breakLabel: endLabel, continueLabel: stepLabel})
+
+	cg.code = append(cg.code, fmt.Sprintf("%s:", stepLabel))
+	if f.Step != nil {
+		cg.genSimpleStatement(f.Step)
+	}
 	cg.code = append(cg.code,
 		fmt.Sprintf("    jmp %s", startLabel),
 		fmt.Sprintf("%s:", endLabel),
 	)
 }
 
+// planSwitch 为 switch 分配标号，case 值足够密集时改用跳转表
+func (cg *CodeGenerator) planSwitch(s *parser.SwitchStatement) {
+	plan := &switchPlan{}
+	values := make(map[int64]string)
+	var min, max int64
+	for _, c := range s.Cases {
+		label := cg.newLabel()
+		plan.labels = append(plan.labels, label)
+		if len(c.Values) == 0 {
+			plan.defaultLabel = label
+		}
+		for _, v := range c.Values {
+			value, _ := parser.EvalConst(v, cg.consts)
+			n := int64(value)
+			if len(values) == 0 || n < min {
+				min = n
+			}
+			if len(values) == 0 || n > max {
+				max = n
+			}
+			values[n] = label
+		}
+	}
+	plan.endLabel = cg.newLabel()
+	if plan.defaultLabel == "" {
+		plan.defaultLabel = plan.endLabel
+	}
+	cg.switches[s] = plan
+
+	size := max - min + 1
+	if len(values) < jumpTableMinCases || size > jumpTableMaxSize || size > 2*int64(len(values)) {
+		return
+	}
+	plan.table = fmt.Sprintf("jump_table_%d", len(cg.tables))
+	plan.min = min
+	for n := min; n <= max; n++ {
+		if label, ok := values[n]; ok {
+			plan.entries = append(plan.entries, label)
+		} else {
+			plan.entries = append(plan.entries, plan.defaultLabel)
+		}
+	}
+	cg.tables = append(cg.tables, plan)
+}
+
+// genSwitch 生成 switch：先按跳转表或比较链跳到对应分支，各分支执行完后跳到 switch 之后
+func (cg *CodeGenerator) genSwitch(s *parser.SwitchStatement) {
+	plan := cg.switches[s]
+	cg.genExpr(s.Value, "ax")
+	if plan.table != "" {
+		if plan.min != 0 {
+			cg.code = append(cg.code, fmt.Sprintf("    sub ax, %d", plan.min))
+		}
+		// 无符号比较同时排除了小于最小值（相减后为负）的情况
+		cg.code = append(cg.code,
+			fmt.Sprintf("    cmp ax, %d", len(plan.entries)-1),
+			fmt.Sprintf("    ja %s", plan.defaultLabel),
+			"    mov bx, ax",
+			"    shl bx, 1",
+			"    add bx, "+cg.offset(plan.table),
+			"    jmp "+cg.wordAt("bx"),
+		)
+	} else {
+		for i, c := range s.Cases {
+			for _, v := range c.Values {
+				n, _ := parser.EvalConst(v, cg.consts)
+				cg.code = append(cg.code,
+					fmt.Sprintf("    cmp ax, %d", n),
+					fmt.Sprintf("    je %s", plan.labels[i]),
+				)
+			}
+		}
+		cg.code = append(cg.code, fmt.Sprintf("    jmp %s", plan.defaultLabel))
+	}
+
+	// break 跳出 switch，continue 仍然作用于外层循环
+	labels := loopLabels{breakLabel: plan.endLabel}
+	if len(cg.loops) > 0 {
+		labels.continueLabel = cg.loops[len(cg.loops)-1].continueLabel
+	}
+	for i, c := range s.Cases {
+		cg.code = append(cg.code, fmt.Sprintf("%s:", plan.labels[i]))
+		cg.genLoopBody(c.Body, labels)
+		if i < len(s.Cases)-1 {
+			cg.code = append(cg.code, fmt.Sprintf("    jmp %s", plan.endLabel))
+		}
+	}
+	cg.code = append(cg.code, fmt.Sprintf("%s:", plan.endLabel))
+}
+
+// genLoopBody 生成循环体或 switch 的分支，其中的 break 和 continue 跳到 labels
+func (cg *CodeGenerator) genLoopBody(body []parser.Statement, labels loopLabels) {
+	cg.loops = append(cg.loops, labels)
+	for _, stmt := range body {
+		cg.genStatement(stmt)
+	}
+	cg.loops = cg.loops[:len(cg.loops)-1]
+}
+
+// genSimpleStatement 生成 for 的初始化或步进部分
+func (cg *CodeGenerator) genSimpleStatement(stmt parser.Statement) {
+	switch s := stmt.(type) {
+	case *parser.Assignment:
+		cg.genAssignment(s)
+	case *parser.CompoundAssignment:
+		cg.genCompoundAssignment(s)
+	case *parser.IncDecStatement:
+		cg.genIncDec(s)
+	}
+}
+
 func (cg *CodeGenerator) genExpr(expr parser.Expr, target string) {
 	switch e := expr.(type) {
 	case *parser.NumberExpr:
 		cg.code = append(cg.code, fmt.Sprintf("    mov %s, %s", target, e.Value))
 	case *parser.IdentExpr:
-		cg.code = append(cg.code, fmt.Sprintf("    mov %s, %s", target, e.Name))
+		if v, ok := cg.constOperand(e); ok {
+			cg.code = append(cg.code, fmt.Sprintf("    mov %s, %s", target, v))
+			break
+		}
+		if cg.types[e.Name] == parser.TYPE_CHAR {
+			// char 变量只占一个字节，读入 AL 后高位清零
+			cg.code = append(cg.code,
+				fmt.Sprintf("    mov al, %s", cg.mem(e.Name)),
+				"    mov ah, 0",
+			)
+			break
+		}
+		cg.code = append(cg.code, fmt.Sprintf("    mov %s, %s", target, cg.mem(e.Name)))
+	case *parser.CharExpr:
+		cg.code = append(cg.code, fmt.Sprintf("    mov %s, %d", target, e.Value))
 	case *parser.BooleanExpr:
 		if e.Value {
 			cg.code = append(cg.code, fmt.Sprintf("    mov %s, 1", target))
@@ -224,39 +657,49 @@ func (cg *CodeGenerator) genExpr(expr parser.Expr, target string) {
 		switch e.Op {
 		case "+":
 			cg.code = append(cg.code, "    add ax, bx")
+			cg.trapIf("jo", trapOverflow, e.Pos)
 		case "-":
-			cg.code = append(cg.code, "    sub bx, ax", "    mov ax, bx")
+			cg.code = append(cg.code, "    sub bx, ax")
+			cg.trapIf("jo", trapOverflow, e.Pos)
+			cg.code = append(cg.code, "    mov ax, bx")
 		case "*":
-			cg.code = append(cg.code, "    mul bx")
+			// 有符号乘法，积的低 16 位在 AX；高字 DX 不是 AX 的符号扩展时 IMUL 置 OF
+			cg.code = append(cg.code, "    imul bx")
+			cg.trapIf("jo", trapOverflow, e.Pos)
 		case "/":
-			cg.genExpr(e.Left, target)
-			cg.code = append(cg.code, "    push ax")
-			cg.genExpr(e.Right, "ax")
-			cg.code = append(cg.code, "    pop bx")
-
-			// 现在：AX = 右操作数 (除数), BX = 左操作数 (被除数)
-			// 我们想计算 BX / AX (被除数 / 除数)
-			// IDIV 指令期望被除数在 AX (或 DX:AX) 中，除数作为其操作数。
-			cg.code = append(cg.code,
-				"    mov cx, ax", // 将除数 (右操作数) 从 AX 移动到 CX
-				"    mov ax, bx", // 将被除数 (左操作数) 从 BX 移动到 AX
-			)
-
-			divOkLabel := cg.newLabel()
+			cg.genDivide(e.Op, e.Pos)
+		case "%":
+			// IDIV 的余数在 DX 中，符号与被除数相同
+			cg.genDivide(e.Op, e.Pos)
+			cg.code = append(cg.code, "    mov ax, dx")
+		case "&":
+			cg.code = append(cg.code, "    and ax, bx")
+		case "|":
+			cg.code = append(cg.code, "    or ax, bx")
+		case "^":
+			cg.code = append(cg.code, "    xor ax, bx")
+		case "<<", ">>":
+			// 移位次数只取低 4 位；右移是算术右移，保留符号位
+			instr := "shl"
+			if e.Op == ">>" {
+				instr = "sar"
+			}
 			cg.code = append(cg.code,
-				"    cmp cx, 0",    // 检查除数 (现在在 CX 中) 是否为0
-				fmt.Sprintf("    jne %s", divOkLabel),   // 如果不为0，继续执行
-				"    mov dx, offset msg_div_by_zero", // 除数为0，显示错误信息
-				"    mov ah, 9",
-				"    int 21h",
-				"    mov ah, 4Ch",  // 程序退出
-				"    int 21h",
-				fmt.Sprintf("%s:", divOkLabel),
-				"    cwd",          // 符号扩展 AX (被除数) 到 DX:AX
-				"    idiv cx",      // 有符号除法 DX:AX / CX (除数)
+				"    mov cx, ax",
+				"    and cx, 15",
+				"    mov ax, bx",
+				fmt.Sprintf("    %s ax, cl", instr),
 			)
 		}
+	case *parser.UnaryExpr:
+		cg.genExpr(e.Operand, target)
+		cg.code = append(cg.code, "    not ax")
 	case *parser.ComparisonExpr:
+		if cg.isLong(e.Left) || cg.isLong(e.Right) {
+			cg.genLongOperands(e.Left, e.Right)
+			cg.genLongCompare(e.Op)
+			break
+		}
 		cg.genExpr(e.Left, "ax")
 		cg.code = append(cg.code, "    push ax")
 		cg.genExpr(e.Right, "ax")
@@ -320,6 +763,52 @@ func (cg *CodeGenerator) genExpr(expr parser.Expr, target string) {
 	}
 }
 
+// genDivide 计算 BX / AX：商向零取整放在 AX 中，余数与被除数同号放在 DX 中，
+// 除数为 0 时输出错误信息并退出程序。检查溢出时运算符 op 为 / 的 -32768 / -1 报告 pos 所在行溢出，
+// -32768 % -1 的余数 0 没有溢出，不报告
+func (cg *CodeGenerator) genDivide(op string, pos parser.Pos) {
+	// 现在：AX = 右操作数 (除数), BX = 左操作数 (被除数)
+	// 我们想计算 BX / AX (被除数 / 除数)
+	// IDIV 指令期望被除数在 AX (或 DX:AX) 中，除数作为其操作数。
+	cg.code = append(cg.code,
+		"    mov cx, ax", // 将除数 (右操作数) 从 AX 移动到 CX
+		"    mov ax, bx", // 将被除数 (左操作数) 从 BX 移动到 AX
+	)
+
+	divOkLabel := cg.newLabel()
+	cg.code = append(cg.code,
+		"    cmp cx, 0",    // 检查除数 (现在在 CX 中) 是否为0
+		fmt.Sprintf("    jne %s", divOkLabel),   // 如果不为0，继续执行
+		"    mov dx, "+cg.offset("msg_div_by_zero"), // 除数为0，显示错误信息
+		"    mov ah, 9",
+		"    int 21h",
+		"    mov ah, 4Ch",  // 程序退出
+		"    int 21h",
+		fmt.Sprintf("%s:", divOkLabel),
+	)
+	// 除数为 -1 时直接取负：-32768 / -1 的商 32768 超出 16 位，IDIV 会触发除法错误中断，
+	// 这里按回绕得到 -32768，余数为 0
+	notMinusOneLabel := cg.newLabel()
+	endLabel := cg.newLabel()
+	cg.code = append(cg.code,
+		"    cmp cx, -1",
+		fmt.Sprintf("    jne %s", notMinusOneLabel),
+	)
+	if cg.trap && op == "/" {
+		cg.code = append(cg.code, "    cmp ax, -32768")
+		cg.trapIf("je", trapOverflow, pos)
+	}
+	cg.code = append(cg.code,
+		"    neg ax",
+		"    mov dx, 0",
+		fmt.Sprintf("    jmp %s", endLabel),
+		fmt.Sprintf("%s:", notMinusOneLabel),
+		"    cwd",          // 符号扩展 AX (被除数) 到 DX:AX
+		"    idiv cx",      // 有符号除法 DX:AX / CX (除数)
+		fmt.Sprintf("%s:", endLabel),
+	)
+}
+
 func (cg *CodeGenerator) newLabel() string {
 	label := fmt.Sprintf("label_%d", cg.labelCount)
 	cg.labelCount++
@@ -329,7 +818,7 @@ func (cg *CodeGenerator) newLabel() string {
 // AddHelperFunctions 添加辅助函数
 func (cg *CodeGenerator) AddHelperFunctions() {
 	cg.code = append(cg.code,
-		"print_number PROC",
+		cg.procBegin("print_number"),
 		"    push ax",
 		"    push bx",
 		"    push cx",
@@ -369,47 +858,105 @@ func (cg *CodeGenerator) AddHelperFunctions() {
 		"    pop bx",
 		"    pop ax",
 		"    ret",
-		"print_number ENDP",
+		cg.procEnd("print_number"),
 		"",
-		"read_number PROC",
+	)
+	cg.addReadNumber()
+}
+
+// addReadNumber 添加 read_number：读入一行，可以有一个前导的 '-' 或 '+'，其后只能是数字，
+// 结果放在 AX。输入为空、含有其他字符或超出 int 范围时提示重新输入；
+// 检查溢出时超出范围改为置 CF 返回，由调用处跳转报告错误，正常返回时 CF 为 0。
+// BX 累加绝对值，SI 为 1 表示负数，DI 记录状态：0 还没有字符，1 读入了符号，2 读入了数字，3 输入不合法
+func (cg *CodeGenerator) addReadNumber() {
+	// 超出范围时：不检查溢出则把这一行当作不合法的输入，检查溢出则报告错误
+	outOfRange, endOutOfRange := "read_number_invalid", "read_number_retry"
+	if cg.trap {
+		outOfRange, endOutOfRange = "read_number_overflow", "read_number_overflow"
+	}
+	cg.code = append(cg.code,
+		cg.procBegin("read_number"),
 		"    push bx",
 		"    push cx",
 		"    push dx",
+		"    push si",
+		"    push di",
+	"read_number_start:",
 		"    mov bx, 0",
 		"    mov cx, 0",
 		"    mov si, 0",
+		"    mov di, 0",
 	"read_number_loop:",
 		"    mov ah, 1",
 		"    int 21h",
 		"    cmp al, 13",
 		"    je read_number_done",
+		"    cmp di, 3",
+		"    je read_number_loop", // 已经不合法，读到行尾后重新输入
 		"    cmp al, '-'",
-		"    je handle_negative",
+		"    je read_number_sign",
+		"    cmp al, '+'",
+		"    je read_number_sign",
 		"    cmp al, '0'",
-		"    jb read_number_loop",
+		"    jb read_number_invalid",
 		"    cmp al, '9'",
-		"    ja read_number_loop",
+		"    ja read_number_invalid",
+		"    mov di, 2",
 		"    sub al, '0'",
 		"    mov cl, al",
 		"    mov ax, bx",
-		"    mov bx, 10",
-		"    mul bx",
+		"    mov dx, 10",
+		"    mul dx",
+		"    jc "+outOfRange,
+		"    add ax, cx",
+		"    jc "+outOfRange,
+		"    cmp ax, 32768", // 绝对值最大为 32768，正数读完后再检查
+		"    ja "+outOfRange,
 		"    mov bx, ax",
-		"    add bx, cx",
 		"    jmp read_number_loop",
-	"handle_negative:",
+	"read_number_sign:",
+		"    cmp di, 0",
+		"    jne read_number_invalid",
+		"    mov di, 1",
+		"    cmp al, '-'",
+		"    jne read_number_loop",
 		"    mov si, 1",
 		"    jmp read_number_loop",
+	"read_number_invalid:",
+		"    mov di, 3",
+		"    jmp read_number_loop",
 	"read_number_done:",
-		"    cmp si, 1",
-		"    jne not_negative",
-		"    neg bx",
-	"not_negative:",
+		"    cmp di, 2",
+		"    jne read_number_retry",
 		"    mov ax, bx",
+		"    cmp si, 1",
+		"    jne read_number_positive",
+		"    neg ax",
+		"    jmp read_number_ok",
+	"read_number_positive:",
+		"    cmp ax, 32768",
+		"    je "+endOutOfRange,
+	"read_number_ok:",
+		"    clc",
+	"read_number_return:",
+		"    pop di",
+		"    pop si",
 		"    pop dx",
 		"    pop cx",
 		"    pop bx",
 		"    ret",
-		"read_number ENDP",
+	"read_number_retry:",
+		"    mov dx, "+cg.offset("msg_invalid_number"),
+		"    mov ah, 9",
+		"    int 21h",
+		"    jmp read_number_start",
 	)
+	if cg.trap {
+		cg.code = append(cg.code,
+	"read_number_overflow:",
+			"    stc",
+			"    jmp read_number_return",
+		)
+	}
+	cg.code = append(cg.code, cg.procEnd("read_number"))
 }
@@ -0,0 +1,123 @@
+package codegen
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// retryPrompt 是 read_number 遇到不合法的输入时输出的提示（去掉回车）
+const retryPrompt = "\nInvalid number, try again: "
+
+// runNASM 用目标 b（NASM 方言的 COM 程序）编译 source，在 machine 中执行，返回输出和退出码。
+// 正常结束的程序只设置 AH=4Ch，退出码没有意义
+func runNASM(t *testing.T, b asmBackend, source, input string) (string, int) {
+	t.Helper()
+	ast, err := parser.NewParser(lexer.NewLexer(source)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.Generate(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newMachine(string(data), strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.run()
+	if err != nil {
+		t.Fatalf("运行出错：%v\n输出：\n%s", err, out)
+	}
+	return out, m.exit
+}
+
+// TestConformance 在 machine 中执行 code/conformance 中的程序生成的 8086 汇编，输出与 .out 比较，
+// 有 .in 文件时从它读取输入。比较前去掉重新输入的提示和空行（input 读完一行后换行）
+func TestConformance(t *testing.T) {
+	sources, err := filepath.Glob("../code/conformance/*.src")
+	if err != nil || len(sources) == 0 {
+		t.Fatalf("找不到一致性测试程序：%v", err)
+	}
+	nasm := asmBackend{dialect: DIALECT_NASM, format: FORMAT_COM}
+	for _, path := range sources {
+		base := strings.TrimSuffix(path, ".src")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(base + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, err := os.ReadFile(base + ".in")
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(err)
+			}
+			got, _ := runNASM(t, nasm, string(source), string(input))
+			var lines []string
+			for _, line := range strings.Split(strings.ReplaceAll(got, retryPrompt, ""), "\n") {
+				if line != "" {
+					lines = append(lines, line)
+				}
+			}
+			if got = strings.Join(lines, "\n") + "\n"; got != string(want) {
+				t.Errorf("输出\n%s\n期望\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestReadNumber 检查 read_number：只接受一个可选的前导符号后跟数字、范围为 -32768～32767 的数，
+// 其他输入提示重新输入。读完一行后输出换行
+func TestReadNumber(t *testing.T) {
+	retry := retryPrompt + "\n7\n"
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", retry},
+		{"-", retry},
+		{"+5", "\n5\n"},
+		{"1-2", retry},
+		{"--3", retry},
+		{"+-3", retry},
+		{"12a", retry},
+		{"32767", "\n32767\n"},
+		{"32768", retry},
+		{"65536", retry},
+		{"-32768", "\n-32768\n"},
+		{"-32769", retry},
+		{"-0", "\n0\n"},
+	}
+	nasm := asmBackend{dialect: DIALECT_NASM, format: FORMAT_COM}
+	for _, tt := range tests {
+		got, _ := runNASM(t, nasm, "int x; input x; print x;", tt.input+"\n7\n")
+		if got != tt.want {
+			t.Errorf("输入 %q：输出 %q，期望 %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestMultiply 检查乘法用有符号的 IMUL：积按补码回绕，检查溢出时积超出 int 的范围报告溢出
+func TestMultiply(t *testing.T) {
+	source := "int a = -32768; int b = -1; int c = -3; int d = 300;\n" +
+		"print c * 5; print c * c; print d * c; print a * 1;\n" +
+		"print a * b;\n" +
+		"print d * d;\n"
+	nasm := asmBackend{dialect: DIALECT_NASM, format: FORMAT_COM}
+	want := "-15\n9\n-900\n-32768\n-32768\n24464\n"
+	if got, _ := runNASM(t, nasm, source, ""); got != want {
+		t.Errorf("输出 %q，期望 %q", got, want)
+	}
+	nasm.trap = true
+	want = "-15\n9\n-900\n-32768\nError: Arithmetic overflow at line 3\n"
+	if got, code := runNASM(t, nasm, source, ""); got != want || code != 1 {
+		t.Errorf("检查溢出：输出 %q，退出码 %d，期望 %q", got, code, want)
+	}
+}
@@ -0,0 +1,371 @@
+package codegen
+
+import (
+	"compiler/msg"
+	"compiler/parser"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect 决定生成哪种汇编器的语法
+type Dialect int
+
+const (
+	DIALECT_EMU8086 Dialect = iota // emu8086，默认 COM 程序
+	DIALECT_MASM                   // MASM 6，默认 .model small 的 EXE 程序
+	DIALECT_TASM                   // TASM Ideal 模式，默认 EXE 程序
+	DIALECT_NASM                   // NASM，默认 bits 16 的 COM 程序
+)
+
+var dialectToString = map[Dialect]string{
+	DIALECT_EMU8086: "emu8086",
+	DIALECT_MASM:    "masm",
+	DIALECT_TASM:    "tasm",
+	DIALECT_NASM:    "nasm",
+}
+
+func (d Dialect) String() string {
+	return dialectToString[d]
+}
+
+// ParseDialect 根据名字查找方言
+func ParseDialect(name string) (Dialect, error) {
+	for d, s := range dialectToString {
+		if s == name {
+			return d, nil
+		}
+	}
+	return 0, msg.Errorf(msg.GEN_UNKNOWN_DIALECT, name)
+}
+
+// Format 决定生成 COM 还是 EXE 程序
+type Format int
+
+const (
+	FORMAT_DEFAULT Format = iota // 使用方言的默认格式
+	FORMAT_COM                   // 单段 COM 程序，CS=DS=SS，从 100h 开始
+	FORMAT_EXE                   // 代码、数据、栈分段的 EXE（MZ）程序
+)
+
+var formatToString = map[Format]string{
+	FORMAT_DEFAULT: "default",
+	FORMAT_COM:     "com",
+	FORMAT_EXE:     "exe",
+}
+
+func (f Format) String() string {
+	return formatToString[f]
+}
+
+// DefaultFormat 返回方言的默认输出格式
+func (d Dialect) DefaultFormat() Format {
+	switch d {
+	case DIALECT_MASM, DIALECT_TASM:
+		return FORMAT_EXE
+	}
+	return FORMAT_COM
+}
+
+// genHeader 生成程序头，随后紧跟数据定义
+func (cg *CodeGenerator) genHeader() {
+	if cg.format == FORMAT_COM {
+		// COM 程序只有一个段，数据放在开头的 jmp 之后
+		switch cg.dialect {
+		case DIALECT_EMU8086:
+			cg.code = append(cg.code,
+				"#make_COM#",
+				"ORG 100h",
+				"",
+				"jmp main_start", // Jump over data and procedures
+				"",
+			)
+		case DIALECT_MASM:
+			cg.code = append(cg.code,
+				".model tiny",
+				".code",
+				"org 100h",
+				"",
+				"entry:",
+				"    jmp main_start",
+				"",
+			)
+		case DIALECT_TASM:
+			cg.code = append(cg.code,
+				"IDEAL",
+				"MODEL tiny",
+				"CODESEG",
+				"ORG 100h",
+				"",
+				"entry:",
+				"    jmp main_start",
+				"",
+			)
+		case DIALECT_NASM:
+			cg.code = append(cg.code,
+				"bits 16",
+				"org 100h",
+				"",
+				"section .text",
+				"    jmp main_start", // Jump over procedures
+				"",
+				"section .data",
+			)
+		}
+		return
+	}
+
+	switch cg.dialect {
+	case DIALECT_EMU8086:
+		cg.code = append(cg.code,
+			"#make_EXE#",
+			"",
+			"data segment",
+		)
+	case DIALECT_MASM:
+		cg.code = append(cg.code,
+			".model small",
+			".stack 100h",
+			"",
+			".data",
+		)
+	case DIALECT_TASM:
+		cg.code = append(cg.code,
+			"IDEAL",
+			"MODEL small",
+			"STACK 100h",
+			"",
+			"DATASEG",
+		)
+	case DIALECT_NASM:
+		// 使用 nasm -f obj 生成 OMF 目标文件，再由链接器生成 MZ 程序
+		cg.code = append(cg.code,
+			"bits 16",
+			"",
+			"segment data",
+		)
+	}
+}
+
+// genData 生成消息字符串、常量和变量定义
+func (cg *CodeGenerator) genData() {
+	cg.code = append(cg.code,
+		"    msg_div_by_zero db 'Error: Division by zero!$'",
+		"    newline db 13, 10, '$'",
+		"    msg_invalid_number db 13, 10, 'Invalid number, try again: $'",
+	)
+	cg.genTrapData()
+
+	// 常量按声明顺序用 EQU 定义，值已在编译期算出
+	for _, name := range cg.constNames {
+		cg.code = append(cg.code, fmt.Sprintf("    %s equ %d", cg.varName(name), cg.consts[name]))
+	}
+
+	// 变量按名字排序，保证输出稳定
+	names := make([]string, 0, len(cg.varMap))
+	for name := range cg.varMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch cg.types[name] {
+		case parser.TYPE_CHAR:
+			cg.code = append(cg.code, fmt.Sprintf("    %s db 0", cg.varMap[name]))
+		case parser.TYPE_LONG:
+			cg.code = append(cg.code, fmt.Sprintf("    %s dd 0", cg.varMap[name]))
+		default:
+			cg.code = append(cg.code, fmt.Sprintf("    %s dw 0", cg.varMap[name]))
+		}
+	}
+	// switch 的跳转表，每行最多 8 项
+	for _, plan := range cg.tables {
+		for i := 0; i < len(plan.entries); i += 8 {
+			end := i + 8
+			if end > len(plan.entries) {
+				end = len(plan.entries)
+			}
+			name := strings.Repeat(" ", len(plan.table))
+			if i == 0 {
+				name = plan.table
+			}
+			cg.code = append(cg.code, fmt.Sprintf("    %s dw %s", name, strings.Join(plan.entries[i:end], ", ")))
+		}
+	}
+	cg.code = append(cg.code, "")
+}
+
+// genCodeSegment 结束数据段，定义栈段（EXE）并开始代码段
+func (cg *CodeGenerator) genCodeSegment() {
+	if cg.format == FORMAT_COM {
+		if cg.dialect == DIALECT_NASM {
+			cg.code = append(cg.code, "section .text")
+		}
+		return
+	}
+
+	switch cg.dialect {
+	case DIALECT_EMU8086:
+		cg.code = append(cg.code,
+			"ends",
+			"",
+			"stack segment",
+			"    dw 128 dup(0)",
+			"ends",
+			"",
+			"code segment",
+		)
+	case DIALECT_MASM:
+		cg.code = append(cg.code, ".code")
+	case DIALECT_TASM:
+		cg.code = append(cg.code, "CODESEG")
+	case DIALECT_NASM:
+		cg.code = append(cg.code,
+			"segment stack stack",
+			"    resb 256",
+			"stacktop:",
+			"",
+			"segment code",
+		)
+	}
+}
+
+// genEntry 生成程序入口。COM 程序的段寄存器已由 DOS 设置好，
+// EXE 程序需要自己把 DS（以及 NASM 下的 SS:SP）指向对应的段。
+func (cg *CodeGenerator) genEntry() {
+	if cg.format == FORMAT_EXE && cg.dialect == DIALECT_NASM {
+		cg.code = append(cg.code, "..start:")
+	}
+	cg.code = append(cg.code, "main_start:") // Actual start of the main program logic
+	if cg.format == FORMAT_COM {
+		return
+	}
+
+	switch cg.dialect {
+	case DIALECT_EMU8086:
+		cg.code = append(cg.code,
+			"    mov ax, data",
+			"    mov ds, ax",
+			"    mov es, ax",
+		)
+	case DIALECT_MASM, DIALECT_TASM:
+		cg.code = append(cg.code,
+			"    mov ax, @data",
+			"    mov ds, ax",
+		)
+	case DIALECT_NASM:
+		cg.code = append(cg.code,
+			"    mov ax, data",
+			"    mov ds, ax",
+			"    mov ax, stack",
+			"    mov ss, ax",
+			"    mov sp, stacktop",
+		)
+	}
+}
+
+// genExit 生成程序退出代码和结束伪指令
+func (cg *CodeGenerator) genExit() {
+	cg.code = append(cg.code,
+		"    mov ah, 4Ch", // Program exit
+		"    int 21h",
+	)
+	cg.genTraps()
+	switch cg.dialect {
+	case DIALECT_EMU8086:
+		if cg.format == FORMAT_EXE {
+			cg.code = append(cg.code, "ends", "", "end main_start")
+		}
+	case DIALECT_MASM, DIALECT_TASM:
+		if cg.format == FORMAT_COM {
+			cg.code = append(cg.code, "END entry")
+		} else {
+			cg.code = append(cg.code, "END main_start")
+		}
+	}
+}
+
+// varName 返回变量在汇编中的符号名。
+// MASM/TASM/NASM 中 c、ax 等短名字是保留字，统一加前缀避免冲突。
+func (cg *CodeGenerator) varName(name string) string {
+	if cg.dialect == DIALECT_EMU8086 {
+		return name
+	}
+	return "v_" + name
+}
+
+// mem 返回变量的内存操作数写法
+func (cg *CodeGenerator) mem(name string) string {
+	switch cg.dialect {
+	case DIALECT_TASM, DIALECT_NASM:
+		return "[" + cg.varMap[name] + "]"
+	}
+	return cg.varMap[name]
+}
+
+// wordMem 返回带字长说明的变量内存操作数写法，用于 inc、add 立即数等无法从寄存器推断字长的指令。
+// TASM 的 IDEAL 模式按变量定义推断字长，不需要说明。
+func (cg *CodeGenerator) wordMem(name string) string {
+	switch cg.dialect {
+	case DIALECT_NASM:
+		return "word [" + cg.varMap[name] + "]"
+	case DIALECT_TASM:
+		return cg.mem(name)
+	}
+	return "word ptr " + cg.varMap[name]
+}
+
+// longMem 返回 long 变量低字（offset 为 0）或高字（offset 为 2）的内存操作数写法。
+// 变量按 dd 定义，所有方言都要写明按字访问
+func (cg *CodeGenerator) longMem(name string, offset int) string {
+	sym := cg.varMap[name]
+	if offset != 0 {
+		sym += fmt.Sprintf("+%d", offset)
+	}
+	switch cg.dialect {
+	case DIALECT_NASM:
+		return "word [" + sym + "]"
+	case DIALECT_TASM:
+		return "[word " + sym + "]"
+	}
+	return "word ptr " + sym
+}
+
+// wordAt 返回寄存器 reg 所指的字的内存操作数写法，如跳转表中的 jmp word ptr [bx]。
+// 不写字长时 MASM 和 TASM 无法确定间接跳转的形式
+func (cg *CodeGenerator) wordAt(reg string) string {
+	switch cg.dialect {
+	case DIALECT_NASM:
+		return "word [" + reg + "]"
+	case DIALECT_TASM:
+		return "[word " + reg + "]"
+	}
+	return "word ptr [" + reg + "]"
+}
+
+// offset 返回标号地址的立即数写法
+func (cg *CodeGenerator) offset(label string) string {
+	if cg.dialect == DIALECT_NASM {
+		return label
+	}
+	return "offset " + label
+}
+
+func (cg *CodeGenerator) procBegin(name string) string {
+	switch cg.dialect {
+	case DIALECT_TASM:
+		return "PROC " + name
+	case DIALECT_NASM:
+		return name + ":"
+	}
+	return name + " PROC"
+}
+
+func (cg *CodeGenerator) procEnd(name string) string {
+	switch cg.dialect {
+	case DIALECT_TASM:
+		return "ENDP " + name
+	case DIALECT_NASM:
+		return "; end of " + name
+	}
+	return name + " ENDP"
+}
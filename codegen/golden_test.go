@@ -0,0 +1,85 @@
+package codegen
+
+import (
+	"bytes"
+	"compiler/lexer"
+	"compiler/parser"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "用当前输出重写 testdata 中的 golden 文件")
+
+// TestGolden 把 code/ 下的全部示例按每种方言和格式生成汇编，与 testdata/<方言>-<格式>.golden 比较；
+// 每种方言还按默认格式生成检查溢出的代码，与 testdata/<方言>-<格式>-trap.golden 比较。
+// 修改代码生成后用 go test ./codegen -update 重新生成 golden 文件并检查差异。
+func TestGolden(t *testing.T) {
+	samples, err := filepath.Glob("../code/*.src")
+	if err != nil || len(samples) == 0 {
+		t.Fatalf("找不到示例程序：%v", err)
+	}
+	for _, d := range []Dialect{DIALECT_EMU8086, DIALECT_MASM, DIALECT_TASM, DIALECT_NASM} {
+		backends := []asmBackend{
+			{dialect: d, format: FORMAT_COM},
+			{dialect: d, format: FORMAT_EXE},
+			{dialect: d, format: d.DefaultFormat(), trap: true},
+		}
+		for _, b := range backends {
+			name := d.String() + "-" + b.format.String()
+			if b.trap {
+				name += "-trap"
+			}
+			golden := filepath.Join("testdata", name+".golden")
+			t.Run(name, func(t *testing.T) {
+				var got bytes.Buffer
+				for _, sample := range samples {
+					fmt.Fprintf(&got, "; ==== %s ====\n", filepath.Base(sample))
+					got.Write(generate(t, b, sample))
+				}
+				compareGolden(t, golden, got.Bytes())
+			})
+		}
+	}
+}
+
+// generate 解析示例程序并用目标 b 生成代码
+func generate(t *testing.T, b asmBackend, path string) []byte {
+	t.Helper()
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.NewParser(lexer.NewLexer(string(source))).Parse()
+	if err != nil {
+		t.Fatalf("%s：%v", path, err)
+	}
+	data, err := b.Generate(ast)
+	if err != nil {
+		t.Fatalf("%s：%v", path, err)
+	}
+	return data
+}
+
+// compareGolden 比较输出与 golden 文件，-update 时改为写入 golden 文件
+func compareGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v（用 -update 生成 golden 文件）", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("输出与 %s 不同，检查改动后用 -update 更新", golden)
+	}
+}
@@ -0,0 +1,175 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 8086 的条件跳转只有 8 位的位移，只能跳到 -128～+127 字节之内。
+
+// inverseJumps 是每种条件跳转的反向条件
+var inverseJumps = map[string]string{
+	"je": "jne", "jne": "je", "jz": "jnz", "jnz": "jz",
+	"jg": "jle", "jle": "jg", "jge": "jl", "jl": "jge",
+	"ja": "jbe", "jbe": "ja", "jae": "jb", "jb": "jae",
+	"jc": "jnc", "jnc": "jc", "jo": "jno", "jno": "jo",
+	"js": "jns", "jns": "js",
+}
+
+// jumpAround 返回条件 jump 成立时跳转到任意远处的 label 的代码：
+// 用反向的条件跳转越过一条可以跳到段内任意位置的 jmp
+func (cg *CodeGenerator) jumpAround(jump, label string) []string {
+	skip := cg.newLabel()
+	return []string{
+		fmt.Sprintf("    %s %s", inverseJumps[jump], skip),
+		fmt.Sprintf("    jmp %s", label),
+		skip + ":",
+	}
+}
+
+// 生成代码时不知道指令的长度，用每行指令长度的上限估计跳转距离：
+// 8086 一条指令（不带前缀）最长 6 字节，操作数只有寄存器和数的指令（如 mov ax, 1234）最长 4 字节，
+// 跳到标号的 jmp 和 call 最长 3 字节。留下来的条件跳转都能跳到目标，是 2 字节的短跳转
+const (
+	maxInstructionSize = 6
+	maxRegisterSize    = 4
+	maxNearJumpSize    = 3
+	shortJumpSize      = 2
+)
+
+var registers = map[string]bool{
+	"ax": true, "bx": true, "cx": true, "dx": true, "si": true, "di": true, "bp": true, "sp": true,
+	"al": true, "ah": true, "bl": true, "bh": true, "cl": true, "ch": true, "dl": true, "dh": true,
+	"ds": true, "es": true, "ss": true,
+}
+
+// relaxJumps 把可能跳不到目标的条件跳转改为 jumpAround。按 maxLineSize 估计跳转距离的上限，
+// 改写使代码变长，可能让别的跳转超出范围，所以重复到没有需要改写的跳转为止。
+// loop 和 jcxz 没有反向的条件，只用在距离很短的辅助函数中，不改写
+func (cg *CodeGenerator) relaxJumps() {
+	for {
+		labels := make(map[string]int)
+		offsets := make([]int, len(cg.code)+1) // offsets[i] 是第 i 行之前的代码长度的上限
+		for i, line := range cg.code {
+			offsets[i+1] = offsets[i] + maxLineSize(line)
+			if name, ok := labelOf(line); ok {
+				labels[name] = i
+			}
+		}
+		changed := false
+		// 从后往前改写，插入的行不影响前面的下标
+		for i := len(cg.code) - 1; i >= 0; i-- {
+			jump, target, ok := conditionalJump(cg.code[i])
+			if _, invertible := inverseJumps[jump]; !ok || !invertible {
+				continue
+			}
+			if j, ok := labels[target]; !ok || inShortRange(offsets, i, j) {
+				continue
+			}
+			cg.replaceLine(i, cg.jumpAround(jump, target))
+			changed = true
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// inShortRange 报告第 i 行的 2 字节跳转指令是否一定能跳到第 j 行。位移从跳转指令之后算起
+func inShortRange(offsets []int, i, j int) bool {
+	if j > i {
+		return offsets[j]-offsets[i+1] <= 127
+	}
+	return offsets[i+1]-offsets[j] <= 128
+}
+
+// replaceLine 把第 i 行换成 lines，并移动源码映射中之后的行号
+func (cg *CodeGenerator) replaceLine(i int, lines []string) {
+	extra := len(lines) - 1
+	code := make([]string, 0, len(cg.code)+extra)
+	code = append(code, cg.code[:i]...)
+	code = append(code, lines...)
+	cg.code = append(code, cg.code[i+1:]...)
+	// 映射的行号从 1 开始，第 i 行是第 i+1 行
+	for k := range cg.mappings {
+		if cg.mappings[k].AsmStart > i+1 {
+			cg.mappings[k].AsmStart += extra
+		}
+		if cg.mappings[k].AsmEnd >= i+1 {
+			cg.mappings[k].AsmEnd += extra
+		}
+	}
+}
+
+// maxLineSize 返回一行汇编生成的字节数的上限：标号、注释和空行为 0，
+// 数据定义不超过它的字符数（dup 和 resb 按整个段计算），其余的行按一条指令计算
+func maxLineSize(line string) int {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], ";") {
+		return 0
+	}
+	if _, ok := labelOf(line); ok {
+		return 0
+	}
+	for _, f := range fields {
+		switch strings.ToLower(f) {
+		case "db", "dw", "dd":
+			if strings.Contains(line, "dup") {
+				return 1 << 16
+			}
+			return len(line)
+		case "resb":
+			return 1 << 16
+		}
+	}
+	if _, _, ok := conditionalJump(line); ok {
+		return shortJumpSize
+	}
+	if len(fields) == 2 && (fields[0] == "jmp" || fields[0] == "call") && isLabel(fields[1]) {
+		return maxNearJumpSize
+	}
+	// MASM 和 emu8086 中不带方括号的变量名也是内存操作数，所以只认寄存器和数
+	for _, operand := range strings.Split(strings.Join(fields[1:], " "), ",") {
+		operand = strings.TrimSpace(operand)
+		if operand != "" && !registers[operand] && !isNumber(operand) {
+			return maxInstructionSize
+		}
+	}
+	return maxRegisterSize
+}
+
+// isNumber 报告操作数是否为十进制数、h 结尾的十六进制数或 '0' 形式的字符
+func isNumber(operand string) bool {
+	if len(operand) == 3 && operand[0] == '\'' && operand[2] == '\'' {
+		return true
+	}
+	s := strings.TrimPrefix(operand, "-")
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return false
+	}
+	s = strings.TrimSuffix(strings.ToLower(s), "h")
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// isLabel 报告跳转的操作数是否为标号（而不是 word ptr [bx] 这样的内存操作数）
+func isLabel(operand string) bool {
+	return !strings.ContainsAny(operand, "[]")
+}
+
+// labelOf 返回 "name:" 形式的标号行定义的标号
+func labelOf(line string) (string, bool) {
+	s := strings.TrimSpace(line)
+	if !strings.HasSuffix(s, ":") || strings.ContainsAny(s, " \t;'") {
+		return "", false
+	}
+	return strings.TrimSuffix(s, ":"), true
+}
+
+// conditionalJump 拆开 "    jne label" 形式的只能短跳转的指令（条件跳转、loop 和 jcxz）
+func conditionalJump(line string) (jump, target string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] == "jmp" || !strings.HasPrefix(fields[0], "j") && fields[0] != "loop" {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
@@ -0,0 +1,99 @@
+package codegen
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJumpRange 检查全部示例在每种方言、格式和溢出处理方式下生成的代码中，
+// 条件跳转、loop 和 jcxz 按 maxLineSize 估计的距离都在 8 位位移的范围（-128～+127 字节）之内。
+// 超出范围时 TASM 报错，NASM 会生成 386 才有的 16 位位移的条件跳转
+func TestJumpRange(t *testing.T) {
+	samples, err := filepath.Glob("../code/*.src")
+	if err != nil || len(samples) == 0 {
+		t.Fatalf("找不到示例程序：%v", err)
+	}
+	for _, d := range []Dialect{DIALECT_EMU8086, DIALECT_MASM, DIALECT_TASM, DIALECT_NASM} {
+		for _, f := range []Format{FORMAT_COM, FORMAT_EXE} {
+			for _, trap := range []bool{false, true} {
+				b := asmBackend{dialect: d, format: f, trap: trap}
+				for _, sample := range samples {
+					lines := strings.Split(string(generate(t, b, sample)), "\n")
+					name := fmt.Sprintf("%s-%s trap=%v %s", d, f, trap, filepath.Base(sample))
+					checkJumpRange(t, name, lines)
+				}
+			}
+		}
+	}
+}
+
+// checkJumpRange 检查 lines 中每条只能短跳转的指令都能跳到目标
+func checkJumpRange(t *testing.T, name string, lines []string) {
+	t.Helper()
+	labels := make(map[string]int)
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + maxLineSize(line)
+		if label, ok := labelOf(line); ok {
+			labels[label] = i
+		}
+	}
+	for i, line := range lines {
+		_, target, ok := conditionalJump(line)
+		if !ok {
+			continue
+		}
+		j, ok := labels[target]
+		if !ok {
+			t.Errorf("%s：第 %d 行 %q 跳转到未定义的标号", name, i+1, strings.TrimSpace(line))
+			continue
+		}
+		// 位移从跳转指令之后算起，向后跳转时为负
+		distance := offsets[j] - offsets[i+1]
+		if distance > 127 || distance < -128 {
+			t.Errorf("%s：第 %d 行 %q 到目标的距离可能达到 %d 字节", name, i+1, strings.TrimSpace(line), distance)
+		}
+	}
+}
+
+// TestRelaxJumps 检查循环体很长时循环条件的跳转改为越过 jmp，源码映射中的行号随之移动
+func TestRelaxJumps(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("int x = 0;\nwhile (x < 10) {\n")
+	for i := 0; i < 30; i++ {
+		src.WriteString("    x = x + 1;\n")
+	}
+	src.WriteString("}\nprint x;\n")
+	ast, err := parser.NewParser(lexer.NewLexer(src.String())).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg := NewCodeGeneratorWithOptions(Options{Dialect: DIALECT_NASM, Source: src.String()})
+	lines := cg.Generate(ast)
+
+	relaxed := false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "cmp ax, 0" && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "jne ") &&
+			strings.HasPrefix(strings.TrimSpace(lines[i+2]), "jmp ") {
+			relaxed = true
+		}
+	}
+	if !relaxed {
+		t.Errorf("循环条件的跳转没有改为越过 jmp：\n%s", strings.Join(lines, "\n"))
+	}
+	checkJumpRange(t, "while", lines)
+
+	for _, m := range cg.SourceMap() {
+		want := fmt.Sprintf("; line %d:", m.Line)
+		if got := strings.TrimSpace(lines[m.AsmStart-1]); !strings.HasPrefix(got, want) {
+			t.Errorf("第 %d 行语句的映射从 %q 开始，期望 %q", m.Line, got, want)
+		}
+		if m.AsmEnd < m.AsmStart || m.AsmEnd > len(lines) {
+			t.Errorf("第 %d 行语句的映射范围 %d-%d 不合法", m.Line, m.AsmStart, m.AsmEnd)
+		}
+	}
+}
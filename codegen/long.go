@@ -0,0 +1,480 @@
+package codegen
+
+import (
+	"compiler/parser"
+	"fmt"
+	"strconv"
+)
+
+// long 是 32 位有符号整数，计算时放在 DX:AX 中（DX 为高字），
+// 二元运算的左操作数放在 CX:BX 中。int 类型的子表达式按 16 位计算后用 cwd 符号扩展。
+
+// isLong 判断表达式是否是 long 类型
+func (cg *CodeGenerator) isLong(expr parser.Expr) bool {
+	return parser.TypeOf(expr, cg.types) == parser.TYPE_LONG
+}
+
+// genLong 计算表达式，结果按 long 放在 DX:AX
+func (cg *CodeGenerator) genLong(expr parser.Expr) {
+	if !cg.isLong(expr) {
+		cg.genExpr(expr, "ax")
+		cg.code = append(cg.code, "    cwd")
+		return
+	}
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		n, _ := strconv.ParseInt(e.Value, 10, 32)
+		cg.code = append(cg.code,
+			fmt.Sprintf("    mov ax, %d", uint16(n)),
+			fmt.Sprintf("    mov dx, %d", uint16(n>>16)),
+		)
+	case *parser.IdentExpr:
+		cg.code = append(cg.code,
+			fmt.Sprintf("    mov ax, %s", cg.longMem(e.Name, 0)),
+			fmt.Sprintf("    mov dx, %s", cg.longMem(e.Name, 2)),
+		)
+	case *parser.UnaryExpr:
+		cg.genLong(e.Operand)
+		cg.code = append(cg.code, "    not ax", "    not dx")
+	case *parser.BinaryExpr:
+		cg.genLongOperands(e.Left, e.Right)
+		switch e.Op {
+		case "+":
+			cg.code = append(cg.code, "    add ax, bx", "    adc dx, cx")
+			cg.trapIf("jo", trapOverflow, e.Pos)
+		case "-":
+			cg.code = append(cg.code, "    sub bx, ax", "    sbb cx, dx")
+			cg.trapIf("jo", trapOverflow, e.Pos)
+			cg.code = append(cg.code, "    mov ax, bx", "    mov dx, cx")
+		case "*":
+			cg.code = append(cg.code, "    call long_mul")
+			cg.trapIf("jc", trapOverflow, e.Pos)
+		case "/":
+			cg.code = append(cg.code, "    call long_div")
+			cg.trapIf("jc", trapOverflow, e.Pos)
+		case "%":
+			cg.code = append(cg.code,
+				"    call long_div",
+				"    mov ax, bx",
+				"    mov dx, cx",
+			)
+		case "&", "|", "^":
+			instr := map[string]string{"&": "and", "|": "or", "^": "xor"}[e.Op]
+			cg.code = append(cg.code,
+				fmt.Sprintf("    %s ax, bx", instr),
+				fmt.Sprintf("    %s dx, cx", instr),
+			)
+		case "<<", ">>":
+			// 移位次数只取低 5 位，逐位移动两个字；右移是算术右移
+			first, second := "shl ax, 1", "rcl dx, 1"
+			if e.Op == ">>" {
+				first, second = "sar dx, 1", "rcr ax, 1"
+			}
+			loopLabel := cg.newLabel()
+			endLabel := cg.newLabel()
+			cg.code = append(cg.code,
+				"    and ax, 31",
+				"    xchg ax, cx",
+				"    mov dx, ax",
+				"    mov ax, bx",
+				fmt.Sprintf("    jcxz %s", endLabel),
+				fmt.Sprintf("%s:", loopLabel),
+				"    "+first,
+				"    "+second,
+				fmt.Sprintf("    loop %s", loopLabel),
+				fmt.Sprintf("%s:", endLabel),
+			)
+		}
+	}
+}
+
+// genLongOperands 按 long 计算两个操作数：左操作数放入 CX:BX，右操作数放入 DX:AX
+func (cg *CodeGenerator) genLongOperands(left, right parser.Expr) {
+	cg.genLong(left)
+	cg.code = append(cg.code, "    push dx", "    push ax")
+	cg.genLong(right)
+	cg.code = append(cg.code, "    pop bx", "    pop cx")
+}
+
+// longCompareResults 是比较运算在左操作数小于、等于、大于右操作数时的结果
+var longCompareResults = map[string][3]int{
+	"==": {0, 1, 0},
+	"!=": {1, 0, 1},
+	"<":  {1, 0, 0},
+	">":  {0, 0, 1},
+	"<=": {1, 1, 0},
+	">=": {0, 1, 1},
+}
+
+// genLongCompare 比较 CX:BX 和 DX:AX，结果 1 或 0 放在 AX。
+// 先按有符号数比较高字，高字相等时再按无符号数比较低字
+func (cg *CodeGenerator) genLongCompare(op string) {
+	results := longCompareResults[op]
+	lessLabel := cg.newLabel()
+	greaterLabel := cg.newLabel()
+	endLabel := cg.newLabel()
+	cg.code = append(cg.code,
+		"    cmp cx, dx",
+		fmt.Sprintf("    jl %s", lessLabel),
+		fmt.Sprintf("    jg %s", greaterLabel),
+		"    cmp bx, ax",
+		fmt.Sprintf("    jb %s", lessLabel),
+		fmt.Sprintf("    ja %s", greaterLabel),
+		fmt.Sprintf("    mov ax, %d", results[1]),
+		fmt.Sprintf("    jmp %s", endLabel),
+		fmt.Sprintf("%s:", lessLabel),
+		fmt.Sprintf("    mov ax, %d", results[0]),
+		fmt.Sprintf("    jmp %s", endLabel),
+		fmt.Sprintf("%s:", greaterLabel),
+		fmt.Sprintf("    mov ax, %d", results[2]),
+		fmt.Sprintf("%s:", endLabel),
+	)
+}
+
+// addLongHelpers 添加 long 运算用到的辅助函数，只在程序用到 long 时生成
+func (cg *CodeGenerator) addLongHelpers() {
+	cg.code = append(cg.code,
+		// print_long 输出 DX:AX 中的有符号整数：每次把 DI:SI 除以 10，
+		// 先除高字，余数和低字一起再除，余数就是最低位的数字
+		cg.procBegin("print_long"),
+		"    push ax",
+		"    push bx",
+		"    push cx",
+		"    push dx",
+		"    push si",
+		"    push di",
+		"    mov si, ax",
+		"    mov di, dx",
+		"    test di, di",
+		"    jns print_long_positive",
+		"    mov ah, 2",
+		"    mov dl, '-'",
+		"    int 21h",
+		"    neg di",
+		"    neg si",
+		"    sbb di, 0",
+		"print_long_positive:",
+		"    mov bx, 10",
+		"    mov cx, 0",
+		"print_long_loop:",
+		"    mov dx, 0",
+		"    mov ax, di",
+		"    div bx",
+		"    mov di, ax",
+		"    mov ax, si",
+		"    div bx",
+		"    mov si, ax",
+		"    push dx",
+		"    inc cx",
+		"    or ax, di",
+		"    jnz print_long_loop",
+		"print_long_output:",
+		"    pop dx",
+		"    add dl, '0'",
+		"    mov ah, 2",
+		"    int 21h",
+		"    loop print_long_output",
+		"    pop di",
+		"    pop si",
+		"    pop dx",
+		"    pop cx",
+		"    pop bx",
+		"    pop ax",
+		"    ret",
+		cg.procEnd("print_long"),
+		"",
+	)
+	cg.addReadLong()
+	if cg.trap {
+		cg.addCheckedLongMul()
+	} else {
+		cg.code = append(cg.code,
+			// long_mul 计算 CX:BX * DX:AX 的低 32 位，放在 DX:AX：
+			// 低字相乘的 32 位结果，加上两个交叉乘积的低 16 位到高字
+			cg.procBegin("long_mul"),
+			"    push si",
+			"    push di",
+			"    mov si, ax",
+			"    mov di, dx",
+			"    mov ax, cx",
+			"    mul si",
+			"    mov cx, ax",
+			"    mov ax, bx",
+			"    mul di",
+			"    add cx, ax",
+			"    mov ax, bx",
+			"    mul si",
+			"    add dx, cx",
+			"    pop di",
+			"    pop si",
+			"    ret",
+			cg.procEnd("long_mul"),
+			"",
+		)
+	}
+	cg.code = append(cg.code,
+		// long_div 计算 CX:BX / DX:AX：商放在 DX:AX，余数放在 CX:BX，商超出 long 范围时置 CF。
+		// 先对绝对值做 32 次移位相减，商向零取整，余数与被除数同号
+		cg.procBegin("long_div"),
+		"    push si",
+		"    push di",
+		"    push bp",
+		"    mov si, ax",
+		"    or si, dx",
+		"    jnz long_div_ok",
+		"    mov dx, "+cg.offset("msg_div_by_zero"),
+		"    mov ah, 9",
+		"    int 21h",
+		"    mov ah, 4Ch",
+		"    int 21h",
+		"long_div_ok:",
+		"    push cx",
+		"    mov si, cx",
+		"    xor si, dx",
+		"    push si",
+		"    test cx, cx",
+		"    jns long_div_dividend",
+		"    neg cx",
+		"    neg bx",
+		"    sbb cx, 0",
+		"long_div_dividend:",
+		"    test dx, dx",
+		"    jns long_div_divisor",
+		"    neg dx",
+		"    neg ax",
+		"    sbb dx, 0",
+		"long_div_divisor:",
+		"    mov si, ax",
+		"    mov di, dx",
+		"    mov ax, 0",
+		"    mov dx, 0",
+		"    mov bp, 32",
+		"long_div_loop:",
+		"    shl bx, 1",
+		"    rcl cx, 1",
+		"    rcl ax, 1",
+		"    rcl dx, 1",
+		"    cmp dx, di",
+		"    jb long_div_next",
+		"    ja long_div_sub",
+		"    cmp ax, si",
+		"    jb long_div_next",
+		"long_div_sub:",
+		"    sub ax, si",
+		"    sbb dx, di",
+		"    inc bx",
+		"long_div_next:",
+		"    dec bp",
+		"    jnz long_div_loop",
+		"    xchg ax, bx",
+		"    xchg dx, cx",
+		"    pop si",
+		"    test si, si",
+		"    jns long_div_positive",
+		"    neg dx",
+		"    neg ax",
+		"    sbb dx, 0",
+		"    jmp long_div_quotient",
+		"long_div_positive:",
+		// 只有 -2147483648 / -1 的商 2147483648 超出范围，这时 BP（循环结束后为 0）记为 1
+		"    test dx, dx",
+		"    jns long_div_quotient",
+		"    mov bp, 1",
+		"long_div_quotient:",
+		"    pop si",
+		"    test si, si",
+		"    jns long_div_done",
+		"    neg cx",
+		"    neg bx",
+		"    sbb cx, 0",
+		"long_div_done:",
+		"    shr bp, 1",
+		"    pop bp",
+		"    pop di",
+		"    pop si",
+		"    ret",
+		cg.procEnd("long_div"),
+		"",
+	)
+}
+
+// addReadLong 添加 read_long：输入规则与 read_number 相同，结果放在 DX:AX。
+// DI:SI 累加绝对值，BP 为 1 表示负数，BX 记录状态
+func (cg *CodeGenerator) addReadLong() {
+	outOfRange, endOutOfRange := "read_long_invalid", "read_long_retry"
+	if cg.trap {
+		outOfRange, endOutOfRange = "read_long_overflow", "read_long_overflow"
+	}
+	cg.code = append(cg.code,
+		cg.procBegin("read_long"),
+		"    push bx",
+		"    push cx",
+		"    push si",
+		"    push di",
+		"    push bp",
+		"read_long_start:",
+		"    mov si, 0",
+		"    mov di, 0",
+		"    mov bp, 0",
+		"    mov bx, 0",
+		"read_long_loop:",
+		"    mov ah, 1",
+		"    int 21h",
+		"    cmp al, 13",
+		"    je read_long_done",
+		"    cmp bx, 3",
+		"    je read_long_loop",
+		"    cmp al, '-'",
+		"    je read_long_sign",
+		"    cmp al, '+'",
+		"    je read_long_sign",
+		"    cmp al, '0'",
+		"    jb read_long_invalid",
+		"    cmp al, '9'",
+		"    ja read_long_invalid",
+		"    mov bx, 2",
+		"    sub al, '0'",
+		"    mov ah, 0",
+		"    push ax",
+		// DI:SI = DI:SI * 10 + 数字，绝对值最大为 2147483648，正数读完后再检查
+		"    mov cx, 10",
+		"    mov ax, di",
+		"    mul cx",
+		"    jc read_long_discard",
+		"    mov di, ax",
+		"    mov ax, si",
+		"    mul cx",
+		"    mov si, ax",
+		"    add di, dx",
+		"    jc read_long_discard",
+		"    pop ax",
+		"    add si, ax",
+		"    adc di, 0",
+		"    jc "+outOfRange,
+		"    cmp di, 32768",
+		"    ja "+outOfRange,
+		"    jb read_long_loop",
+		"    test si, si",
+		"    jnz "+outOfRange,
+		"    jmp read_long_loop",
+		"read_long_discard:",
+		"    pop ax",
+		"    jmp "+outOfRange,
+		"read_long_sign:",
+		"    cmp bx, 0",
+		"    jne read_long_invalid",
+		"    mov bx, 1",
+		"    cmp al, '-'",
+		"    jne read_long_loop",
+		"    mov bp, 1",
+		"    jmp read_long_loop",
+		"read_long_invalid:",
+		"    mov bx, 3",
+		"    jmp read_long_loop",
+		"read_long_done:",
+		"    cmp bx, 2",
+		"    jne read_long_retry",
+		"    mov ax, si",
+		"    mov dx, di",
+		"    cmp bp, 1",
+		"    jne read_long_positive",
+		"    neg dx",
+		"    neg ax",
+		"    sbb dx, 0",
+		"    jmp read_long_ok",
+		"read_long_positive:",
+		"    test dx, dx",
+		"    js "+endOutOfRange,
+		"read_long_ok:",
+		"    clc",
+		"read_long_return:",
+		"    pop bp",
+		"    pop di",
+		"    pop si",
+		"    pop cx",
+		"    pop bx",
+		"    ret",
+		"read_long_retry:",
+		"    mov dx, "+cg.offset("msg_invalid_number"),
+		"    mov ah, 9",
+		"    int 21h",
+		"    jmp read_long_start",
+	)
+	if cg.trap {
+		cg.code = append(cg.code,
+			"read_long_overflow:",
+			"    stc",
+			"    jmp read_long_return",
+		)
+	}
+	cg.code = append(cg.code, cg.procEnd("read_long"), "")
+}
+
+// addCheckedLongMul 添加检查溢出的 long_mul：计算 CX:BX * DX:AX 放在 DX:AX，积超出 long 范围时置 CF。
+// 先对绝对值相乘：两个高字都不为 0 时一定溢出，否则把高字为 0 的一方换到 DX:AX，
+// 只需两次 16 位乘法；最后按符号检查范围并取负
+func (cg *CodeGenerator) addCheckedLongMul() {
+	cg.code = append(cg.code,
+		cg.procBegin("long_mul"),
+		"    push si",
+		"    push di",
+		"    push bp",
+		"    mov bp, cx",
+		"    xor bp, dx",
+		"    test cx, cx",
+		"    jns long_mul_left",
+		"    neg cx",
+		"    neg bx",
+		"    sbb cx, 0",
+		"long_mul_left:",
+		"    test dx, dx",
+		"    jns long_mul_right",
+		"    neg dx",
+		"    neg ax",
+		"    sbb dx, 0",
+		"long_mul_right:",
+		"    test dx, dx",
+		"    jz long_mul_small",
+		"    test cx, cx",
+		"    jnz long_mul_overflow",
+		"    xchg ax, bx",
+		"    xchg dx, cx",
+		"long_mul_small:",
+		"    mov si, ax",
+		"    mov ax, cx",
+		"    mul si",
+		"    jc long_mul_overflow",
+		"    mov di, ax",
+		"    mov ax, bx",
+		"    mul si",
+		"    add dx, di",
+		"    jc long_mul_overflow",
+		"    test bp, bp",
+		"    jns long_mul_positive",
+		"    cmp dx, 32768",
+		"    ja long_mul_overflow",
+		"    jb long_mul_negate",
+		"    test ax, ax",
+		"    jnz long_mul_overflow",
+		"long_mul_negate:",
+		"    neg dx",
+		"    neg ax",
+		"    sbb dx, 0",
+		"    jmp long_mul_ok",
+		"long_mul_positive:",
+		"    test dx, dx",
+		"    js long_mul_overflow",
+		"long_mul_ok:",
+		"    clc",
+		"    jmp long_mul_return",
+		"long_mul_overflow:",
+		"    stc",
+		"long_mul_return:",
+		"    pop bp",
+		"    pop di",
+		"    pop si",
+		"    ret",
+		cg.procEnd("long_mul"),
+		"",
+	)
+}
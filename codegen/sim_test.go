@@ -0,0 +1,606 @@
+package codegen
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// machine 解释执行 NASM 方言的 COM 程序，用于测试。只支持代码生成器用到的指令和 DOS 功能：
+// INT 21h 的 AH=1 读一个字符（不回显）、AH=2 输出一个字符、AH=9 输出 $ 结尾的字符串、AH=4Ch 退出。
+// 代码标号的“地址”是指令的下标，跳转表中的 dw 项也存放下标
+type machine struct {
+	code    [][]string        // 每条指令的助记符和操作数
+	text    map[string]int    // 代码标号对应的指令下标
+	symbols map[string]uint16 // 数据标号的地址和 equ 定义的常量
+	mem     []byte
+	reg     map[string]uint16
+	cf, zf  bool
+	sf, of  bool
+	in      *bufio.Reader
+	out     bytes.Buffer
+	steps   int
+	exit    int // 退出码，程序还没有退出时为 -1
+}
+
+const (
+	simDataBase = 0x100
+	simMaxSteps = 10000000
+)
+
+var simRegs8 = map[string]struct {
+	reg   string
+	shift uint
+}{
+	"al": {"ax", 0}, "ah": {"ax", 8}, "bl": {"bx", 0}, "bh": {"bx", 8},
+	"cl": {"cx", 0}, "ch": {"cx", 8}, "dl": {"dx", 0}, "dh": {"dx", 8},
+}
+
+// newMachine 汇编 source：数据定义写入内存，指令按顺序记录
+func newMachine(source string, in io.Reader) (*machine, error) {
+	m := &machine{
+		text:    make(map[string]int),
+		symbols: make(map[string]uint16),
+		mem:     make([]byte, 0x10000),
+		reg:     map[string]uint16{"ax": 0, "bx": 0, "cx": 0, "dx": 0, "si": 0, "di": 0, "bp": 0, "sp": 0xFFFE},
+		in:      bufio.NewReader(in),
+		steps:   simMaxSteps,
+		exit:    -1,
+	}
+	type fixup struct {
+		addr  uint16
+		label string
+	}
+	var fixups []fixup
+	addr := uint16(simDataBase)
+	for n, line := range strings.Split(source, "\n") {
+		fields := splitOperands(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if label, ok := labelOf(line); ok {
+			m.text[label] = len(m.code)
+			continue
+		}
+		// "name db ..." 或者跳转表的续行 "dw ..."
+		name, kind := "", fields[0]
+		if len(fields) > 1 && isDataKind(fields[1]) {
+			name, kind = fields[0], fields[1]
+			fields = fields[1:]
+		}
+		switch {
+		case kind == "equ":
+			v, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行：%v", n+1, err)
+			}
+			m.symbols[name] = uint16(v)
+		case isDataKind(kind):
+			if name != "" {
+				m.symbols[name] = addr
+			}
+			for _, v := range fields[1:] {
+				switch {
+				case kind == "db" && strings.HasPrefix(v, "'"):
+					addr += uint16(copy(m.mem[addr:], v[1:len(v)-1]))
+				case kind == "db":
+					b, err := strconv.Atoi(v)
+					if err != nil {
+						return nil, fmt.Errorf("第 %d 行：%v", n+1, err)
+					}
+					m.mem[addr] = byte(b)
+					addr++
+				case kind == "dw":
+					if w, err := strconv.Atoi(v); err == nil {
+						m.store(addr, uint16(w), 16)
+					} else {
+						fixups = append(fixups, fixup{addr, v})
+					}
+					addr += 2
+				default:
+					addr += 4
+				}
+			}
+		case kind == "bits" || kind == "org" || kind == "section":
+		default:
+			m.code = append(m.code, fields)
+		}
+	}
+	for _, f := range fixups {
+		pc, ok := m.text[f.label]
+		if !ok {
+			return nil, fmt.Errorf("跳转表中未定义的标号 %s", f.label)
+		}
+		m.store(f.addr, uint16(pc), 16)
+	}
+	return m, nil
+}
+
+func isDataKind(s string) bool {
+	return s == "db" || s == "dw" || s == "dd" || s == "equ"
+}
+
+// splitOperands 把一行拆成助记符（或数据名和数据类型）和各个操作数，忽略注释，引号中的逗号和分号不分隔
+func splitOperands(line string) []string {
+	var parts []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range line {
+		if r == '\'' {
+			quoted = !quoted
+		}
+		if !quoted && r == ';' {
+			break
+		}
+		if !quoted && r == ',' {
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	parts = append(parts, strings.TrimSpace(cur.String()))
+	// 第一个操作数前面是助记符，数据定义还有数据名和数据类型
+	head := strings.Fields(parts[0])
+	if len(head) == 0 {
+		return nil
+	}
+	n := 1
+	if len(head) > 1 && isDataKind(head[1]) {
+		n = 2
+	}
+	fields := append([]string(nil), head[:n]...)
+	first := strings.TrimSpace(parts[0])
+	for _, h := range head[:n] {
+		first = strings.TrimSpace(strings.TrimPrefix(first, h))
+	}
+	if first != "" {
+		fields = append(fields, first)
+	}
+	return append(fields, parts[1:]...)
+}
+
+// run 从 main_start 开始执行到程序退出，返回输出（去掉回车）
+func (m *machine) run() (string, error) {
+	pc, ok := m.text["main_start"]
+	if !ok {
+		return "", fmt.Errorf("没有 main_start")
+	}
+	for m.exit < 0 {
+		if pc < 0 || pc >= len(m.code) {
+			return m.output(), fmt.Errorf("指令下标 %d 超出范围", pc)
+		}
+		if m.steps--; m.steps < 0 {
+			return m.output(), fmt.Errorf("执行的指令超过 %d 条", simMaxSteps)
+		}
+		next, err := m.step(pc)
+		if err != nil {
+			return m.output(), fmt.Errorf("%v：%s", err, strings.Join(m.code[pc], " "))
+		}
+		pc = next
+	}
+	return m.output(), nil
+}
+
+func (m *machine) output() string {
+	return strings.ReplaceAll(m.out.String(), "\r", "")
+}
+
+// size 返回操作数的位数：8 位寄存器和 byte 操作数为 8，其余为 16
+func size(operands ...string) uint {
+	for _, op := range operands {
+		if _, ok := simRegs8[op]; ok || strings.HasPrefix(op, "byte ") {
+			return 8
+		}
+	}
+	return 16
+}
+
+// address 计算 [name]、[name+2]、[bx] 形式的内存操作数的地址，不是内存操作数时返回 false
+func (m *machine) address(operand string) (uint16, bool, error) {
+	operand = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(operand, "word "), "byte "))
+	if !strings.HasPrefix(operand, "[") {
+		return 0, false, nil
+	}
+	inner := strings.Trim(operand, "[]")
+	offset := 0
+	if i := strings.Index(inner, "+"); i >= 0 {
+		var err error
+		if offset, err = strconv.Atoi(inner[i+1:]); err != nil {
+			return 0, true, err
+		}
+		inner = inner[:i]
+	}
+	if v, ok := m.reg[inner]; ok {
+		return v + uint16(offset), true, nil
+	}
+	base, ok := m.symbols[inner]
+	if !ok {
+		return 0, true, fmt.Errorf("未定义的符号 %s", inner)
+	}
+	return base + uint16(offset), true, nil
+}
+
+func (m *machine) load(addr uint16, bits uint) uint16 {
+	if bits == 8 {
+		return uint16(m.mem[addr])
+	}
+	return uint16(m.mem[addr]) | uint16(m.mem[addr+1])<<8
+}
+
+func (m *machine) store(addr, v uint16, bits uint) {
+	m.mem[addr] = byte(v)
+	if bits == 16 {
+		m.mem[addr+1] = byte(v >> 8)
+	}
+}
+
+// get 读取寄存器、内存或立即数操作数
+func (m *machine) get(operand string, bits uint) (uint16, error) {
+	if v, ok := m.reg[operand]; ok {
+		return v, nil
+	}
+	if r, ok := simRegs8[operand]; ok {
+		return m.reg[r.reg] >> r.shift & 0xFF, nil
+	}
+	addr, isMem, err := m.address(operand)
+	if err != nil || isMem {
+		return m.load(addr, bits), err
+	}
+	if len(operand) == 3 && operand[0] == '\'' {
+		return uint16(operand[1]), nil
+	}
+	if v, ok := m.symbols[operand]; ok {
+		return v, nil
+	}
+	if s := strings.ToLower(operand); strings.HasSuffix(s, "h") {
+		v, err := strconv.ParseUint(strings.TrimSuffix(s, "h"), 16, 16)
+		return uint16(v), err
+	}
+	v, err := strconv.Atoi(operand)
+	return uint16(v), err
+}
+
+// set 写寄存器或内存操作数
+func (m *machine) set(operand string, v uint16, bits uint) error {
+	if _, ok := m.reg[operand]; ok {
+		m.reg[operand] = v
+		return nil
+	}
+	if r, ok := simRegs8[operand]; ok {
+		m.reg[r.reg] = m.reg[r.reg]&^(0xFF<<r.shift) | (v&0xFF)<<r.shift
+		return nil
+	}
+	addr, isMem, err := m.address(operand)
+	if err != nil {
+		return err
+	}
+	if !isMem {
+		return fmt.Errorf("不能写入 %s", operand)
+	}
+	m.store(addr, v, bits)
+	return nil
+}
+
+func (m *machine) push(v uint16) {
+	m.reg["sp"] -= 2
+	m.store(m.reg["sp"], v, 16)
+}
+
+func (m *machine) pop() uint16 {
+	v := m.load(m.reg["sp"], 16)
+	m.reg["sp"] += 2
+	return v
+}
+
+// signed 把 bits 位的值看作有符号数
+func signed(v uint16, bits uint) int {
+	if bits == 8 {
+		return int(int8(v))
+	}
+	return int(int16(v))
+}
+
+func (m *machine) setZS(v uint16, bits uint) {
+	mask := uint16(1)<<bits - 1
+	m.zf = v&mask == 0
+	m.sf = v>>(bits-1)&1 == 1
+}
+
+func (m *machine) jump(label string) (int, error) {
+	pc, ok := m.text[label]
+	if !ok {
+		return 0, fmt.Errorf("未定义的代码标号 %s", label)
+	}
+	return pc, nil
+}
+
+// step 执行第 pc 条指令，返回下一条指令的下标
+func (m *machine) step(pc int) (int, error) {
+	op, args := m.code[pc][0], m.code[pc][1:]
+	arg := func(i int) (uint16, error) { return m.get(args[i], size(args...)) }
+	switch op {
+	case "mov":
+		v, err := arg(1)
+		if err != nil {
+			return 0, err
+		}
+		return pc + 1, m.set(args[0], v, size(args...))
+	case "add", "adc", "sub", "sbb", "cmp":
+		bits := size(args...)
+		a, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		b, err := arg(1)
+		if err != nil {
+			return 0, err
+		}
+		carry := 0
+		if (op == "adc" || op == "sbb") && m.cf {
+			carry = 1
+		}
+		mask := 1<<bits - 1
+		var r, s int
+		if op == "add" || op == "adc" {
+			r = int(a) + int(b) + carry
+			s = signed(a, bits) + signed(b, bits) + carry
+			m.cf = r > mask
+		} else {
+			r = int(a) - int(b) - carry
+			s = signed(a, bits) - signed(b, bits) - carry
+			m.cf = r < 0
+		}
+		m.of = s < -(1<<(bits-1)) || s >= 1<<(bits-1)
+		m.setZS(uint16(r), bits)
+		if op != "cmp" {
+			return pc + 1, m.set(args[0], uint16(r&mask), bits)
+		}
+	case "and", "or", "xor", "test":
+		bits := size(args...)
+		a, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		b, err := arg(1)
+		if err != nil {
+			return 0, err
+		}
+		var r uint16
+		switch op {
+		case "and", "test":
+			r = a & b
+		case "or":
+			r = a | b
+		case "xor":
+			r = a ^ b
+		}
+		m.cf, m.of = false, false
+		m.setZS(r, bits)
+		if op != "test" {
+			return pc + 1, m.set(args[0], r, bits)
+		}
+	case "inc", "dec", "neg", "not":
+		a, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		var r uint16
+		switch op {
+		case "inc":
+			r = a + 1
+			m.of = a == 0x7FFF
+		case "dec":
+			r = a - 1
+			m.of = a == 0x8000
+		case "neg":
+			r = -a
+			m.cf, m.of = a != 0, a == 0x8000
+		case "not":
+			return pc + 1, m.set(args[0], ^a, 16)
+		}
+		m.setZS(r, 16)
+		return pc + 1, m.set(args[0], r, 16)
+	case "mul", "imul":
+		b, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		var r uint32
+		if op == "mul" {
+			r = uint32(m.reg["ax"]) * uint32(b)
+			m.cf = r>>16 != 0
+		} else {
+			p := int32(int16(m.reg["ax"])) * int32(int16(b))
+			r = uint32(p)
+			m.cf = p != int32(int16(p))
+		}
+		m.of = m.cf
+		m.reg["ax"], m.reg["dx"] = uint16(r), uint16(r>>16)
+	case "div", "idiv":
+		b, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		if b == 0 {
+			return 0, fmt.Errorf("除法错误：除数为 0")
+		}
+		n := uint32(m.reg["dx"])<<16 | uint32(m.reg["ax"])
+		if op == "div" {
+			q := n / uint32(b)
+			if q > 0xFFFF {
+				return 0, fmt.Errorf("除法错误：商超出 16 位")
+			}
+			m.reg["ax"], m.reg["dx"] = uint16(q), uint16(n%uint32(b))
+		} else {
+			q, r := int32(n)/int32(int16(b)), int32(n)%int32(int16(b))
+			if q != int32(int16(q)) {
+				return 0, fmt.Errorf("除法错误：商超出 16 位")
+			}
+			m.reg["ax"], m.reg["dx"] = uint16(q), uint16(r)
+		}
+	case "cwd":
+		m.reg["dx"] = 0
+		if m.reg["ax"]&0x8000 != 0 {
+			m.reg["dx"] = 0xFFFF
+		}
+	case "shl", "shr", "sar", "rcl", "rcr":
+		v, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		count, err := m.get(args[1], 8)
+		if err != nil {
+			return 0, err
+		}
+		for i := uint16(0); i < count&0xFF; i++ {
+			switch op {
+			case "shl":
+				m.cf, v = v&0x8000 != 0, v<<1
+			case "shr":
+				m.cf, v = v&1 != 0, v>>1
+			case "sar":
+				m.cf, v = v&1 != 0, uint16(int16(v)>>1)
+			case "rcl":
+				c := v&0x8000 != 0
+				v = v << 1
+				if m.cf {
+					v |= 1
+				}
+				m.cf = c
+			case "rcr":
+				c := v&1 != 0
+				v = v >> 1
+				if m.cf {
+					v |= 0x8000
+				}
+				m.cf = c
+			}
+		}
+		if (op == "shl" || op == "shr" || op == "sar") && count != 0 {
+			m.setZS(v, 16)
+		}
+		return pc + 1, m.set(args[0], v, 16)
+	case "xchg":
+		a, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		b, err := arg(1)
+		if err != nil {
+			return 0, err
+		}
+		if err := m.set(args[0], b, 16); err != nil {
+			return 0, err
+		}
+		return pc + 1, m.set(args[1], a, 16)
+	case "push":
+		v, err := arg(0)
+		if err != nil {
+			return 0, err
+		}
+		m.push(v)
+	case "pop":
+		return pc + 1, m.set(args[0], m.pop(), 16)
+	case "clc":
+		m.cf = false
+	case "stc":
+		m.cf = true
+	case "call":
+		m.push(uint16(pc + 1))
+		return m.jump(args[0])
+	case "ret":
+		return int(m.pop()), nil
+	case "jmp":
+		if strings.HasPrefix(args[0], "word ") {
+			v, err := m.get(args[0], 16)
+			return int(v), err
+		}
+		return m.jump(args[0])
+	case "loop":
+		m.reg["cx"]--
+		if m.reg["cx"] != 0 {
+			return m.jump(args[0])
+		}
+	case "jcxz":
+		if m.reg["cx"] == 0 {
+			return m.jump(args[0])
+		}
+	case "int":
+		return pc + 1, m.dos()
+	default:
+		taken, ok := m.condition(op)
+		if !ok {
+			return 0, fmt.Errorf("不支持的指令")
+		}
+		if taken {
+			return m.jump(args[0])
+		}
+	}
+	return pc + 1, nil
+}
+
+// condition 报告条件跳转 op 的条件是否成立
+func (m *machine) condition(op string) (taken, ok bool) {
+	switch op {
+	case "je", "jz":
+		return m.zf, true
+	case "jne", "jnz":
+		return !m.zf, true
+	case "jl":
+		return m.sf != m.of, true
+	case "jge":
+		return m.sf == m.of, true
+	case "jg":
+		return !m.zf && m.sf == m.of, true
+	case "jle":
+		return m.zf || m.sf != m.of, true
+	case "jb", "jc":
+		return m.cf, true
+	case "jae", "jnc":
+		return !m.cf, true
+	case "ja":
+		return !m.cf && !m.zf, true
+	case "jbe":
+		return m.cf || m.zf, true
+	case "jo":
+		return m.of, true
+	case "jno":
+		return !m.of, true
+	case "js":
+		return m.sf, true
+	case "jns":
+		return !m.sf, true
+	}
+	return false, false
+}
+
+// dos 执行 INT 21h
+func (m *machine) dos() error {
+	switch m.reg["ax"] >> 8 {
+	case 1:
+		c, err := m.in.ReadByte()
+		if err != nil {
+			return err
+		}
+		// 键盘输入的行以回车结束
+		if c == '\n' {
+			c = '\r'
+		}
+		m.set("al", uint16(c), 8)
+	case 2:
+		m.out.WriteByte(byte(m.reg["dx"]))
+	case 9:
+		for addr := m.reg["dx"]; m.mem[addr] != '$'; addr++ {
+			m.out.WriteByte(m.mem[addr])
+		}
+	case 0x4C:
+		m.exit = int(m.reg["ax"] & 0xFF)
+	default:
+		return fmt.Errorf("不支持的 DOS 功能 %02Xh", m.reg["ax"]>>8)
+	}
+	return nil
+}
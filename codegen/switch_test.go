@@ -0,0 +1,79 @@
+package codegen
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSwitchPlan 检查 switch 何时使用跳转表：至少 jumpTableMinCases 个 case 值、
+// 最小值到最大值的范围不超过 jumpTableMaxSize，且至少一半的表项是某个 case，否则使用比较链。
+// 两种方式都在 machine 中执行，检查每个 case 值、表中的空位和范围外的值都跳到正确的分支
+func TestSwitchPlan(t *testing.T) {
+	span := func(from, to, step int) []int {
+		var values []int
+		for v := from; v <= to; v += step {
+			values = append(values, v)
+		}
+		return values
+	}
+	tests := []struct {
+		name   string
+		values []int
+		table  bool
+	}{
+		{"too few cases", []int{1, 2, 3}, false},
+		{"dense", []int{1, 2, 3, 4}, true},
+		{"negative", []int{-2, -1, 0, 1}, true},
+		{"more than half", []int{0, 2, 4, 6}, true},
+		{"exactly half", []int{0, 2, 4, 7}, true},
+		{"less than half", []int{0, 2, 4, 8}, false},
+		{"largest table", span(0, 255, 2), true},
+		{"table too large", span(0, 256, 2), false},
+	}
+	for _, tt := range tests {
+		var src strings.Builder
+		src.WriteString("int x;\ninput x;\nswitch (x) {\n")
+		for _, v := range tt.values {
+			fmt.Fprintf(&src, "case %d:\n    print %d;\n    break;\n", v, v)
+		}
+		src.WriteString("default:\n    print 1000;\n}\n")
+		ast, err := parser.NewParser(lexer.NewLexer(src.String())).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cg := NewCodeGeneratorWithOptions(Options{Dialect: DIALECT_NASM})
+		code := strings.Join(cg.Generate(ast), "\n")
+		for _, plan := range cg.switches {
+			if got := plan.table != ""; got != tt.table {
+				t.Errorf("%s：使用跳转表为 %v，期望 %v", tt.name, got, tt.table)
+			}
+		}
+
+		cases := make(map[int]bool)
+		for _, v := range tt.values {
+			cases[v] = true
+		}
+		min, max := tt.values[0], tt.values[len(tt.values)-1]
+		for x := min - 1; x <= max+1; x++ {
+			want := 1000
+			if cases[x] {
+				want = x
+			}
+			m, err := newMachine(code, strings.NewReader(strconv.Itoa(x)+"\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.run()
+			if err != nil {
+				t.Fatalf("%s：x = %d：%v", tt.name, x, err)
+			}
+			if got != fmt.Sprintf("\n%d\n", want) {
+				t.Errorf("%s：x = %d 时输出 %q，期望 %d", tt.name, x, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package codegen
+
+import (
+	"compiler/parser"
+	"fmt"
+)
+
+// 溢出检查（--overflow=trap）：可能溢出的指令之后用 jo、jc 等条件跳转到出错语句所在行的桩代码。
+// 桩代码在程序末尾，超出条件跳转的范围，所以用反向的条件跳转越过一条 jmp（见 jumpAround）。
+// 桩代码把行号放入 AX、文件下标（见 parser.Pos.File）放入 CX、错误信息放入 DX，
+// 再转到 runtime_error 输出并以退出码 1 结束程序。
+// 同一文件同一行的同一种错误只生成一次。
+
+// trapKind 是运行时错误的种类
+type trapKind int
+
+const (
+	trapOverflow trapKind = iota // 算术运算溢出
+	trapInput                    // 输入的数超出变量类型的范围
+)
+
+// trapSite 是一段运行时错误桩代码
+type trapSite struct {
+	kind trapKind
+	file int
+	line int
+}
+
+func (t trapSite) label() string {
+	if t.kind == trapInput {
+		return fmt.Sprintf("trap_input_%d_%d", t.file, t.line)
+	}
+	return fmt.Sprintf("trap_overflow_%d_%d", t.file, t.line)
+}
+
+func (t trapSite) message() string {
+	if t.kind == trapInput {
+		return "msg_input_range"
+	}
+	return "msg_overflow"
+}
+
+// trapIf 在检查溢出时生成条件跳转 jump，条件成立时报告 pos 所在文件和行的运行时错误
+func (cg *CodeGenerator) trapIf(jump string, kind trapKind, pos parser.Pos) {
+	if !cg.trap {
+		return
+	}
+	site := trapSite{kind: kind, file: pos.File, line: pos.Line}
+	found := false
+	for _, t := range cg.traps {
+		if t == site {
+			found = true
+			break
+		}
+	}
+	if !found {
+		cg.traps = append(cg.traps, site)
+	}
+	cg.code = append(cg.code, cg.jumpAround(jump, site.label())...)
+}
+
+// genTrapData 生成运行时错误信息，后面紧跟出错的行号；出错的语句不在主文件中时再输出文件下标
+func (cg *CodeGenerator) genTrapData() {
+	if !cg.trap {
+		return
+	}
+	cg.code = append(cg.code,
+		"    msg_overflow db 'Error: Arithmetic overflow at line $'",
+		"    msg_input_range db 'Error: Input out of range at line $'",
+		"    msg_in_file db ' in file $'",
+	)
+}
+
+// genTraps 生成程序中用到的运行时错误桩代码
+func (cg *CodeGenerator) genTraps() {
+	for _, t := range cg.traps {
+		cg.code = append(cg.code,
+			fmt.Sprintf("%s:", t.label()),
+			fmt.Sprintf("    mov ax, %d", t.line),
+			fmt.Sprintf("    mov cx, %d", t.file),
+			"    mov dx, "+cg.offset(t.message()),
+			"    jmp runtime_error",
+		)
+	}
+}
+
+// addTrapHelpers 添加 runtime_error：输出 DX 指向的错误信息和 AX 中的行号，
+// CX 不为 0 时再输出 CX 中的文件下标，以退出码 1 结束程序
+func (cg *CodeGenerator) addTrapHelpers() {
+	cg.code = append(cg.code,
+		cg.procBegin("runtime_error"),
+		"    push ax",
+		"    mov ah, 9",
+		"    int 21h",
+		"    pop ax",
+		"    call print_number",
+		"    cmp cx, 0",
+		"    je runtime_error_end",
+		"    mov dx, "+cg.offset("msg_in_file"),
+		"    mov ah, 9",
+		"    int 21h",
+		"    mov ax, cx",
+		"    call print_number",
+		"runtime_error_end:",
+		"    mov dx, "+cg.offset("newline"),
+		"    mov ah, 9",
+		"    int 21h",
+		"    mov ax, 4C01h",
+		"    int 21h",
+		cg.procEnd("runtime_error"),
+		"",
+	)
+}
@@ -0,0 +1,32 @@
+package codegen
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"strings"
+	"testing"
+)
+
+// TestTrapSites 检查溢出检查的桩代码：-32768 % -1 不报告溢出，
+// 桩代码的标号和 CX 中的文件下标区分不同文件中的同一行
+func TestTrapSites(t *testing.T) {
+	source := "int m = -32768;\nint n = -1;\nint r = m % n;\nint q = m / n;\nlong a = 1;\na = a % a;\n"
+	p := parser.NewParser(lexer.NewLexer(source))
+	p.SetFile(2)
+	ast, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := strings.Join(NewCodeGeneratorWithOptions(Options{Trap: true}).Generate(ast), "\n")
+
+	for _, want := range []string{"trap_overflow_2_4:", "    mov cx, 2", "msg_in_file"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("生成的代码中没有 %q", want)
+		}
+	}
+	for _, unwanted := range []string{"trap_overflow_2_3", "trap_overflow_2_6", "trap_overflow_4"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("生成的代码中不应有 %q", unwanted)
+		}
+	}
+}
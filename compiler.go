@@ -0,0 +1,164 @@
+// Package compiler 把词法分析、语法分析、优化和代码生成串成一条流水线，
+// 供命令行工具、编辑器插件、评测程序等嵌入使用。
+//
+// 包内没有全局可变状态，也不会打印或退出进程，多个编译可以并发进行。
+// 诊断信息只记录消息编号和参数，用 Diagnostic.Format 或 Error.Format 按指定的语言输出，
+// String 和 Error 使用 msg 包的默认语言。
+package compiler
+
+import (
+	"compiler/backend"
+	"compiler/bytecode"
+	_ "compiler/codegen"
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/optimize"
+	"compiler/parser"
+	_ "compiler/riscv"
+	"context"
+)
+
+// Options 控制一次编译
+type Options struct {
+	// Target 是目标后端名（见 Targets），为空时只做分析不生成目标代码
+	Target string
+	// OptLevel 是优化级别，见 optimize.Optimize
+	OptLevel int
+	// Filename 出现在诊断信息中，也是解析 import 相对路径的起点，可以为空
+	Filename string
+	// ReadFile 读取 import 的文件，为 nil 时使用 os.ReadFile
+	ReadFile func(name string) ([]byte, error)
+	// Annotate 为真时在生成的代码中插入源代码行注释，
+	// 并在 Artifacts 中追加一个源码映射（扩展名为目标扩展名加 .map 的 JSON 文件）
+	Annotate bool
+	// Overflow 是整数溢出的处理方式，默认按补码回绕；
+	// backend.OVERFLOW_TRAP 只有 8086 汇编目标支持
+	Overflow backend.Overflow
+}
+
+// Artifact 是一个生成的输出文件
+type Artifact struct {
+	Target    string
+	Extension string
+	Data      []byte
+}
+
+// Result 是一次编译的全部产物。出错时只填写已完成阶段的结果。
+type Result struct {
+	Tokens      []lexer.Token     // 主文件的词法单元
+	Files       []string          // 参与编译的全部源文件，主文件在前
+	Owners      map[string]string // 每个变量所属的源文件，见 import
+	AST         *parser.AST       // 展开 import 后的语法树（未优化）
+	Optimized   *parser.AST       // 优化后的语法树，代码生成使用它
+	IR          *bytecode.Program
+	Artifacts   []Artifact
+	Diagnostics []Diagnostic
+}
+
+// HasErrors 报告编译是否产生了错误
+func (r *Result) HasErrors() bool {
+	return len(r.Diagnostics) > 0
+}
+
+// Targets 返回所有可用的目标后端名
+func Targets() []string {
+	return backend.Names()
+}
+
+// Compile 编译一段源代码。
+//
+// 源程序有错误时返回的 error 为 *Error，Result 中仍包含已完成阶段的结果；
+// 选项不合法或 ctx 被取消时返回相应的错误。
+func Compile(ctx context.Context, source string, opts Options) (*Result, error) {
+	var b backend.Backend
+	if opts.Target != "" {
+		var ok bool
+		if b, ok = backend.Lookup(opts.Target); !ok {
+			return nil, msg.Errorf(msg.GEN_UNKNOWN_TARGET, opts.Target)
+		}
+		var err error
+		if b, err = backend.WithOverflow(b, opts.Overflow); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Result{Tokens: tokenize(source)}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	// 词法和语法分析，展开 import 后做语义检查
+	ln := newLinker(opts.ReadFile)
+	ln.load(opts.Filename, source, nil)
+	res.Files = ln.files
+	if len(ln.diagnostics) == 0 {
+		res.Owners = ln.check()
+	}
+	if len(ln.diagnostics) > 0 {
+		res.Diagnostics = append(res.Diagnostics, ln.diagnostics...)
+		return res, &Error{Diagnostics: res.Diagnostics}
+	}
+	ast := &parser.AST{Statements: ln.stmts}
+	res.AST = ast
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	res.Optimized = optimize.Optimize(ast, opts.OptLevel)
+	// IR 尽力生成，字节码不支持的程序没有 IR
+	if prog, err := bytecode.Compile(res.Optimized); err == nil {
+		res.IR = prog
+	}
+	if b == nil {
+		return res, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	var data, sourceMap []byte
+	var err error
+	switch {
+	case opts.Annotate && len(res.Files) > 1:
+		// 语句的行号属于各自的文件，而注释和源码映射只对应主文件
+		err = msg.Errorf(msg.GEN_ANNOTATE_MULTI_FILE)
+	case opts.Annotate:
+		var mappings []backend.Mapping
+		data, mappings, err = backend.GenerateAnnotated(b, res.Optimized, source)
+		if err == nil {
+			sourceMap, err = backend.MarshalSourceMap(opts.Filename, mappings)
+		}
+	default:
+		data, err = backend.Generate(b, res.Optimized)
+	}
+	if err != nil {
+		res.Diagnostics = append(res.Diagnostics, DiagnosticFromError(opts.Filename, err))
+		return res, &Error{Diagnostics: res.Diagnostics}
+	}
+	res.Artifacts = append(res.Artifacts, Artifact{
+		Target:    b.Name(),
+		Extension: b.Extension(),
+		Data:      data,
+	})
+	if sourceMap != nil {
+		res.Artifacts = append(res.Artifacts, Artifact{
+			Target:    b.Name(),
+			Extension: b.Extension() + ".map",
+			Data:      sourceMap,
+		})
+	}
+	return res, nil
+}
+
+// tokenize 返回源代码的全部词法单元（含末尾的 EOF）
+func tokenize(source string) []lexer.Token {
+	var tokens []lexer.Token
+	l := lexer.NewLexer(source)
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == lexer.TOKEN_EOF {
+			return tokens
+		}
+	}
+}
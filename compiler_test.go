@@ -0,0 +1,252 @@
+package compiler
+
+import (
+	"bytes"
+	"compiler/backend"
+	"compiler/bytecode"
+	"compiler/msg"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestDiagnosticLanguage 检查诊断信息在输出时才按语言生成，与编译时的默认语言无关
+func TestDiagnosticLanguage(t *testing.T) {
+	_, err := Compile(context.Background(), "print missing;", Options{Filename: "main.src"})
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("错误 %v，期望 *Error", err)
+	}
+	d := cerr.Diagnostics[0]
+	zh, en := d.Format(msg.LANG_ZH), d.Format(msg.LANG_EN)
+	if !strings.HasPrefix(zh, "语义错误[") || !strings.Contains(zh, "missing") {
+		t.Errorf("中文诊断 %q", zh)
+	}
+	if !strings.HasPrefix(en, "semantic error[") || !strings.Contains(en, "missing") {
+		t.Errorf("英文诊断 %q", en)
+	}
+}
+
+// TestImportCycleLanguage 检查诊断参数中的“主程序”也按输出的语言生成
+func TestImportCycleLanguage(t *testing.T) {
+	files := map[string]string{"a.src": "import \"b.src\";", "b.src": "import \"a.src\";"}
+	read := func(name string) ([]byte, error) { return []byte(files[name]), nil }
+	_, err := Compile(context.Background(), files["a.src"], Options{Filename: "a.src", ReadFile: read})
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("错误 %v，期望 *Error", err)
+	}
+	if got := cerr.Format(msg.LANG_EN); !strings.Contains(got, "a.src -> b.src -> a.src") {
+		t.Errorf("诊断 %q", got)
+	}
+	_, err = Compile(context.Background(), "x = 1; import \"c.src\";", Options{
+		ReadFile: func(string) ([]byte, error) { return []byte("x = 2;"), nil },
+	})
+	if !errors.As(err, &cerr) {
+		t.Fatalf("错误 %v，期望 *Error", err)
+	}
+	zh, en := cerr.Format(msg.LANG_ZH), cerr.Format(msg.LANG_EN)
+	if !strings.Contains(zh, msg.Format(msg.LANG_ZH, msg.DIAG_MAIN_FILE)) || !strings.Contains(en, msg.Format(msg.LANG_EN, msg.DIAG_MAIN_FILE)) {
+		t.Errorf("诊断 %q / %q 中的主程序没有按语言生成", zh, en)
+	}
+}
+
+// TestConcurrentCompile 在另一个 goroutine 切换默认语言的同时并发编译，用 go test -race 检查数据竞争
+func TestConcurrentCompile(t *testing.T) {
+	defer msg.SetLang(msg.Current())
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				msg.SetLang(msg.LANG_EN)
+			} else {
+				msg.SetLang(msg.LANG_ZH)
+			}
+		}
+	}()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, target := range []string{"nasm", "riscv32", "bytecode"} {
+				res, err := Compile(context.Background(), "int x = 3; while (x > 0) { print x; x--; }", Options{Target: target})
+				if err != nil || len(res.Artifacts) != 1 {
+					t.Errorf("%s：%v", target, err)
+				}
+				if _, err := Compile(context.Background(), "print 1 +;", Options{Target: target}); err == nil || err.Error() == "" {
+					t.Errorf("%s：缺少语法错误", target)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+}
+
+// TestLink 用内存中的文件检查 import 的展开：导入多次的文件只展开一次，路径相对于导入它的文件，
+// 以及变量所属的文件和读取失败时报告的错误
+func TestLink(t *testing.T) {
+	files := map[string]string{
+		"lib/a.src":     "import \"c.src\";\nprint shared;\n",
+		"lib/b.src":     "import \"c.src\";\nprint shared + 1;\n",
+		"lib/c.src":     "int shared = 1;\n",
+		"owner.src":     "int x = 1;\n",
+		"assign.src":    "print x;\nx = 5;\n",
+		"undefined.src": "print y;\n",
+	}
+	read := func(name string) ([]byte, error) {
+		source, ok := files[filepath.ToSlash(name)]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(source), nil
+	}
+	tests := []struct {
+		name   string
+		source string
+		files  []string // 期望的 Result.Files
+		code   msg.Code
+		file   string
+		line   int
+		column int
+	}{
+		{"import twice", "import \"lib/a.src\";\nimport \"lib/b.src\";\nimport \"lib/c.src\";\nprint shared;\n",
+			[]string{"main.src", "lib/a.src", "lib/c.src", "lib/b.src"}, "", "", 0, 0},
+		{"var owner", "import \"owner.src\";\nx = 2;\n", []string{"main.src", "owner.src"}, msg.SEM_VAR_OWNER, "main.src", 2, 1},
+		{"var owner in import", "int x = 0;\nimport \"assign.src\";\n", []string{"main.src", "assign.src"}, msg.SEM_VAR_OWNER, "assign.src", 2, 1},
+		{"import read", "print 1;\nimport \"missing.src\";\n", []string{"main.src"}, msg.SEM_IMPORT_READ, "main.src", 2, 1},
+		{"error in import", "import \"undefined.src\";\n", []string{"main.src", "undefined.src"}, msg.SEM_UNDEFINED_VAR, "undefined.src", 1, 7},
+	}
+	for _, tt := range tests {
+		res, err := Compile(context.Background(), tt.source, Options{Filename: "main.src", ReadFile: read, Target: "bytecode"})
+		var got []string
+		for _, f := range res.Files {
+			got = append(got, filepath.ToSlash(f))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.files, " ") {
+			t.Errorf("%s：文件 %v，期望 %v", tt.name, got, tt.files)
+		}
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s：%v", tt.name, err)
+			}
+			continue
+		}
+		var cerr *Error
+		if !errors.As(err, &cerr) {
+			t.Errorf("%s：错误 %v，期望 *Error", tt.name, err)
+			continue
+		}
+		d := cerr.Diagnostics[0]
+		if d.Code != tt.code || filepath.ToSlash(d.File) != tt.file || d.Line != tt.line || d.Column != tt.column {
+			t.Errorf("%s：错误 %s %s:%d:%d，期望 %s %s:%d:%d", tt.name, d.Code, d.File, d.Line, d.Column, tt.code, tt.file, tt.line, tt.column)
+		}
+	}
+
+	// lib/c.src 只展开一次，在第一次导入它的 lib/a.src 之前；再次展开会重复声明 shared
+	res, err := Compile(context.Background(), tests[0].source, Options{Filename: "main.src", ReadFile: read})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := filepath.ToSlash(res.Owners["shared"]); owner != "lib/c.src" {
+		t.Errorf("shared 属于 %q，期望 lib/c.src", owner)
+	}
+	var out bytes.Buffer
+	if err := bytecode.NewVM(res.IR, strings.NewReader(""), &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\n2\n1\n"; out.String() != want {
+		t.Errorf("输出 %q，期望 %q", out.String(), want)
+	}
+}
+
+// TestAnnotate 检查带源代码注释的输出和源码映射：每条语句的范围从它的 "; line N:" 注释开始，
+// 内层循环的范围在外层循环之内，相邻语句的范围不重叠
+func TestAnnotate(t *testing.T) {
+	source := "int i = 0;\nwhile (i < 2) {\n    int j = 0;\n    while (j < 2) {\n        print j;\n        j++;\n    }\n    i++;\n}\n"
+	// 每条语句所在的行，以及包含它的语句所在的行（顶层语句为 0）
+	parents := map[int]int{1: 0, 2: 0, 3: 2, 4: 2, 5: 4, 6: 4, 8: 2}
+	tested := 0
+	for _, target := range Targets() {
+		b, _ := backend.Lookup(target)
+		if _, ok := b.(backend.Annotator); !ok {
+			continue
+		}
+		tested++
+		res, err := Compile(context.Background(), source, Options{Filename: "main.src", Target: target, Annotate: true})
+		if err != nil {
+			t.Fatalf("%s：%v", target, err)
+		}
+		if len(res.Artifacts) != 2 {
+			t.Fatalf("%s：生成了 %d 个文件，期望代码和源码映射", target, len(res.Artifacts))
+		}
+		lines := strings.Split(string(res.Artifacts[0].Data), "\n")
+		var sm backend.SourceMap
+		if err := json.Unmarshal(res.Artifacts[1].Data, &sm); err != nil {
+			t.Fatalf("%s：%v", target, err)
+		}
+		if sm.Source != "main.src" || len(sm.Mappings) != len(parents) {
+			t.Fatalf("%s：源码映射 %+v", target, sm)
+		}
+
+		ranges := make(map[int]backend.Mapping)
+		for _, m := range sm.Mappings {
+			ranges[m.Line] = m
+			if m.AsmStart < 1 || m.AsmEnd < m.AsmStart || m.AsmEnd > len(lines) {
+				t.Errorf("%s：第 %d 行语句的范围 %d-%d 不合法", target, m.Line, m.AsmStart, m.AsmEnd)
+				continue
+			}
+			want := fmt.Sprintf("; line %d: %s", m.Line, strings.TrimSpace(strings.Split(source, "\n")[m.Line-1]))
+			if got := strings.TrimSpace(lines[m.AsmStart-1]); got != want {
+				t.Errorf("%s：第 %d 行语句的范围从 %q 开始，期望 %q", target, m.Line, got, want)
+			}
+		}
+		for line, parent := range parents {
+			m, ok := ranges[line]
+			if !ok {
+				t.Errorf("%s：第 %d 行语句没有映射", target, line)
+				continue
+			}
+			if p, ok := ranges[parent]; ok && (m.AsmStart <= p.AsmStart || m.AsmEnd > p.AsmEnd) {
+				t.Errorf("%s：第 %d 行的范围 %d-%d 不在第 %d 行的范围 %d-%d 之内",
+					target, line, m.AsmStart, m.AsmEnd, parent, p.AsmStart, p.AsmEnd)
+			}
+		}
+		for _, pair := range [][2]int{{1, 2}, {3, 4}, {5, 6}, {4, 8}} {
+			if a, b := ranges[pair[0]], ranges[pair[1]]; a.AsmEnd >= b.AsmStart {
+				t.Errorf("%s：第 %d 行的范围 %d-%d 与第 %d 行的范围 %d-%d 重叠",
+					target, pair[0], a.AsmStart, a.AsmEnd, pair[1], b.AsmStart, b.AsmEnd)
+			}
+		}
+	}
+	if tested == 0 {
+		t.Fatal("没有支持源代码注释的目标")
+	}
+
+	// 导入了其他文件的程序不支持源代码注释
+	_, err := Compile(context.Background(), "import \"lib.src\";\nprint x;\n", Options{
+		Filename: "main.src",
+		Target:   "nasm",
+		Annotate: true,
+		ReadFile: func(string) ([]byte, error) { return []byte("int x = 1;\n"), nil },
+	})
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("错误 %v，期望 *Error", err)
+	}
+	if d := cerr.Diagnostics[0]; d.Code != msg.GEN_ANNOTATE_MULTI_FILE || d.File != "main.src" {
+		t.Errorf("诊断 %+v，期望 %s", d, msg.GEN_ANNOTATE_MULTI_FILE)
+	}
+}
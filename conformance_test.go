@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"bytes"
+	"compiler/backend"
+	"compiler/bytecode"
+	"compiler/msg"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConformance 编译 code/conformance 中的每个程序，在字节码虚拟机上运行并与 .out 比较，
+// 有 .in 文件时从它读取输入。不合法的输入行会输出一次重新输入的提示，比较前去掉这些提示。
+// 字节码不支持的程序（long.src）只检查代码生成，它的输出由 codegen 包和 riscv 包的 TestConformance 检查。
+// 每个程序还要能用全部目标生成代码，程序用到目标不支持的特性时应报告错误
+func TestConformance(t *testing.T) {
+	sources, err := filepath.Glob("code/conformance/*.src")
+	if err != nil || len(sources) == 0 {
+		t.Fatalf("找不到一致性测试程序：%v", err)
+	}
+	for _, path := range sources {
+		base := strings.TrimSuffix(path, ".src")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(base + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			input, err := os.ReadFile(base + ".in")
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(err)
+			}
+
+			for _, level := range []int{0, 2} {
+				res, err := Compile(context.Background(), string(source), Options{Filename: path, OptLevel: level})
+				if err != nil {
+					t.Fatalf("-O%d：%v", level, err)
+				}
+				if res.IR == nil {
+					if backend.Used(res.AST)&backend.FEATURE_LONG == 0 {
+						t.Fatalf("-O%d：没有生成字节码", level)
+					}
+					continue
+				}
+				var out bytes.Buffer
+				if err := bytecode.NewVM(res.IR, bytes.NewReader(input), &out).Run(); err != nil {
+					t.Fatalf("-O%d：运行出错：%v", level, err)
+				}
+				got := out.String()
+				for _, line := range strings.Split(string(input), "\n") {
+					got = strings.ReplaceAll(got, msg.Get(msg.RT_BAD_INPUT, line), "")
+				}
+				if got != string(want) {
+					t.Errorf("-O%d：输出\n%s\n期望\n%s", level, got, want)
+				}
+			}
+
+			for _, target := range Targets() {
+				b, _ := backend.Lookup(target)
+				res, err := Compile(context.Background(), string(source), Options{Filename: path, Target: target})
+				supported := backend.Used(res.AST)&^b.Features() == 0
+				switch {
+				case supported && err != nil:
+					t.Errorf("%s：%v", target, err)
+				case supported && len(res.Artifacts) == 0:
+					t.Errorf("%s：没有生成代码", target)
+				case !supported && err == nil:
+					t.Errorf("%s：不支持的特性没有报告错误", target)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package compiler
+
+import (
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"errors"
+	"strings"
+)
+
+// Stage 标识产生诊断信息的编译阶段
+type Stage string
+
+const (
+	StageLexer    Stage = "lexer"
+	StageParser   Stage = "parser"
+	StageSemantic Stage = "semantic"
+	StageCodegen  Stage = "codegen"
+)
+
+var stageToTitle = map[Stage]msg.Code{
+	StageLexer:    msg.DIAG_LEXER,
+	StageParser:   msg.DIAG_SYNTAX,
+	StageSemantic: msg.DIAG_SEMANTIC,
+	StageCodegen:  msg.DIAG_CODEGEN,
+}
+
+// Diagnostic 是一条编译诊断信息。Line 为 0 表示没有位置信息。
+// Code 是消息编号（见 msg 包），消息文本在输出时由 Code 和 Args 按语言生成；
+// 无法识别的错误没有编号，只有原文 Text。
+type Diagnostic struct {
+	Stage  Stage
+	Code   msg.Code
+	Args   []interface{}
+	File   string
+	Line   int
+	Column int
+	Text   string
+}
+
+// Message 按语言 lang 生成诊断的消息文本，不含标题和位置
+func (d Diagnostic) Message(lang msg.Lang) string {
+	if d.Text != "" {
+		return d.Text
+	}
+	return msg.Format(lang, d.Code, d.Args...)
+}
+
+// Format 按语言 lang 生成完整的诊断信息
+func (d Diagnostic) Format(lang msg.Lang) string {
+	return msg.FormatDiagnostic(lang, stageToTitle[d.Stage], d.Code, d.File, d.Line, d.Column, d.Message(lang))
+}
+
+// String 按 msg 包的默认语言生成完整的诊断信息
+func (d Diagnostic) String() string {
+	return d.Format(msg.Current())
+}
+
+// Error 表示源程序中存在错误，Diagnostics 中至少有一条
+type Error struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *Error) Error() string {
+	return e.Format(msg.Current())
+}
+
+// Format 按语言 lang 生成全部诊断信息，每行一条
+func (e *Error) Format(lang msg.Lang) string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.Format(lang)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiagnosticFromError 将各阶段（包括 format.Source）返回的错误转换为 file 中的诊断信息
+func DiagnosticFromError(file string, err error) Diagnostic {
+	switch e := err.(type) {
+	case *lexer.LexerError:
+		return Diagnostic{Stage: StageLexer, Code: e.Code, Args: e.Args, File: file, Line: e.Line, Column: e.Column}
+	case *parser.ParseError:
+		return Diagnostic{Stage: StageParser, Code: e.Code, Args: e.Args, File: file, Line: e.Line, Column: e.Column}
+	case *parser.SemanticError:
+		return Diagnostic{Stage: StageSemantic, Code: e.Code, Args: e.Args, File: file, Line: e.Line, Column: e.Column}
+	case *msg.Error:
+		return Diagnostic{Stage: StageCodegen, Code: e.Code, Args: e.Args, File: file}
+	}
+	// 包装过的错误保留原文，只取出其中的编号
+	d := Diagnostic{Stage: StageCodegen, File: file, Text: err.Error()}
+	var me *msg.Error
+	if errors.As(err, &me) {
+		d.Code = me.Code
+	}
+	return d
+}
@@ -0,0 +1,304 @@
+package format
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"strings"
+)
+
+// 运算符优先级，数值越大结合越紧
+const (
+	precComparison = 1
+	precBitOr      = 2
+	precBitXor     = 3
+	precBitAnd     = 4
+	precShift      = 5
+	precSum        = 6
+	precProduct    = 7
+	precUnary      = 8
+	precAtom       = 9
+)
+
+// binaryPrec 是二元运算符的优先级（见 parser.parseExpr）
+var binaryPrec = map[string]int{
+	"|":  precBitOr,
+	"^":  precBitXor,
+	"&":  precBitAnd,
+	"<<": precShift,
+	">>": precShift,
+	"+":  precSum,
+	"-":  precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+	"%":  precProduct,
+}
+
+type printer struct {
+	sb         strings.Builder
+	indent     int
+	comments   []lexer.Comment
+	next       int    // 下一条待输出的注释
+	pending    string // 最后输出的一行（不含换行符），它的行尾注释要到下一行开始时才能确定
+	lastLine   int    // 最近输出内容在源代码中的行号，用于保留空行
+	blockStart bool   // 刚输出左花括号，块开头不保留空行
+}
+
+// Source 格式化一段源代码，保留其中的注释。
+// 只做语法分析，变量未定义等语义错误不影响格式化。
+func Source(source string) (string, error) {
+	l := lexer.NewLexer(source)
+	ast, err := parser.NewParser(l).ParseProgram()
+	if l.HasErrors() {
+		return "", l.GetErrors()[0]
+	}
+	if err != nil {
+		return "", err
+	}
+	return Format(ast, l.Comments()), nil
+}
+
+// Format 将 AST 输出为规范格式的源代码：
+// 四个空格缩进，二元运算符两侧各一个空格，左花括号不换行，
+// 语句之间最多保留一个空行。comments 按源代码位置插回对应的语句前后。
+func Format(ast *parser.AST, comments []lexer.Comment) string {
+	p := &printer{comments: comments}
+	p.block(ast.Statements)
+	p.flushComments(int(^uint(0) >> 1))
+	p.flushLine()
+	return p.sb.String()
+}
+
+func (p *printer) block(stmts []parser.Statement) {
+	for _, stmt := range stmts {
+		p.statement(stmt)
+	}
+}
+
+// line 开始输出新的一行。一行源代码中可能有多条语句，
+// 行尾注释跟在它前面的词法单元所在的那一行之后，所以每行先暂存，等下一行开始时再写出
+func (p *printer) line(s string) {
+	p.flushLine()
+	p.pending = strings.Repeat("    ", p.indent) + s
+}
+
+// flushLine 写出暂存的一行
+func (p *printer) flushLine() {
+	if p.pending != "" {
+		p.sb.WriteString(p.pending)
+		p.sb.WriteString("\n")
+		p.pending = ""
+	}
+}
+
+// begin 开始输出源代码第 line 行的内容：先输出它之前的独占一行的注释，
+// 再按源代码中是否有空行决定是否空一行
+func (p *printer) begin(line int) {
+	p.flushComments(line)
+	p.separate(line)
+}
+
+// separate 源代码中与上一项之间有空行时输出一个空行
+func (p *printer) separate(line int) {
+	if !p.blockStart && p.lastLine > 0 && line > p.lastLine+1 {
+		p.flushLine()
+		p.sb.WriteString("\n")
+	}
+	p.blockStart = false
+	if line > 0 {
+		p.lastLine = line
+	}
+}
+
+// flushComments 输出所有在第 line 行之前的注释。
+// 行尾注释接在暂存的一行之后，即它前面的词法单元所在的那一行，其余注释独占一行
+func (p *printer) flushComments(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Line < line {
+		c := p.comments[p.next]
+		if c.Trailing && p.pending != "" {
+			p.pending += " " + c.Text
+		} else {
+			p.separate(c.Line)
+			p.line(c.Text)
+		}
+		p.next++
+	}
+}
+
+// simple 输出单行语句
+func (p *printer) simple(pos parser.Pos, text string) {
+	p.begin(pos.Line)
+	p.line(text)
+}
+
+func (p *printer) statement(stmt parser.Statement) {
+	switch s := stmt.(type) {
+	case *parser.Assignment, *parser.CompoundAssignment, *parser.IncDecStatement:
+		p.simple(s.Position(), clause(s)+";")
+	case *parser.ConstDeclaration:
+		p.simple(s.Pos, "const "+s.Name+" = "+expr(s.Value, precComparison)+";")
+	case *parser.VarDeclaration:
+		text := s.Type.String() + " " + s.Name
+		if s.Value != nil {
+			text += " = " + expr(s.Value, precComparison)
+		}
+		p.simple(s.Pos, text+";")
+	case *parser.PrintStatement:
+		p.simple(s.Pos, "print "+expr(s.Expr, precComparison)+";")
+	case *parser.InputStatement:
+		p.simple(s.Pos, "input "+s.Ident+";")
+	case *parser.ImportStatement:
+		p.simple(s.Pos, "import \""+s.Path+"\";")
+	case *parser.IfStatement:
+		p.simple(s.Pos, "if ("+expr(s.Condition, precComparison)+") {")
+		p.ifRest(s)
+	case *parser.WhileStatement:
+		p.simple(s.Pos, "while ("+expr(s.Condition, precComparison)+") {")
+		p.indented(s.Body, s.End)
+		p.closeBrace(s.End, "}")
+	case *parser.DoWhileStatement:
+		p.simple(s.Pos, "do {")
+		p.indented(s.Body, s.End)
+		p.closeBrace(s.End, "} while ("+expr(s.Condition, precComparison)+");")
+	case *parser.ForStatement:
+		p.simple(s.Pos, "for ("+forHeader(s)+") {")
+		p.indented(s.Body, s.End)
+		p.closeBrace(s.End, "}")
+	case *parser.SwitchStatement:
+		p.simple(s.Pos, "switch ("+expr(s.Value, precComparison)+") {")
+		p.blockStart = true
+		for i, c := range s.Cases {
+			p.simple(c.Pos, caseLabel(c))
+			// 下一个 case 之前的注释与 case 对齐，由下一个 case 输出
+			end := c.Pos
+			if i == len(s.Cases)-1 {
+				end = s.End
+			}
+			p.indented(c.Body, end)
+		}
+		p.closeBrace(s.End, "}")
+	case *parser.BreakStatement:
+		p.simple(s.Pos, "break;")
+	case *parser.ContinueStatement:
+		p.simple(s.Pos, "continue;")
+	}
+}
+
+// ifRest 输出 if 语句条件之后的部分，else if 链写在右花括号的同一行
+func (p *printer) ifRest(s *parser.IfStatement) {
+	p.indented(s.Then, s.ThenEnd)
+	if nested, ok := elseIf(s); ok {
+		p.closeBrace(s.ThenEnd, "} else if ("+expr(nested.Condition, precComparison)+") {")
+		p.ifRest(nested)
+	} else if len(s.Else) > 0 || p.commentBefore(s.ElseEnd.Line) {
+		// 只有注释的 else 块也要保留，否则注释会移到 if 语句之后
+		p.closeBrace(s.ThenEnd, "} else {")
+		p.indented(s.Else, s.ElseEnd)
+		p.closeBrace(s.ElseEnd, "}")
+	} else {
+		p.closeBrace(s.ThenEnd, "}")
+	}
+}
+
+// commentBefore 报告是否还有在第 line 行之前、尚未输出的注释
+func (p *printer) commentBefore(line int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Line < line
+}
+
+// elseIf 在 s 的 else 分支是 else if 时返回其中的 if 语句
+func elseIf(s *parser.IfStatement) (*parser.IfStatement, bool) {
+	if !s.ElseIf || len(s.Else) != 1 {
+		return nil, false
+	}
+	nested, ok := s.Else[0].(*parser.IfStatement)
+	return nested, ok
+}
+
+// caseLabel 输出 "case 1, N + 1:" 或 "default:"
+func caseLabel(c *parser.CaseClause) string {
+	if len(c.Values) == 0 {
+		return "default:"
+	}
+	values := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		values[i] = expr(v, precComparison)
+	}
+	return "case " + strings.Join(values, ", ") + ":"
+}
+
+// forHeader 输出 for 括号内的部分，省略的部分留空，如 "i = 0; i < 10; i = i + 1"、";;"
+func forHeader(s *parser.ForStatement) string {
+	h := clause(s.Init) + ";"
+	if s.Condition != nil {
+		h += " " + expr(s.Condition, precComparison)
+	}
+	h += ";"
+	if s.Step != nil {
+		h += " " + clause(s.Step)
+	}
+	return h
+}
+
+// clause 输出赋值、复合赋值或自增自减语句（不带分号），也用于 for 的初始化和步进部分
+func clause(stmt parser.Statement) string {
+	switch s := stmt.(type) {
+	case *parser.Assignment:
+		return s.Ident + " = " + expr(s.Value, precComparison)
+	case *parser.CompoundAssignment:
+		return s.Ident + " " + s.Op + "= " + expr(s.Value, precComparison)
+	case *parser.IncDecStatement:
+		if s.Prefix {
+			return s.Op + s.Ident
+		}
+		return s.Ident + s.Op
+	}
+	return ""
+}
+
+// indented 输出块内的语句，以及右花括号 end 之前的注释
+func (p *printer) indented(stmts []parser.Statement, end parser.Pos) {
+	p.indent++
+	p.blockStart = true
+	p.block(stmts)
+	p.flushComments(end.Line)
+	p.indent--
+}
+
+func (p *printer) closeBrace(pos parser.Pos, text string) {
+	p.blockStart = true // 右花括号前不保留空行
+	p.separate(pos.Line)
+	p.line(text)
+}
+
+// expr 输出表达式，优先级低于 minPrec 时加括号
+func expr(e parser.Expr, minPrec int) string {
+	var s string
+	var prec int
+	switch e := e.(type) {
+	case *parser.NumberExpr:
+		s, prec = e.Value, precAtom
+	case *parser.IdentExpr:
+		s, prec = e.Name, precAtom
+	case *parser.BooleanExpr:
+		s, prec = "false", precAtom
+		if e.Value {
+			s = "true"
+		}
+	case *parser.CharExpr:
+		s, prec = lexer.QuoteChar(e.Value), precAtom
+	case *parser.BinaryExpr:
+		prec = binaryPrec[e.Op]
+		// 运算符左结合，右操作数优先级相同时也要加括号
+		s = expr(e.Left, prec) + " " + e.Op + " " + expr(e.Right, prec+1)
+	case *parser.ComparisonExpr:
+		// 比较运算不能连用，两侧都不能是比较
+		prec = precComparison
+		s = expr(e.Left, precBitOr) + " " + e.Op + " " + expr(e.Right, precBitOr)
+	case *parser.UnaryExpr:
+		prec = precUnary
+		s = e.Op + expr(e.Operand, precUnary)
+	}
+	if prec < minPrec {
+		return "(" + s + ")"
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package format
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRoundTrip 格式化全部示例程序：结果再格式化一次不变，语法树和注释也不变
+func TestRoundTrip(t *testing.T) {
+	samples, _ := filepath.Glob("../code/*.src")
+	conformance, _ := filepath.Glob("../code/conformance/*.src")
+	samples = append(samples, conformance...)
+	if len(samples) == 0 {
+		t.Fatal("找不到示例程序")
+	}
+	for _, path := range samples {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source := string(data)
+			once, err := Source(source)
+			if err != nil {
+				t.Fatalf("格式化出错：%v", err)
+			}
+			twice, err := Source(once)
+			if err != nil {
+				t.Fatalf("再次格式化出错：%v", err)
+			}
+			if twice != once {
+				t.Errorf("格式化结果不稳定：\n%s\n再次格式化：\n%s", once, twice)
+			}
+			if got, want := dump(t, once), dump(t, source); got != want {
+				t.Errorf("格式化改变了语法树：\n%s\n原来是：\n%s", got, want)
+			}
+			if got, want := comments(once), comments(source); got != want {
+				t.Errorf("注释 %q，原来是 %q", got, want)
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			"leading_and_trailing",
+			"// head\nint x = 1; // one\n\n\n// before\nprint x;\n",
+			"// head\nint x = 1; // one\n\n// before\nprint x;\n",
+		},
+		{
+			"else_if_one_line",
+			"if (true) {\n    print 1;\n} else if (false) { print 0; } // end\n",
+			"if (true) {\n    print 1;\n} else if (false) {\n    print 0;\n} // end\n",
+		},
+		{
+			"if_one_line",
+			"if (true) { print 1; } else { print 0; } // end\nprint 2;\n",
+			"if (true) {\n    print 1;\n} else {\n    print 0;\n} // end\nprint 2;\n",
+		},
+		{
+			"case_on_switch_line",
+			"int x = 1;\nswitch (x) { case 1, 2: // c1\n    print 1;\n}\n",
+			"int x = 1;\nswitch (x) {\ncase 1, 2: // c1\n    print 1;\n}\n",
+		},
+		{
+			"body_on_case_line",
+			"int x = 1;\nswitch (x) {\n    case 1: print 1; // one\n    default: print 2;\n}\n",
+			"int x = 1;\nswitch (x) {\ncase 1:\n    print 1; // one\ndefault:\n    print 2;\n}\n",
+		},
+		{
+			"else_only_comment",
+			"if (true) {\n} else {\n  // c\n}\nprint 1;\n",
+			"if (true) {\n} else {\n    // c\n}\nprint 1;\n",
+		},
+		{
+			"else_brace_comment",
+			"if (true) { print 1; } else { // c\n}\n",
+			"if (true) {\n    print 1;\n} else { // c\n}\n",
+		},
+		{
+			"else_if_else_only_comment",
+			"if (true) {\n} else if (false) {\n} else {\n    // c\n}\n",
+			"if (true) {\n} else if (false) {\n} else {\n    // c\n}\n",
+		},
+		{
+			"open_and_close_brace",
+			"int i = 0;\nwhile (i < 3) { // loop\n    i++;\n    // last\n} // done\n",
+			"int i = 0;\nwhile (i < 3) { // loop\n    i++;\n    // last\n} // done\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source(tt.source)
+			if err != nil {
+				t.Fatalf("格式化出错：%v", err)
+			}
+			if got != tt.want {
+				t.Errorf("格式化结果：\n%s\n期望：\n%s", got, tt.want)
+			}
+			again, err := Source(got)
+			if err != nil || again != got {
+				t.Errorf("再次格式化结果不同：\n%s", again)
+			}
+		})
+	}
+}
+
+// dump 返回源代码语法树的文本形式，不含位置信息
+func dump(t *testing.T, source string) string {
+	t.Helper()
+	ast, err := parser.NewParser(lexer.NewLexer(source)).ParseProgram()
+	if err != nil {
+		t.Fatalf("解析出错：%v", err)
+	}
+	return parser.Dump(ast)
+}
+
+// comments 返回源代码中全部注释的文本，按出现顺序连接
+func comments(source string) string {
+	l := lexer.NewLexer(source)
+	for l.NextToken().Type != lexer.TOKEN_EOF {
+	}
+	var s string
+	for _, c := range l.Comments() {
+		s += c.Text + "\n"
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// charEscapes 是字符常量中可用的转义序列，\xHH 表示任意 ASCII 字符
+var charEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'\'': '\'',
+}
+
+// UnquoteChar 解出字符常量 'A'、'\n'、'\x41' 的值。字符常量只能是一个 ASCII 字符。
+func UnquoteChar(lit string) (byte, bool) {
+	if len(lit) < 3 || lit[0] != '\'' || lit[len(lit)-1] != '\'' {
+		return 0, false
+	}
+	body := lit[1 : len(lit)-1]
+	switch {
+	case len(body) == 1 && body[0] != '\\' && body[0] != '\'' && body[0] < 0x80:
+		return body[0], true
+	case len(body) == 2 && body[0] == '\\':
+		c, ok := charEscapes[body[1]]
+		return c, ok
+	case len(body) == 4 && body[0] == '\\' && body[1] == 'x':
+		n, err := strconv.ParseUint(body[2:], 16, 8)
+		if err != nil || n >= 0x80 {
+			return 0, false
+		}
+		return byte(n), true
+	}
+	return 0, false
+}
+
+// QuoteChar 返回字符 c 的字符常量写法，是 UnquoteChar 的逆运算
+func QuoteChar(c byte) string {
+	for esc, v := range charEscapes {
+		if v == c {
+			return `'\` + string(esc) + `'`
+		}
+	}
+	if c >= ' ' && c < 0x7F {
+		return "'" + string(c) + "'"
+	}
+	return fmt.Sprintf(`'\x%02X'`, c)
+}
@@ -1,13 +1,28 @@
 package lexer
 
+import "sort"
+
 var keywords = map[string]TokenType{
-	"if":    TOKEN_KEYWORD,
-	"else":  TOKEN_KEYWORD,
-	"while": TOKEN_KEYWORD,
-	"print": TOKEN_KEYWORD,
-	"input": TOKEN_KEYWORD,
-	"true":  TOKEN_KEYWORD,
-	"false": TOKEN_KEYWORD,
+	"if":       TOKEN_KEYWORD,
+	"else":     TOKEN_KEYWORD,
+	"while":    TOKEN_KEYWORD,
+	"print":    TOKEN_KEYWORD,
+	"input":    TOKEN_KEYWORD,
+	"true":     TOKEN_KEYWORD,
+	"false":    TOKEN_KEYWORD,
+	"import":   TOKEN_KEYWORD,
+	"for":      TOKEN_KEYWORD,
+	"break":    TOKEN_KEYWORD,
+	"continue": TOKEN_KEYWORD,
+	"do":       TOKEN_KEYWORD,
+	"switch":   TOKEN_KEYWORD,
+	"case":     TOKEN_KEYWORD,
+	"default":  TOKEN_KEYWORD,
+	"const":    TOKEN_KEYWORD,
+	"int":      TOKEN_KEYWORD,
+	"bool":     TOKEN_KEYWORD,
+	"char":     TOKEN_KEYWORD,
+	"long":     TOKEN_KEYWORD,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -16,3 +31,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return TOKEN_IDENT
 }
+
+// Keywords 返回所有关键字，按字母顺序排列
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
@@ -1,6 +1,9 @@
 package lexer
 
-import "fmt"
+import (
+	"compiler/msg"
+	"strings"
+)
 
 type TokenType int
 
@@ -27,6 +30,24 @@ var tokenTypeToString = map[TokenType]string{
 	TOKEN_NOT_EQUAL:    "NOT_EQUAL",
 	TOKEN_LESS_EQUAL:   "LESS_EQUAL",
 	TOKEN_GREATER_EQUAL: "GREATER_EQUAL",
+	TOKEN_COLON:        "COLON",
+	TOKEN_COMMA:        "COMMA",
+	TOKEN_MODULO:       "MODULO",
+	TOKEN_BIT_AND:      "BIT_AND",
+	TOKEN_BIT_OR:       "BIT_OR",
+	TOKEN_BIT_XOR:      "BIT_XOR",
+	TOKEN_BIT_NOT:      "BIT_NOT",
+	TOKEN_SHIFT_LEFT:   "SHIFT_LEFT",
+	TOKEN_SHIFT_RIGHT:  "SHIFT_RIGHT",
+	TOKEN_PLUS_ASSIGN:     "PLUS_ASSIGN",
+	TOKEN_MINUS_ASSIGN:    "MINUS_ASSIGN",
+	TOKEN_MULTIPLY_ASSIGN: "MULTIPLY_ASSIGN",
+	TOKEN_DIVIDE_ASSIGN:   "DIVIDE_ASSIGN",
+	TOKEN_MODULO_ASSIGN:   "MODULO_ASSIGN",
+	TOKEN_INCREMENT:       "INCREMENT",
+	TOKEN_DECREMENT:       "DECREMENT",
+	TOKEN_STRING:       "STRING",
+	TOKEN_CHAR:         "CHAR",
 	TOKEN_KEYWORD:      "KEYWORD",
 	TOKEN_EOF:          "EOF",
 	TOKEN_ILLEGAL:      "ILLEGAL",
@@ -51,6 +72,24 @@ const (
 	TOKEN_NOT_EQUAL
 	TOKEN_LESS_EQUAL
 	TOKEN_GREATER_EQUAL
+	TOKEN_COLON
+	TOKEN_COMMA
+	TOKEN_MODULO
+	TOKEN_BIT_AND
+	TOKEN_BIT_OR
+	TOKEN_BIT_XOR
+	TOKEN_BIT_NOT
+	TOKEN_SHIFT_LEFT
+	TOKEN_SHIFT_RIGHT
+	TOKEN_PLUS_ASSIGN
+	TOKEN_MINUS_ASSIGN
+	TOKEN_MULTIPLY_ASSIGN
+	TOKEN_DIVIDE_ASSIGN
+	TOKEN_MODULO_ASSIGN
+	TOKEN_INCREMENT
+	TOKEN_DECREMENT
+	TOKEN_STRING
+	TOKEN_CHAR
 	TOKEN_KEYWORD
 	TOKEN_EOF
 	TOKEN_ILLEGAL
@@ -63,24 +102,36 @@ type Token struct {
 	Column  int
 }
 
+// LexerError 是带位置的词法错误，消息由 Code 和 Args 在输出时按语言生成
 type LexerError struct {
-	Line    int
-	Column  int
-	Message string
+	Code   msg.Code
+	Args   []interface{}
+	Line   int
+	Column int
 }
 
 func (e *LexerError) Error() string {
-	return fmt.Sprintf("词法错误：第%d行第%d列: %s", e.Line, e.Column, e.Message)
+	return msg.Diagnostic(msg.DIAG_LEXER, e.Code, "", e.Line, e.Column, msg.Get(e.Code, e.Args...))
+}
+
+// Comment 是源代码中的一条 // 注释，作为附属信息（trivia）保留下来供格式化工具使用
+type Comment struct {
+	Line     int
+	Column   int
+	Text     string // 包含开头的 //，不含换行符
+	Trailing bool   // 同一行中注释前还有词法单元，即行尾注释
 }
 
 type Lexer struct {
-	input   string
-	pos     int
-	readPos int
-	ch      rune
-	line    int
-	column  int
-	errors  []*LexerError
+	input    string
+	pos      int
+	readPos  int
+	ch       rune
+	line     int
+	column   int
+	errors   []*LexerError
+	comments []Comment
+	prev     Token // 上一个词法单元，用于区分负数字面量和减号
 }
 
 func NewLexer(input string) *Lexer {
@@ -95,14 +146,15 @@ func NewLexer(input string) *Lexer {
 }
 
 func (l *Lexer) readChar() {
+	// 越过换行符后才进入下一行
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 	if l.readPos >= len(l.input) {
 		l.ch = 0
 	} else {
 		l.ch = rune(l.input[l.readPos])
-		if l.ch == '\n' {
-			l.line++
-			l.column = 0
-		}
 	}
 	l.pos = l.readPos
 	l.readPos++
@@ -110,27 +162,76 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) NextToken() Token {
-	var tok Token
-	tok.Line = l.line
-	tok.Column = l.column
-
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	tok := l.nextToken()
+	tok.Line = line
+	tok.Column = column
+	l.prev = tok
+	return tok
+}
+
+func (l *Lexer) nextToken() Token {
+	var tok Token
+
 	switch l.ch {
 	case '+':
-		tok = newToken(TOKEN_PLUS, l.ch)
+		if l.peekChar() == '+' {
+			l.readChar()
+			tok = Token{Type: TOKEN_INCREMENT, Literal: "++"}
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: TOKEN_PLUS_ASSIGN, Literal: "+="}
+		} else {
+			tok = newToken(TOKEN_PLUS, l.ch)
+		}
 	case '-':
-		if isDigit(l.peekChar()) {
+		// 只有不跟在操作数之后时 -1 才是负数字面量，x-1 中的 - 是减号
+		if isDigit(l.peekChar()) && !l.afterOperand() {
 			l.readChar()
 			tok.Type = TOKEN_NUMBER
 			tok.Literal = "-" + l.readNumber()
 			return tok
 		}
-		tok = newToken(TOKEN_MINUS, l.ch)
+		if l.peekChar() == '-' {
+			l.readChar()
+			tok = Token{Type: TOKEN_DECREMENT, Literal: "--"}
+		} else if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: TOKEN_MINUS_ASSIGN, Literal: "-="}
+		} else {
+			tok = newToken(TOKEN_MINUS, l.ch)
+		}
 	case '*':
-		tok = newToken(TOKEN_MULTIPLY, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: TOKEN_MULTIPLY_ASSIGN, Literal: "*="}
+		} else {
+			tok = newToken(TOKEN_MULTIPLY, l.ch)
+		}
 	case '/':
-		tok = newToken(TOKEN_DIVIDE, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: TOKEN_DIVIDE_ASSIGN, Literal: "/="}
+		} else {
+			tok = newToken(TOKEN_DIVIDE, l.ch)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: TOKEN_MODULO_ASSIGN, Literal: "%="}
+		} else {
+			tok = newToken(TOKEN_MODULO, l.ch)
+		}
+	case '&':
+		tok = newToken(TOKEN_BIT_AND, l.ch)
+	case '|':
+		tok = newToken(TOKEN_BIT_OR, l.ch)
+	case '^':
+		tok = newToken(TOKEN_BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(TOKEN_BIT_NOT, l.ch)
 	case '=':
 		if l.peekChar() == '=' {
 			l.readChar()
@@ -149,6 +250,9 @@ func (l *Lexer) NextToken() Token {
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = Token{Type: TOKEN_LESS_EQUAL, Literal: "<="}
+		} else if l.peekChar() == '<' {
+			l.readChar()
+			tok = Token{Type: TOKEN_SHIFT_LEFT, Literal: "<<"}
 		} else {
 			tok = newToken(TOKEN_LESS, l.ch)
 		}
@@ -156,11 +260,18 @@ func (l *Lexer) NextToken() Token {
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok = Token{Type: TOKEN_GREATER_EQUAL, Literal: ">="}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = Token{Type: TOKEN_SHIFT_RIGHT, Literal: ">>"}
 		} else {
 			tok = newToken(TOKEN_GREATER, l.ch)
 		}
 	case ';':
 		tok = newToken(TOKEN_SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(TOKEN_COLON, l.ch)
+	case ',':
+		tok = newToken(TOKEN_COMMA, l.ch)
 	case '(':
 		tok = newToken(TOKEN_LPAREN, l.ch)
 	case ')':
@@ -169,6 +280,10 @@ func (l *Lexer) NextToken() Token {
 		tok = newToken(TOKEN_LBRACE, l.ch)
 	case '}':
 		tok = newToken(TOKEN_RBRACE, l.ch)
+	case '"':
+		return l.readString()
+	case '\'':
+		return l.readCharLiteral()
 	case 0:
 		tok.Literal = ""
 		tok.Type = TOKEN_EOF
@@ -183,9 +298,10 @@ func (l *Lexer) NextToken() Token {
 			return tok
 		} else {
 			l.errors = append(l.errors, &LexerError{
-				Line:    l.line,
-				Column:  l.column,
-				Message: fmt.Sprintf("非法字符: %c", l.ch),
+				Code:   msg.LEX_ILLEGAL_CHAR,
+				Args:   []interface{}{l.ch},
+				Line:   l.line,
+				Column: l.column,
 			})
 			tok = newToken(TOKEN_ILLEGAL, l.ch)
 		}
@@ -199,11 +315,15 @@ func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
-	// 跳过注释
+	// 跳过注释，同时记录下来
 	if l.ch == '/' && l.peekChar() == '/' {
+		comment := Comment{Line: l.line, Column: l.column, Trailing: l.prev.Line == l.line}
+		pos := l.pos
 		for l.ch != '\n' && l.ch != 0 {
 			l.readChar()
 		}
+		comment.Text = strings.TrimRight(l.input[pos:l.pos], " \t\r")
+		l.comments = append(l.comments, comment)
 		l.readChar() // 跳过换行符
 		l.skipWhitespace() // 继续跳过空白字符
 	}
@@ -217,6 +337,22 @@ func (l *Lexer) GetErrors() []*LexerError {
 	return l.errors
 }
 
+// Comments 返回目前为止读到的全部注释，按出现顺序排列
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+// afterOperand 报告上一个词法单元能否结束一个操作数
+func (l *Lexer) afterOperand() bool {
+	switch l.prev.Type {
+	case TOKEN_IDENT, TOKEN_NUMBER, TOKEN_CHAR, TOKEN_RPAREN:
+		return true
+	case TOKEN_KEYWORD:
+		return l.prev.Literal == "true" || l.prev.Literal == "false"
+	}
+	return false
+}
+
 func isLetter(ch rune) bool {
 	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
 }
@@ -233,6 +369,54 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.pos]
 }
 
+// readString 读取双引号括起的字符串，字面量不含引号。字符串不能跨行，也没有转义字符。
+func (l *Lexer) readString() Token {
+	line, column := l.line, l.column
+	l.readChar()
+	pos := l.pos
+	for l.ch != '"' {
+		if l.ch == '\n' || l.ch == 0 {
+			l.errors = append(l.errors, &LexerError{
+				Code:   msg.LEX_UNTERMINATED_STRING,
+				Line:   line,
+				Column: column,
+			})
+			return Token{Type: TOKEN_ILLEGAL, Literal: l.input[pos-1 : l.pos]}
+		}
+		l.readChar()
+	}
+	tok := Token{Type: TOKEN_STRING, Literal: l.input[pos:l.pos]}
+	l.readChar()
+	return tok
+}
+
+// readCharLiteral 读取单引号括起的字符常量，字面量保留两端的引号，值由 UnquoteChar 解出
+func (l *Lexer) readCharLiteral() Token {
+	line, column := l.line, l.column
+	pos := l.pos
+	l.readChar()
+	for l.ch != '\'' && l.ch != '\n' && l.ch != 0 {
+		if l.ch == '\\' {
+			l.readChar()
+		}
+		l.readChar()
+	}
+	if l.ch == '\'' {
+		l.readChar()
+	}
+	lit := l.input[pos:l.pos]
+	if _, ok := UnquoteChar(lit); !ok {
+		l.errors = append(l.errors, &LexerError{
+			Code:   msg.LEX_BAD_CHAR,
+			Args:   []interface{}{lit},
+			Line:   line,
+			Column: column,
+		})
+		return Token{Type: TOKEN_ILLEGAL, Literal: lit}
+	}
+	return Token{Type: TOKEN_CHAR, Literal: lit}
+}
+
 func (l *Lexer) readNumber() string {
 	pos := l.pos
 	for isDigit(l.ch) {
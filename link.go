@@ -0,0 +1,214 @@
+package compiler
+
+import (
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// linker 展开 import，把多个源文件合并为一个程序。
+// 每个文件只在第一次被导入的位置展开一次；import 路径相对于导入它的文件。
+type linker struct {
+	readFile    func(name string) ([]byte, error)
+	files       []string        // 已载入的文件，按载入顺序
+	loaded      map[string]bool // 已载入文件的绝对路径
+	stmts       []parser.Statement
+	owners      []string // owners[i] 是 stmts[i] 所在的文件
+	diagnostics []Diagnostic
+}
+
+func newLinker(readFile func(name string) ([]byte, error)) *linker {
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	return &linker{readFile: readFile, loaded: make(map[string]bool)}
+}
+
+// load 解析文件 name 的源代码 source 并展开其中的 import。
+// stack 是正在展开的导入链，用于发现循环导入。
+func (ln *linker) load(name, source string, stack []string) {
+	ln.files = append(ln.files, name)
+	ln.loaded[absPath(name)] = true
+	stack = append(stack, name)
+
+	l := lexer.NewLexer(source)
+	p := parser.NewParser(l)
+	p.SetFile(len(ln.files) - 1)
+	ast, err := p.ParseProgram()
+	// 与单文件编译相同，有词法错误时只报告词法错误
+	if l.HasErrors() {
+		for _, e := range l.GetErrors() {
+			ln.diagnostics = append(ln.diagnostics, DiagnosticFromError(name, e))
+		}
+		return
+	}
+	if err != nil {
+		ln.diagnostics = append(ln.diagnostics, DiagnosticFromError(name, err))
+		return
+	}
+
+	for _, stmt := range ast.Statements {
+		imp, ok := stmt.(*parser.ImportStatement)
+		if !ok {
+			ln.stmts = append(ln.stmts, stmt)
+			ln.owners = append(ln.owners, name)
+			continue
+		}
+		path := filepath.Join(filepath.Dir(name), filepath.FromSlash(imp.Path))
+		if i := indexOfFile(stack, path); i >= 0 {
+			chain := append(importChain(nil), stack[i:]...)
+			ln.errorAt(name, imp.Pos, msg.SEM_IMPORT_CYCLE, append(chain, path))
+			continue
+		}
+		if ln.loaded[absPath(path)] {
+			continue
+		}
+		data, err := ln.readFile(path)
+		if err != nil {
+			ln.errorAt(name, imp.Pos, msg.SEM_IMPORT_READ, path, err)
+			continue
+		}
+		ln.load(path, string(data), stack)
+	}
+}
+
+func (ln *linker) errorAt(file string, pos parser.Pos, code msg.Code, args ...interface{}) {
+	ln.diagnostics = append(ln.diagnostics, Diagnostic{
+		Stage:  StageSemantic,
+		Code:   code,
+		Args:   args,
+		File:   file,
+		Line:   pos.Line,
+		Column: pos.Column,
+	})
+}
+
+// check 按执行顺序对合并后的程序做语义检查，并确定每个变量属于哪个文件：
+// 变量属于第一次给它赋值（或 input）的文件，其他文件只能读取它。
+func (ln *linker) check() map[string]string {
+	owners := make(map[string]string)
+	checker := parser.NewChecker(nil)
+	for i, stmt := range ln.stmts {
+		file := ln.owners[i]
+		if err := checker.CheckStatement(stmt); err != nil {
+			ln.diagnostics = append(ln.diagnostics, DiagnosticFromError(file, err))
+			return owners
+		}
+		for _, def := range definitions(stmt) {
+			owner, ok := owners[def.name]
+			if !ok {
+				owners[def.name] = file
+				continue
+			}
+			if owner != file {
+				ln.errorAt(file, def.pos, msg.SEM_VAR_OWNER, def.name, fileName(owner), fileName(file))
+				return owners
+			}
+		}
+	}
+	return owners
+}
+
+// fileName 是诊断信息中的文件名，没有文件名的主程序按诊断的语言显示为“主程序”
+type fileName string
+
+func (f fileName) Localize(lang msg.Lang) string {
+	if f == "" {
+		return msg.Format(lang, msg.DIAG_MAIN_FILE)
+	}
+	return string(f)
+}
+
+// importChain 是诊断信息中的循环导入链，如 "主程序 -> a.src -> 主程序"
+type importChain []string
+
+func (c importChain) Localize(lang msg.Lang) string {
+	names := make([]string, len(c))
+	for i, file := range c {
+		names[i] = fileName(file).Localize(lang)
+	}
+	return strings.Join(names, " -> ")
+}
+
+type definition struct {
+	name string
+	pos  parser.Pos
+}
+
+// definitions 返回语句（包括嵌套的语句）中被声明、赋值或 input 的变量
+func definitions(stmt parser.Statement) []definition {
+	switch s := stmt.(type) {
+	case *parser.VarDeclaration:
+		return []definition{{s.Name, s.Pos}}
+	case *parser.Assignment:
+		return []definition{{s.Ident, s.Pos}}
+	case *parser.CompoundAssignment:
+		return []definition{{s.Ident, s.Pos}}
+	case *parser.IncDecStatement:
+		return []definition{{s.Ident, s.Pos}}
+	case *parser.InputStatement:
+		return []definition{{s.Ident, s.Pos}}
+	case *parser.IfStatement:
+		var defs []definition
+		for _, stmt := range s.Then {
+			defs = append(defs, definitions(stmt)...)
+		}
+		for _, stmt := range s.Else {
+			defs = append(defs, definitions(stmt)...)
+		}
+		return defs
+	case *parser.WhileStatement:
+		var defs []definition
+		for _, stmt := range s.Body {
+			defs = append(defs, definitions(stmt)...)
+		}
+		return defs
+	case *parser.DoWhileStatement:
+		var defs []definition
+		for _, stmt := range s.Body {
+			defs = append(defs, definitions(stmt)...)
+		}
+		return defs
+	case *parser.SwitchStatement:
+		var defs []definition
+		for _, c := range s.Cases {
+			for _, stmt := range c.Body {
+				defs = append(defs, definitions(stmt)...)
+			}
+		}
+		return defs
+	case *parser.ForStatement:
+		var defs []definition
+		if s.Init != nil {
+			defs = append(defs, definitions(s.Init)...)
+		}
+		for _, stmt := range s.Body {
+			defs = append(defs, definitions(stmt)...)
+		}
+		if s.Step != nil {
+			defs = append(defs, definitions(s.Step)...)
+		}
+		return defs
+	}
+	return nil
+}
+
+func indexOfFile(stack []string, path string) int {
+	abs := absPath(path)
+	for i, name := range stack {
+		if absPath(name) == abs {
+			return i
+		}
+	}
+	return -1
+}
+
+func absPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
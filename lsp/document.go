@@ -0,0 +1,272 @@
+package lsp
+
+import (
+	"compiler"
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"context"
+	"net/url"
+	"strings"
+)
+
+// document 是一个打开的源文件及其分析结果。
+// 变量信息直接从词法单元得到，源文件有语法错误时也能跳转和悬停。
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	tokens      []lexer.Token
+	vars        map[string]*variable
+	order       []string // 变量按定义出现的顺序
+	diagnostics []Diagnostic
+}
+
+// variable 记录一个变量的定义位置和所有出现位置
+type variable struct {
+	name string
+	def  *lexer.Token // 声明、第一次赋值或 input 的位置，未定义时为 nil
+	typ  string       // 声明的类型，没有声明时为空
+	refs []lexer.Token
+}
+
+// detail 返回补全和符号列表中显示的变量类型
+func (v *variable) detail() string {
+	if v.typ == "" || v.typ == "int" {
+		return "int16"
+	}
+	if v.typ == "long" {
+		return "int32"
+	}
+	return v.typ
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:   uri,
+		text:  text,
+		lines: strings.Split(text, "\n"),
+		vars:  make(map[string]*variable),
+	}
+	l := lexer.NewLexer(text)
+	for tok := l.NextToken(); tok.Type != lexer.TOKEN_EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.collectVars()
+	d.diagnose()
+	return d
+}
+
+func (d *document) collectVars() {
+	for i, tok := range d.tokens {
+		if tok.Type != lexer.TOKEN_IDENT {
+			continue
+		}
+		v, ok := d.vars[tok.Literal]
+		if !ok {
+			v = &variable{name: tok.Literal}
+			d.vars[tok.Literal] = v
+		}
+		v.refs = append(v.refs, tok)
+		if v.def != nil {
+			continue
+		}
+		// int x;、x = ... 和 input x; 定义变量
+		var prev lexer.Token
+		if i > 0 {
+			prev = d.tokens[i-1]
+		}
+		_, declared := parser.LookupType(prev.Literal)
+		declared = declared && prev.Type == lexer.TOKEN_KEYWORD
+		assigned := i+1 < len(d.tokens) && d.tokens[i+1].Type == lexer.TOKEN_ASSIGN
+		inputted := prev.Type == lexer.TOKEN_KEYWORD && prev.Literal == "input"
+		if declared {
+			v.typ = prev.Literal
+		}
+		if declared || assigned || inputted {
+			v.def = &v.refs[len(v.refs)-1]
+			d.order = append(d.order, tok.Literal)
+		}
+	}
+}
+
+// diagnose 运行编译器的词法、语法和语义检查
+func (d *document) diagnose() {
+	d.diagnostics = []Diagnostic{}
+	// 文件名用于解析 import 的相对路径
+	path := uriToPath(d.uri)
+	res, err := compiler.Compile(context.Background(), d.text, compiler.Options{Filename: path})
+	if err == nil {
+		return
+	}
+	for _, diag := range res.Diagnostics {
+		r, message := d.rangeAt(diag.Line, diag.Column), diag.Message(msg.Current())
+		// 导入的文件中的错误显示在文档开头
+		if diag.File != path {
+			r, message = Range{}, diag.File+": "+message
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    r,
+			Severity: SeverityError,
+			Code:     string(diag.Code),
+			Source:   "compiler",
+			Message:  message,
+		})
+	}
+}
+
+// uriToPath 把 file:// URI 转换为本地路径，其他 URI 返回空串
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// rangeAt 返回源代码第 line 行第 column 列（从 1 开始）处词法单元的范围，
+// 那里没有词法单元时返回一个字符宽的范围
+func (d *document) rangeAt(line, column int) Range {
+	if line <= 0 {
+		return Range{}
+	}
+	for _, tok := range d.tokens {
+		if tok.Line == line && tok.Column == column && tok.Literal != "" {
+			return d.tokenRange(tok)
+		}
+	}
+	start := d.position(line, column)
+	end := start
+	end.Character++
+	return Range{Start: start, End: end}
+}
+
+func (d *document) tokenRange(tok lexer.Token) Range {
+	width := len(tok.Literal)
+	if tok.Type == lexer.TOKEN_STRING {
+		width += 2 // 两端的引号
+	}
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+width),
+	}
+}
+
+// position 把词法分析器的位置（从 1 开始，按字节计数）转换为 LSP 位置
+func (d *document) position(line, column int) Position {
+	if line > len(d.lines) {
+		return Position{Line: line - 1}
+	}
+	text := d.lines[line-1]
+	end := min(column-1, len(text))
+	character := 0
+	for _, r := range text[:end] {
+		character++
+		if r >= 0x10000 { // 需要两个 UTF-16 编码单元
+			character++
+		}
+	}
+	return Position{Line: line - 1, Character: character}
+}
+
+// tokenAt 返回覆盖 LSP 位置 pos 的词法单元
+func (d *document) tokenAt(pos Position) (lexer.Token, bool) {
+	for _, tok := range d.tokens {
+		r := d.tokenRange(tok)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return tok, true
+		}
+	}
+	return lexer.Token{}, false
+}
+
+// end 返回文档末尾的位置
+func (d *document) end() Position {
+	last := len(d.lines)
+	return d.position(last, len(d.lines[last-1])+1)
+}
+
+func (d *document) hover(pos Position) *Hover {
+	tok, ok := d.tokenAt(pos)
+	if !ok {
+		return nil
+	}
+	var text string
+	switch tok.Type {
+	case lexer.TOKEN_IDENT:
+		v := d.vars[tok.Literal]
+		if v.def == nil {
+			text = msg.Get(msg.LSP_VAR_UNDEFINED, v.name)
+		} else {
+			text = msg.Get(msg.LSP_VAR_INFO, v.name, v.def.Line, len(v.refs))
+		}
+	case lexer.TOKEN_KEYWORD:
+		text = msg.Get(msg.LSP_KEYWORD, tok.Literal, msg.Get(keywordDocs[tok.Literal]))
+	default:
+		return nil
+	}
+	r := d.tokenRange(tok)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+var keywordDocs = map[string]msg.Code{
+	"if":       msg.LSP_DOC_IF,
+	"else":     msg.LSP_DOC_ELSE,
+	"while":    msg.LSP_DOC_WHILE,
+	"print":    msg.LSP_DOC_PRINT,
+	"input":    msg.LSP_DOC_INPUT,
+	"true":     msg.LSP_DOC_TRUE,
+	"false":    msg.LSP_DOC_FALSE,
+	"import":   msg.LSP_DOC_IMPORT,
+	"for":      msg.LSP_DOC_FOR,
+	"break":    msg.LSP_DOC_BREAK,
+	"continue": msg.LSP_DOC_CONTINUE,
+	"do":       msg.LSP_DOC_DO,
+	"switch":   msg.LSP_DOC_SWITCH,
+	"case":     msg.LSP_DOC_CASE,
+	"default":  msg.LSP_DOC_DEFAULT,
+	"const":    msg.LSP_DOC_CONST,
+	"int":      msg.LSP_DOC_INT,
+	"bool":     msg.LSP_DOC_BOOL,
+	"char":     msg.LSP_DOC_CHAR,
+	"long":     msg.LSP_DOC_LONG,
+}
+
+func (d *document) definition(pos Position) *Location {
+	tok, ok := d.tokenAt(pos)
+	if !ok || tok.Type != lexer.TOKEN_IDENT {
+		return nil
+	}
+	v := d.vars[tok.Literal]
+	if v.def == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.tokenRange(*v.def)}
+}
+
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, name := range d.order {
+		v := d.vars[name]
+		r := d.tokenRange(*v.def)
+		symbols = append(symbols, DocumentSymbol{
+			Name:           name,
+			Detail:         v.detail(),
+			Kind:           SymbolKindVariable,
+			Range:          r,
+			SelectionRange: r,
+		})
+	}
+	return symbols
+}
+
+func (d *document) completion() []CompletionItem {
+	items := []CompletionItem{}
+	for _, word := range lexer.Keywords() {
+		items = append(items, CompletionItem{Label: word, Kind: CompletionKindKeyword, Detail: msg.Get(msg.LSP_KEYWORD_LABEL)})
+	}
+	for _, name := range d.order {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionKindVariable, Detail: d.vars[name].detail()})
+	}
+	return items
+}
@@ -0,0 +1,228 @@
+// Package lsp 实现一个通过标准输入输出通信的语言服务器（Language Server Protocol），
+// 为编辑器提供实时诊断、悬停提示、跳转到定义、文档符号、关键字补全和格式化。
+package lsp
+
+import (
+	"bufio"
+	"compiler/format"
+	"compiler/msg"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Server 是语言服务器，一个 Server 服务一个编辑器连接
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer 创建从 in 读取请求、向 out 写出响应的语言服务器
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Run 处理请求直到收到 exit 通知或输入结束。
+// 按协议要求，没有先收到 shutdown 请求就退出时返回错误。
+func (s *Server) Run() error {
+	for {
+		data, err := s.read()
+		if err == io.EOF {
+			return msg.Errorf(msg.LSP_DISCONNECTED)
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return msg.Errorf(msg.LSP_EXIT_NO_SHUTDOWN)
+			}
+			return nil
+		}
+		s.handle(&req)
+	}
+}
+
+// read 读取一条消息：若干行头部，一个空行，然后是 Content-Length 字节的内容
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, msg.Errorf(msg.LSP_BAD_LENGTH, header.Get("Content-Length"))
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *Server) write(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, err *responseError) {
+	// 出错时不能带 result 字段，成功时 result 为 null 也必须出现
+	if err != nil {
+		s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(req *request) {
+	result, err := s.dispatch(req)
+	// 通知不需要回复
+	if req.ID == nil {
+		return
+	}
+	s.reply(req.ID, result, err)
+}
+
+func (s *Server) dispatch(req *request) (any, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: msg.Get(msg.LSP_SHUT_DOWN)}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           1, // 每次修改发送完整文档
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentSymbolProvider":     true,
+				"completionProvider":         map[string]any{},
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "compiler"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		// 关闭后清空该文件的诊断
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+		return nil, nil
+
+	case "textDocument/hover", "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		if req.Method == "textDocument/hover" {
+			return doc.hover(params.Position), nil
+		}
+		return doc.definition(params.Position), nil
+	case "textDocument/documentSymbol", "textDocument/completion", "textDocument/formatting":
+		var params DocumentParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		switch req.Method {
+		case "textDocument/documentSymbol":
+			return doc.symbols(), nil
+		case "textDocument/completion":
+			return doc.completion(), nil
+		}
+		return formatEdits(doc), nil
+	}
+
+	if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: msg.Get(msg.LSP_UNKNOWN_METHOD, req.Method)}
+}
+
+func unmarshalParams(req *request, v any) *responseError {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update 重新分析文档并发布诊断
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// formatEdits 用格式化结果替换整个文档；有语法错误或已经规范时不做修改
+func formatEdits(doc *document) []TextEdit {
+	formatted, err := format.Source(doc.text)
+	if err != nil || formatted == doc.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{
+		Range:   Range{Start: Position{}, End: doc.end()},
+		NewText: formatted,
+	}}
+}
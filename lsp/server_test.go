@@ -0,0 +1,275 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// message 是客户端收到的一条消息：响应或服务器发出的通知
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// client 通过内存管道与在另一个 goroutine 中运行的 Server 通信
+type client struct {
+	t      *testing.T
+	w      *io.PipeWriter
+	msgs   chan message
+	done   chan error // Run 的返回值
+	nextID int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, w: clientOut, msgs: make(chan message, 16), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	go c.readLoop(bufio.NewReader(clientIn))
+	t.Cleanup(func() {
+		clientOut.Close()
+		clientIn.Close()
+	})
+	return c
+}
+
+// readLoop 按 Content-Length 分帧读取服务器的输出
+func (c *client) readLoop(r *bufio.Reader) {
+	defer close(c.msgs)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			c.t.Errorf("服务器输出了非法的 JSON：%s", data)
+			return
+		}
+		c.msgs <- m
+	}
+}
+
+func (c *client) send(id *int, method string, params any) {
+	c.t.Helper()
+	body := map[string]any{"jsonrpc": "2.0", "method": method}
+	if id != nil {
+		body["id"] = *id
+	}
+	if params != nil {
+		body["params"] = params
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("发送 %s 失败：%v", method, err)
+	}
+}
+
+// notify 发送通知
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(nil, method, params)
+}
+
+// call 发送请求并等待响应，把结果解码到 result 中
+func (c *client) call(method string, params any, result any) {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.send(&id, method, params)
+	m := c.receive()
+	if m.ID == nil || *m.ID != id {
+		c.t.Fatalf("%s：收到 %+v，期望 id 为 %d 的响应", method, m, id)
+	}
+	if m.Error != nil {
+		c.t.Fatalf("%s：错误 %d %s", method, m.Error.Code, m.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(m.Result, result); err != nil {
+			c.t.Fatalf("%s：无法解码结果 %s：%v", method, m.Result, err)
+		}
+	}
+}
+
+// receive 等待下一条消息
+func (c *client) receive() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("服务器已关闭输出")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("等待服务器消息超时")
+	}
+	return message{}
+}
+
+// diagnostics 等待 uri 的诊断通知
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	m := c.receive()
+	if m.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("收到 %+v，期望 publishDiagnostics", m)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(m.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	if params.URI != uri {
+		c.t.Fatalf("诊断属于 %s，期望 %s", params.URI, uri)
+	}
+	return params.Diagnostics
+}
+
+// open 打开文档并返回它的诊断
+func (c *client) open(uri, text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: text},
+	})
+	return c.diagnostics(uri)
+}
+
+// wait 等待 Run 返回
+func (c *client) wait() error {
+	c.t.Helper()
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("等待服务器退出超时")
+	}
+	return nil
+}
+
+func TestServerSession(t *testing.T) {
+	c := newClient(t)
+
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.call("initialize", map[string]any{"processId": nil, "capabilities": map[string]any{}}, &init)
+	for _, name := range []string{"hoverProvider", "definitionProvider", "documentSymbolProvider", "completionProvider", "documentFormattingProvider"} {
+		if init.Capabilities[name] == nil {
+			t.Errorf("initialize 没有声明 %s", name)
+		}
+	}
+	c.notify("initialized", map[string]any{})
+
+	const uri = "file:///tmp/main.src"
+	diags := c.open(uri, "int count = 1;\ncount = count + 1;\nprint count;\nprint missing;\n")
+	if len(diags) != 1 {
+		t.Fatalf("诊断 %+v，期望一条", diags)
+	}
+	if d := diags[0]; d.Range.Start.Line != 3 || d.Severity != SeverityError || d.Code == "" {
+		t.Errorf("诊断 %+v，期望第 4 行带编号的错误", d)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 2, Character: 8},
+	}, &hover)
+	if !strings.Contains(hover.Contents.Value, "count") {
+		t.Errorf("悬停内容 %q 没有提到 count", hover.Contents.Value)
+	}
+
+	var loc Location
+	c.call("textDocument/definition", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 1, Character: 10},
+	}, &loc)
+	want := Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 9}}
+	if loc.URI != uri || loc.Range != want {
+		t.Errorf("定义位置 %+v，期望 %s %+v", loc, uri, want)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "count" || symbols[0].Kind != SymbolKindVariable {
+		t.Errorf("文档符号 %+v，期望只有变量 count", symbols)
+	}
+
+	var items []CompletionItem
+	c.call("textDocument/completion", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 3, Character: 0},
+	}, &items)
+	kinds := make(map[string]int)
+	for _, item := range items {
+		kinds[item.Label] = item.Kind
+	}
+	if kinds["while"] != CompletionKindKeyword || kinds["count"] != CompletionKindVariable {
+		t.Errorf("补全项 %+v，期望包含关键字 while 和变量 count", items)
+	}
+
+	const messy = "file:///tmp/messy.src"
+	c.open(messy, "int x=1;\nif (x>0) {\nprint   x;}\n")
+	var edits []TextEdit
+	c.call("textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: messy}}, &edits)
+	if len(edits) != 1 || edits[0].NewText != "int x = 1;\nif (x > 0) {\n    print x;\n}\n" {
+		t.Errorf("格式化结果 %+v", edits)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: messy}})
+	if diags := c.diagnostics(messy); len(diags) != 0 {
+		t.Errorf("关闭后诊断 %+v，期望清空", diags)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("shutdown 后 exit 返回 %v", err)
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	c.notify("exit", nil)
+	if err := c.wait(); err == nil {
+		t.Error("没有 shutdown 就 exit 应返回错误")
+	}
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	c := newClient(t)
+	id := 7
+	c.send(&id, "workspace/nothing", map[string]any{})
+	m := c.receive()
+	if m.Error == nil || m.Error.Code != codeMethodNotFound {
+		t.Errorf("响应 %+v，期望 MethodNotFound", m)
+	}
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("exit 返回 %v", err)
+	}
+}
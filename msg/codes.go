@@ -0,0 +1,274 @@
+package msg
+
+// 消息编号一经发布就不再改变含义，删除的编号不再复用
+
+// 诊断信息格式
+const (
+	DIAG_LEXER         Code = "D001"
+	DIAG_SYNTAX        Code = "D002"
+	DIAG_SEMANTIC      Code = "D003"
+	DIAG_CODEGEN       Code = "D004"
+	DIAG_SEPARATOR     Code = "D005"
+	DIAG_FILE_POSITION Code = "D006"
+	DIAG_POSITION      Code = "D007"
+	DIAG_MAIN_FILE     Code = "D008"
+	DIAG_RUNTIME       Code = "D009"
+)
+
+// 词法错误
+const (
+	LEX_ILLEGAL_CHAR        Code = "L001"
+	LEX_UNTERMINATED_STRING Code = "L002"
+	LEX_BAD_CHAR            Code = "L003"
+)
+
+// 语法错误
+const (
+	PARSE_TRAILING_INPUT       Code = "P001"
+	PARSE_UNKNOWN_STATEMENT    Code = "P002"
+	PARSE_IMPORT_NOT_TOP_LEVEL Code = "P003"
+	PARSE_IMPORT_NEEDS_PATH    Code = "P004"
+	PARSE_IMPORT_EMPTY_PATH    Code = "P005"
+	PARSE_IMPORT_SEMICOLON     Code = "P006"
+	PARSE_ASSIGN_MISSING_EQUAL Code = "P007"
+	PARSE_ASSIGN_SEMICOLON     Code = "P008"
+	PARSE_PRINT_SEMICOLON      Code = "P009"
+	PARSE_INPUT_NEEDS_IDENT    Code = "P010"
+	PARSE_INPUT_SEMICOLON      Code = "P011"
+	PARSE_IF_LPAREN            Code = "P012"
+	PARSE_IF_RPAREN            Code = "P013"
+	PARSE_IF_LBRACE            Code = "P014"
+	PARSE_IF_RBRACE            Code = "P015"
+	PARSE_ELSE_LBRACE          Code = "P016"
+	PARSE_ELSE_RBRACE          Code = "P017"
+	PARSE_WHILE_LPAREN         Code = "P018"
+	PARSE_WHILE_RPAREN         Code = "P019"
+	PARSE_WHILE_LBRACE         Code = "P020"
+	PARSE_WHILE_RBRACE         Code = "P021"
+	PARSE_ILLEGAL_KEYWORD      Code = "P022"
+	PARSE_MISSING_RPAREN       Code = "P023"
+	PARSE_ILLEGAL_EXPR         Code = "P024"
+	PARSE_FOR_LPAREN           Code = "P025"
+	PARSE_FOR_SEMICOLON        Code = "P026"
+	PARSE_FOR_RPAREN           Code = "P027"
+	PARSE_FOR_LBRACE           Code = "P028"
+	PARSE_FOR_RBRACE           Code = "P029"
+	PARSE_FOR_CLAUSE           Code = "P030"
+	PARSE_BREAK_SEMICOLON      Code = "P031"
+	PARSE_CONTINUE_SEMICOLON   Code = "P032"
+	PARSE_DO_LBRACE            Code = "P033"
+	PARSE_DO_RBRACE            Code = "P034"
+	PARSE_DO_WHILE             Code = "P035"
+	PARSE_DO_LPAREN            Code = "P036"
+	PARSE_DO_RPAREN            Code = "P037"
+	PARSE_DO_SEMICOLON         Code = "P038"
+	PARSE_SWITCH_LPAREN        Code = "P039"
+	PARSE_SWITCH_RPAREN        Code = "P040"
+	PARSE_SWITCH_LBRACE        Code = "P041"
+	PARSE_SWITCH_RBRACE        Code = "P042"
+	PARSE_SWITCH_CASE          Code = "P043"
+	PARSE_CASE_VALUE           Code = "P044"
+	PARSE_CASE_COLON           Code = "P045"
+	PARSE_INCDEC_IDENT         Code = "P046"
+	PARSE_CONST_NOT_TOP_LEVEL  Code = "P047"
+	PARSE_CONST_NAME           Code = "P048"
+	PARSE_CONST_EQUAL          Code = "P049"
+	PARSE_CONST_SEMICOLON      Code = "P050"
+	PARSE_DECL_NAME            Code = "P051"
+	PARSE_DECL_SEMICOLON       Code = "P052"
+)
+
+// 语义错误
+const (
+	SEM_UNDEFINED_VAR     Code = "S001"
+	SEM_IMPORT_UNLINKED   Code = "S002"
+	SEM_IMPORT_CYCLE      Code = "S003"
+	SEM_IMPORT_READ       Code = "S004"
+	SEM_VAR_OWNER         Code = "S005"
+	SEM_BREAK_OUTSIDE     Code = "S006"
+	SEM_CONTINUE_OUTSIDE  Code = "S007"
+	SEM_DUPLICATE_CASE    Code = "S008"
+	SEM_DUPLICATE_DEFAULT Code = "S009"
+	SEM_CASE_RANGE        Code = "S010"
+	SEM_NOT_CONSTANT      Code = "S011"
+	SEM_CONST_ASSIGN      Code = "S012"
+	SEM_CONST_REDECLARED  Code = "S013"
+	SEM_CONST_IS_VAR      Code = "S014"
+	SEM_CONST_DIV_ZERO    Code = "S015"
+	SEM_NUMBER_RANGE      Code = "S016"
+	SEM_REDECLARED        Code = "S017"
+	SEM_TYPE_ASSIGN       Code = "S018"
+	SEM_TYPE_OPERAND      Code = "S019"
+	SEM_TYPE_COMPARE      Code = "S020"
+	SEM_TYPE_ORDER        Code = "S021"
+	SEM_TYPE_CONDITION    Code = "S022"
+	SEM_TYPE_SWITCH       Code = "S023"
+	SEM_TYPE_CASE         Code = "S024"
+	SEM_TYPE_INPUT        Code = "S025"
+	SEM_CONST_LONG        Code = "S026"
+	SEM_LONG_RANGE        Code = "S027"
+)
+
+// 代码生成
+const (
+	GEN_UNKNOWN_TARGET       Code = "G001"
+	GEN_UNSUPPORTED_FEATURES Code = "G002"
+	GEN_NO_ANNOTATE          Code = "G003"
+	GEN_ANNOTATE_MULTI_FILE  Code = "G004"
+	GEN_UNKNOWN_DIALECT      Code = "G005"
+	GEN_NO_OVERFLOW_TRAP     Code = "G006"
+
+	// 语言特性的名字，用于 GEN_UNSUPPORTED_FEATURES
+	GEN_FEATURE_PRINT      Code = "G100"
+	GEN_FEATURE_INPUT      Code = "G101"
+	GEN_FEATURE_IF         Code = "G102"
+	GEN_FEATURE_WHILE      Code = "G103"
+	GEN_FEATURE_DIVISION   Code = "G104"
+	GEN_FEATURE_COMPARISON Code = "G105"
+	GEN_FEATURE_BOOLEAN    Code = "G106"
+	GEN_FEATURE_FOR        Code = "G107"
+	GEN_FEATURE_BREAK      Code = "G108"
+	GEN_FEATURE_DO_WHILE   Code = "G109"
+	GEN_FEATURE_SWITCH     Code = "G110"
+	GEN_FEATURE_BITWISE    Code = "G111"
+	GEN_FEATURE_CONST      Code = "G112"
+	GEN_FEATURE_CHAR       Code = "G113"
+	GEN_FEATURE_LONG       Code = "G114"
+)
+
+// 字节码编译和 .bc 文件格式
+const (
+	BC_CODE_TOO_LONG          Code = "B001"
+	BC_UNSUPPORTED_STATEMENT  Code = "B002"
+	BC_NUMBER_RANGE           Code = "B003"
+	BC_UNSUPPORTED_OPERATOR   Code = "B004"
+	BC_UNSUPPORTED_COMPARISON Code = "B005"
+	BC_UNSUPPORTED_EXPR       Code = "B006"
+
+	BC_TOO_MANY      Code = "B101"
+	BC_NAME_TOO_LONG Code = "B102"
+	BC_BAD_HEADER    Code = "B103"
+	BC_BAD_MAGIC     Code = "B104"
+	BC_BAD_VERSION   Code = "B105"
+	BC_BAD_CONSTANTS Code = "B106"
+	BC_BAD_GLOBALS   Code = "B107"
+	BC_BAD_CODE      Code = "B108"
+
+	// 反汇编输出中的标题
+	BC_DIS_CONSTANTS Code = "B201"
+	BC_DIS_GLOBALS   Code = "B202"
+)
+
+// 虚拟机运行时错误
+const (
+	RT_TRUNCATED       Code = "R001"
+	RT_CONST_INDEX     Code = "R002"
+	RT_SLOT            Code = "R003"
+	RT_CALL_OVERFLOW   Code = "R004"
+	RT_ILLEGAL_OPCODE  Code = "R005"
+	RT_DIV_ZERO        Code = "R006"
+	RT_READ_INPUT      Code = "R007"
+	RT_BAD_INPUT       Code = "R008"
+	RT_STACK_OVERFLOW  Code = "R009"
+	RT_STACK_UNDERFLOW Code = "R010"
+)
+
+// AST JSON 解码
+const (
+	JSON_BAD_VERSION       Code = "J001"
+	JSON_MISSING_STATEMENT Code = "J002"
+	JSON_UNKNOWN_STATEMENT Code = "J003"
+	JSON_MISSING_EXPR      Code = "J004"
+	JSON_NUMBER_VALUE      Code = "J005"
+	JSON_BOOLEAN_VALUE     Code = "J006"
+	JSON_UNKNOWN_EXPR      Code = "J007"
+	JSON_CASE_CLAUSE       Code = "J008"
+	JSON_CHAR_VALUE        Code = "J009"
+	JSON_UNKNOWN_TYPE      Code = "J010"
+)
+
+// 命令行
+const (
+	CLI_USAGE            Code = "C001"
+	CLI_NEED_FILE        Code = "C002"
+	CLI_READ_SOURCE      Code = "C003"
+	CLI_WRITE_OUTPUT     Code = "C004"
+	CLI_FLAG_OUTPUT      Code = "C005"
+	CLI_FLAG_TARGET      Code = "C006"
+	CLI_FLAG_OPT         Code = "C007"
+	CLI_FLAG_EMIT        Code = "C008"
+	CLI_FLAG_ANNOTATE    Code = "C009"
+	CLI_FLAG_WATCH       Code = "C010"
+	CLI_FLAG_RUN         Code = "C011"
+	CLI_UNKNOWN_STAGE    Code = "C012"
+	CLI_UNKNOWN_TARGET   Code = "C013"
+	CLI_RUN_NEEDS_WATCH  Code = "C014"
+	CLI_WATCH_STDIN      Code = "C015"
+	CLI_NO_IR            Code = "C016"
+	CLI_CODEGEN_ERROR    Code = "C017"
+	CLI_BUILD_OK         Code = "C018"
+	CLI_EXTRA_OUTPUT     Code = "C019"
+	CLI_EMU8086_HINT     Code = "C020"
+	CLI_CANNOT_RUN       Code = "C021"
+	CLI_CHECK_OK         Code = "C022"
+	CLI_FLAG_FMT_WRITE   Code = "C023"
+	CLI_FLAG_FMT_DIFF    Code = "C024"
+	CLI_FLAG_FMT_LIST    Code = "C025"
+	CLI_FMT_NEED_FILE    Code = "C026"
+	CLI_FMT_WRITE_STDIN  Code = "C027"
+	CLI_WRITE_FILE       Code = "C028"
+	CLI_FLAG_AST_JSON    Code = "C029"
+	CLI_DIFF_FORMATTED   Code = "C030"
+	CLI_NO_FILE_ARGS     Code = "C031"
+	CLI_FLAG_HISTORY     Code = "C032"
+	CLI_READ_BYTECODE    Code = "C033"
+	CLI_WATCH_BUILDING   Code = "C034"
+	CLI_WATCH_WAITING    Code = "C035"
+	CLI_WATCH_RUN        Code = "C036"
+	CLI_WATCH_RUN_DONE   Code = "C037"
+	CLI_UNKNOWN_LANG     Code = "C038"
+	CLI_FLAG_OVERFLOW    Code = "C039"
+	CLI_UNKNOWN_OVERFLOW Code = "C040"
+)
+
+// 交互式执行（REPL）
+const (
+	REPL_HELP            Code = "I001"
+	REPL_BANNER          Code = "I002"
+	REPL_UNKNOWN_COMMAND Code = "I003"
+)
+
+// 语言服务器
+const (
+	LSP_VAR_UNDEFINED    Code = "H001"
+	LSP_VAR_INFO         Code = "H002"
+	LSP_KEYWORD          Code = "H003"
+	LSP_KEYWORD_LABEL    Code = "H004"
+	LSP_SHUT_DOWN        Code = "H005"
+	LSP_UNKNOWN_METHOD   Code = "H006"
+	LSP_DISCONNECTED     Code = "H007"
+	LSP_EXIT_NO_SHUTDOWN Code = "H008"
+	LSP_BAD_LENGTH       Code = "H009"
+
+	// 关键字说明
+	LSP_DOC_IF       Code = "H101"
+	LSP_DOC_ELSE     Code = "H102"
+	LSP_DOC_WHILE    Code = "H103"
+	LSP_DOC_PRINT    Code = "H104"
+	LSP_DOC_INPUT    Code = "H105"
+	LSP_DOC_TRUE     Code = "H106"
+	LSP_DOC_FALSE    Code = "H107"
+	LSP_DOC_IMPORT   Code = "H108"
+	LSP_DOC_FOR      Code = "H109"
+	LSP_DOC_BREAK    Code = "H110"
+	LSP_DOC_CONTINUE Code = "H111"
+	LSP_DOC_DO       Code = "H112"
+	LSP_DOC_SWITCH   Code = "H113"
+	LSP_DOC_CASE     Code = "H114"
+	LSP_DOC_DEFAULT  Code = "H115"
+	LSP_DOC_CONST    Code = "H116"
+	LSP_DOC_INT      Code = "H117"
+	LSP_DOC_BOOL     Code = "H118"
+	LSP_DOC_CHAR     Code = "H119"
+	LSP_DOC_LONG     Code = "H120"
+)
@@ -0,0 +1,262 @@
+package msg
+
+// en 是英文消息目录
+var en = map[Code]string{
+	DIAG_LEXER:         "lexical error",
+	DIAG_SYNTAX:        "syntax error",
+	DIAG_SEMANTIC:      "semantic error",
+	DIAG_CODEGEN:       "code generation error",
+	DIAG_SEPARATOR:     ": ",
+	DIAG_FILE_POSITION: "%s:%d:%d: ",
+	DIAG_POSITION:      "line %d, column %d: ",
+	DIAG_MAIN_FILE:     "main program",
+	DIAG_RUNTIME:       "runtime error[%s]: at %04x: %s",
+
+	LEX_ILLEGAL_CHAR:        "illegal character: %c",
+	LEX_UNTERMINATED_STRING: "string is missing its closing double quote",
+	LEX_BAD_CHAR:            "invalid character literal %s: expected one ASCII character in single quotes, such as 'A', '\\n' or '\\x41'",
+
+	PARSE_TRAILING_INPUT:       "unexpected input after expression",
+	PARSE_UNKNOWN_STATEMENT:    "unknown statement",
+	PARSE_IMPORT_NOT_TOP_LEVEL: "import is only allowed at the top level of a file",
+	PARSE_IMPORT_NEEDS_PATH:    "import needs a file name in double quotes",
+	PARSE_IMPORT_EMPTY_PATH:    "import file name must not be empty",
+	PARSE_IMPORT_SEMICOLON:     "missing ';' after import statement",
+	PARSE_ASSIGN_MISSING_EQUAL: "assignment is missing '='",
+	PARSE_ASSIGN_SEMICOLON:     "missing ';' after assignment",
+	PARSE_PRINT_SEMICOLON:      "missing ';' after print statement",
+	PARSE_INPUT_NEEDS_IDENT:    "input statement needs a variable name",
+	PARSE_INPUT_SEMICOLON:      "missing ';' after input statement",
+	PARSE_IF_LPAREN:            "missing '(' in if statement",
+	PARSE_IF_RPAREN:            "missing ')' in if statement",
+	PARSE_IF_LBRACE:            "missing '{' in if statement",
+	PARSE_IF_RBRACE:            "missing '}' in if statement",
+	PARSE_ELSE_LBRACE:          "missing '{' after else",
+	PARSE_ELSE_RBRACE:          "missing '}' in else branch",
+	PARSE_WHILE_LPAREN:         "missing '(' in while statement",
+	PARSE_WHILE_RPAREN:         "missing ')' in while statement",
+	PARSE_WHILE_LBRACE:         "missing '{' in while statement",
+	PARSE_WHILE_RBRACE:         "missing '}' in while statement",
+	PARSE_ILLEGAL_KEYWORD:      "unexpected keyword",
+	PARSE_MISSING_RPAREN:       "missing ')'",
+	PARSE_ILLEGAL_EXPR:         "invalid expression",
+	PARSE_FOR_LPAREN:           "missing '(' in for statement",
+	PARSE_FOR_SEMICOLON:        "missing ';' in for statement",
+	PARSE_FOR_RPAREN:           "missing ')' in for statement",
+	PARSE_FOR_LBRACE:           "missing '{' in for statement",
+	PARSE_FOR_RBRACE:           "missing '}' in for statement",
+	PARSE_FOR_CLAUSE:           "the init and step parts of a for statement must be assignments, compound assignments or increments/decrements",
+	PARSE_BREAK_SEMICOLON:      "missing ';' after break",
+	PARSE_CONTINUE_SEMICOLON:   "missing ';' after continue",
+	PARSE_DO_LBRACE:            "missing '{' after do",
+	PARSE_DO_RBRACE:            "missing '}' in do statement",
+	PARSE_DO_WHILE:             "missing while after the body of a do statement",
+	PARSE_DO_LPAREN:            "missing '(' in do-while statement",
+	PARSE_DO_RPAREN:            "missing ')' in do-while statement",
+	PARSE_DO_SEMICOLON:         "missing ';' after do-while statement",
+	PARSE_SWITCH_LPAREN:        "missing '(' in switch statement",
+	PARSE_SWITCH_RPAREN:        "missing ')' in switch statement",
+	PARSE_SWITCH_LBRACE:        "missing '{' in switch statement",
+	PARSE_SWITCH_RBRACE:        "missing '}' in switch statement",
+	PARSE_SWITCH_CASE:          "statements in a switch must follow case or default",
+	PARSE_CASE_VALUE:           "expected a constant expression after case",
+	PARSE_CASE_COLON:           "missing ':' after case or default",
+	PARSE_INCDEC_IDENT:         "expected a variable name after %s",
+	PARSE_CONST_NOT_TOP_LEVEL:  "const is only allowed at the top level of a file",
+	PARSE_CONST_NAME:           "expected a constant name after const",
+	PARSE_CONST_EQUAL:          "missing '=' in constant declaration",
+	PARSE_CONST_SEMICOLON:      "missing ';' after constant declaration",
+	PARSE_DECL_NAME:            "expected a variable name after %s",
+	PARSE_DECL_SEMICOLON:       "missing ';' after variable declaration",
+
+	SEM_UNDEFINED_VAR:     "variable '%s' is not defined",
+	SEM_IMPORT_UNLINKED:   "cannot resolve import \"%s\": imports require compiling from a file",
+	SEM_IMPORT_CYCLE:      "import cycle: %s",
+	SEM_IMPORT_READ:       "cannot read imported file %s: %v",
+	SEM_VAR_OWNER:         "variable '%s' belongs to %s and cannot be modified in %s",
+	SEM_BREAK_OUTSIDE:     "break is only allowed inside a loop or switch",
+	SEM_CONTINUE_OUTSIDE:  "continue is only allowed inside a loop",
+	SEM_DUPLICATE_CASE:    "duplicate case %d (already used on line %d)",
+	SEM_DUPLICATE_DEFAULT: "multiple default clauses in switch (already one on line %d)",
+	SEM_CASE_RANGE:        "case value %s is out of the 16-bit integer range",
+	SEM_NOT_CONSTANT:      "'%s' is not a constant and cannot be used in a constant expression",
+	SEM_CONST_ASSIGN:      "cannot modify constant '%s'",
+	SEM_CONST_REDECLARED:  "constant '%s' is already declared",
+	SEM_CONST_IS_VAR:      "'%s' is already a variable and cannot be declared as a constant",
+	SEM_CONST_DIV_ZERO:    "division by zero in constant expression",
+	SEM_NUMBER_RANGE:      "number %s is out of the 16-bit integer range",
+	SEM_REDECLARED:        "'%s' is already defined and cannot be declared as a variable",
+	SEM_TYPE_ASSIGN:       "cannot assign a %s value to %s variable '%s'",
+	SEM_TYPE_OPERAND:      "operator %s expects int or long operands, got %s",
+	SEM_TYPE_COMPARE:      "cannot compare %s with %s",
+	SEM_TYPE_ORDER:        "%s values cannot be ordered with %s",
+	SEM_TYPE_CONDITION:    "condition must be bool, got %s",
+	SEM_TYPE_SWITCH:       "switch value must be int or char, got %s",
+	SEM_TYPE_CASE:         "case value must be %s, got %s",
+	SEM_TYPE_INPUT:        "input can only read int or long variables, but '%s' is %s",
+	SEM_CONST_LONG:        "constant '%s' has type long; constants can only be int, bool or char",
+	SEM_LONG_RANGE:        "number %s is out of the 32-bit integer range",
+
+	GEN_UNKNOWN_TARGET:       "unknown target: %s",
+	GEN_UNSUPPORTED_FEATURES: "target %s does not support: %s",
+	GEN_NO_ANNOTATE:          "target %s does not support source annotations and source maps",
+	GEN_ANNOTATE_MULTI_FILE:  "source annotations and source maps are not yet supported for programs that import other files",
+	GEN_UNKNOWN_DIALECT:      "unknown assembler dialect: %s",
+	GEN_NO_OVERFLOW_TRAP:     "target %s does not support overflow checking (--overflow=trap)",
+	GEN_FEATURE_PRINT:        "print",
+	GEN_FEATURE_INPUT:        "input",
+	GEN_FEATURE_IF:           "if",
+	GEN_FEATURE_WHILE:        "while",
+	GEN_FEATURE_DIVISION:     "division",
+	GEN_FEATURE_COMPARISON:   "comparison",
+	GEN_FEATURE_BOOLEAN:      "boolean constants",
+	GEN_FEATURE_FOR:          "for",
+	GEN_FEATURE_BREAK:        "break/continue",
+	GEN_FEATURE_DO_WHILE:     "do-while",
+	GEN_FEATURE_SWITCH:       "switch",
+	GEN_FEATURE_BITWISE:      "bitwise operators",
+	GEN_FEATURE_CONST:        "constants",
+	GEN_FEATURE_CHAR:         "char type",
+	GEN_FEATURE_LONG:         "long type",
+
+	BC_CODE_TOO_LONG:          "bytecode compile error: code exceeds 64KB",
+	BC_UNSUPPORTED_STATEMENT:  "bytecode compile error: unsupported statement %T",
+	BC_NUMBER_RANGE:           "bytecode compile error: number %s does not fit in 16 bits",
+	BC_UNSUPPORTED_OPERATOR:   "bytecode compile error: unsupported operator %s",
+	BC_UNSUPPORTED_COMPARISON: "bytecode compile error: unsupported comparison operator %s",
+	BC_UNSUPPORTED_EXPR:       "bytecode compile error: unsupported expression %T",
+	BC_TOO_MANY:               "bytecode format error: too many constants or variables",
+	BC_NAME_TOO_LONG:          "bytecode format error: variable name '%s' is too long",
+	BC_BAD_HEADER:             "bytecode format error: incomplete header",
+	BC_BAD_MAGIC:              "bytecode format error: not a valid .bc file",
+	BC_BAD_VERSION:            "bytecode format error: unsupported version %d",
+	BC_BAD_CONSTANTS:          "bytecode format error: incomplete constant pool",
+	BC_BAD_GLOBALS:            "bytecode format error: incomplete variable table",
+	BC_BAD_CODE:               "bytecode format error: incomplete code section",
+	BC_DIS_CONSTANTS:          "constant pool",
+	BC_DIS_GLOBALS:            "globals",
+
+	RT_TRUNCATED:       "truncated instruction",
+	RT_CONST_INDEX:     "constant index %d out of range",
+	RT_SLOT:            "variable slot %d out of range",
+	RT_CALL_OVERFLOW:   "call stack overflow",
+	RT_ILLEGAL_OPCODE:  "illegal opcode %02x",
+	RT_DIV_ZERO:        "division by zero",
+	RT_READ_INPUT:      "failed to read input: %v",
+	RT_BAD_INPUT:       "input '%s' is not a valid 16-bit integer, please try again: ",
+	RT_STACK_OVERFLOW:  "stack overflow",
+	RT_STACK_UNDERFLOW: "stack underflow",
+
+	JSON_BAD_VERSION:       "unsupported AST JSON version: %d",
+	JSON_MISSING_STATEMENT: "missing statement node",
+	JSON_UNKNOWN_STATEMENT: "line %d, column %d: unknown statement kind %q",
+	JSON_MISSING_EXPR:      "missing expression node",
+	JSON_NUMBER_VALUE:      "line %d, column %d: NumberExpr value must be a string",
+	JSON_BOOLEAN_VALUE:     "line %d, column %d: BooleanExpr value must be a boolean",
+	JSON_UNKNOWN_EXPR:      "line %d, column %d: unknown expression kind %q",
+	JSON_CASE_CLAUSE:       "SwitchStatement cases must be CaseClause nodes whose values are expressions",
+	JSON_CHAR_VALUE:        "line %d, column %d: CharExpr value must be a string of one ASCII character",
+	JSON_UNKNOWN_TYPE:      "line %d, column %d: unknown type %q",
+
+	CLI_USAGE: `Usage: compiler [--lang=zh|en] <command> [options] <source file>
+
+Commands:
+  build   compile a source file (default, may be omitted)
+  run     compile to bytecode and run it
+  check   lexical, syntax and semantic checks only
+  fmt     format source code (-w write back, -d show diff, -l list unformatted files)
+  tokens  print tokens
+  ast     print the syntax tree
+  repl    run statements interactively
+  lsp     run the language server over stdin/stdout
+  vm      run a .bc bytecode file
+  dis     disassemble a .bc bytecode file
+
+Use - as the source file to read standard input. Run compiler <command> -h for its options.
+--lang selects the message language; without it LC_ALL, LC_MESSAGES and LANG are used, defaulting to Chinese.
+`,
+	CLI_NEED_FILE:        "%s: expected one source file path",
+	CLI_READ_SOURCE:      "cannot read source file: %v",
+	CLI_WRITE_OUTPUT:     "cannot write output file: %v",
+	CLI_FLAG_OUTPUT:      "output file path, - for standard output (default: output plus the target extension)",
+	CLI_FLAG_TARGET:      "target backend: %s",
+	CLI_FLAG_OPT:         "optimization level: 0 none, 1 constant folding, 2 dead branch elimination",
+	CLI_FLAG_EMIT:        "stages to output, comma separated: %s",
+	CLI_FLAG_ANNOTATE:    "annotate the assembly with source lines and write a source map (output name plus .map)",
+	CLI_FLAG_WATCH:       "watch the source files and rebuild on change",
+	CLI_FLAG_RUN:         "with --watch: run the program in the bytecode VM after each successful build",
+	CLI_UNKNOWN_STAGE:    "unknown --emit stage: %s (choose from: %s)",
+	CLI_UNKNOWN_TARGET:   "unknown target: %s (choose from: %s)",
+	CLI_RUN_NEEDS_WATCH:  "build: --run requires --watch; use compiler run to run directly",
+	CLI_WATCH_STDIN:      "build: --watch cannot be used with standard input",
+	CLI_NO_IR:            "bytecode IR cannot be generated for this program",
+	CLI_CODEGEN_ERROR:    "code generation error: %v",
+	CLI_BUILD_OK:         "Build succeeded! Output file: %s",
+	CLI_EXTRA_OUTPUT:     "Additional output file: %s",
+	CLI_EMU8086_HINT:     "You can open and run this file in emu8086",
+	CLI_CANNOT_RUN:       "bytecode cannot be generated for this program, so it cannot be run",
+	CLI_CHECK_OK:         "Check passed",
+	CLI_FLAG_FMT_WRITE:   "write the result back to the source file",
+	CLI_FLAG_FMT_DIFF:    "print a diff of the formatting changes",
+	CLI_FLAG_FMT_LIST:    "list files that are not formatted",
+	CLI_FMT_NEED_FILE:    "fmt: expected a source file path",
+	CLI_FMT_WRITE_STDIN:  "fmt: -w cannot be used with standard input",
+	CLI_WRITE_FILE:       "cannot write file: %v",
+	CLI_FLAG_AST_JSON:    "output JSON (can be read back with parser.UnmarshalJSON)",
+	CLI_DIFF_FORMATTED:   "%s (formatted)",
+	CLI_NO_FILE_ARGS:     "%s: does not take file arguments",
+	CLI_FLAG_HISTORY:     "history file; empty disables history",
+	CLI_READ_BYTECODE:    "cannot read bytecode file: %v",
+	CLI_WATCH_BUILDING:   "[%s] building %s",
+	CLI_WATCH_WAITING:    "Watching for changes, press Ctrl+C to exit",
+	CLI_WATCH_RUN:        "---- run ----",
+	CLI_WATCH_RUN_DONE:   "---- finished ----",
+	CLI_UNKNOWN_LANG:     "unknown language: %s (choose from: %s)",
+	CLI_FLAG_OVERFLOW:    "integer overflow handling: wrap uses two's complement wraparound, trap reports overflow and out-of-range input at run time and exits",
+	CLI_UNKNOWN_OVERFLOW: "unknown --overflow value: %s (choose from: %s)",
+
+	REPL_HELP: `Enter a statement to execute it, or an expression to print its value; if/while/for statements may span lines and run once their braces balance.
+
+Meta commands:
+  :vars           list all variables
+  :ast [code]     print the syntax tree (the previous input when code is omitted)
+  :asm [code]     print emu8086 assembly
+  :tokens [code]  print tokens
+  :history        list previous input
+  :help           show this help
+  :quit           exit
+`,
+	REPL_BANNER:          "Enter statements or expressions; :help for help, :quit to exit",
+	REPL_UNKNOWN_COMMAND: "unknown command %s; enter :help for help",
+
+	LSP_VAR_UNDEFINED:    "variable `%s` (undefined)",
+	LSP_VAR_INFO:         "variable `%s`: 16-bit signed integer\n\ndefined on line %d, %d occurrences",
+	LSP_KEYWORD:          "keyword `%s`: %s",
+	LSP_KEYWORD_LABEL:    "keyword",
+	LSP_SHUT_DOWN:        "server has been shut down",
+	LSP_UNKNOWN_METHOD:   "unsupported method: %s",
+	LSP_DISCONNECTED:     "connection closed",
+	LSP_EXIT_NO_SHUTDOWN: "exit received without a shutdown request",
+	LSP_BAD_LENGTH:       "invalid Content-Length in message header: %q",
+
+	LSP_DOC_IF:       "conditional `if (condition) { ... } else { ... }`",
+	LSP_DOC_ELSE:     "branch taken when the condition is false; may be written `else if (condition) { ... }`",
+	LSP_DOC_WHILE:    "loop `while (condition) { ... }`",
+	LSP_DOC_PRINT:    "print the value of an expression `print expr;`",
+	LSP_DOC_INPUT:    "read an integer into a variable `input var;`",
+	LSP_DOC_TRUE:     "boolean constant with value 1",
+	LSP_DOC_FALSE:    "boolean constant with value 0",
+	LSP_DOC_IMPORT:   "import another source file `import \"util.src\";`, relative to the current file",
+	LSP_DOC_FOR:      "loop `for (init; condition; step) { ... }`; each part may be omitted",
+	LSP_DOC_BREAK:    "leave the innermost loop `break;`",
+	LSP_DOC_CONTINUE: "start the next iteration of the innermost loop `continue;`; a for loop runs its step first",
+	LSP_DOC_DO:       "loop that tests at the bottom `do { ... } while (condition);`; the body runs at least once",
+	LSP_DOC_SWITCH:   "multi-way branch `switch (expr) { case 1, 2: ... default: ... }`; control leaves the switch after a clause",
+	LSP_DOC_CASE:     "switch clause `case 1, N + 1:`; values must be distinct constant expressions",
+	LSP_DOC_DEFAULT:  "switch clause taken when no case matches `default:`",
+	LSP_DOC_CONST:    "compile-time constant `const N = 10 * 2;`; only allowed at the top level and cannot be modified",
+	LSP_DOC_INT:      "16-bit signed integer type `int x = 3;`; defaults to 0 without an initializer",
+	LSP_DOC_BOOL:     "boolean type `bool ok = x > 0;`; holds true or false, and conditions must be bool",
+	LSP_DOC_CHAR:     "character type `char c = 'A';`; takes one byte and print outputs the character itself",
+	LSP_DOC_LONG:     "32-bit signed integer type `long f = 100000;`; int values can be assigned to long directly, stored with dd on the 8086",
+}
@@ -0,0 +1,157 @@
+// Package msg 是编译器全部用户可见消息的目录。
+// 每条消息有一个稳定的编号（Code），按指定的语言或默认语言取出对应的文本，
+// 工具可以根据编号识别错误，而不必解析某种语言的文本。
+package msg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Lang 是消息语言
+type Lang string
+
+const (
+	LANG_ZH Lang = "zh" // 中文（默认）
+	LANG_EN Lang = "en" // 英文
+)
+
+// Code 是消息编号。首字母表示来源：
+// L 词法，P 语法，S 语义，G 代码生成，B 字节码，R 运行时，J AST JSON，
+// D 诊断信息格式，C 命令行，I 交互式执行，H 语言服务器。
+type Code string
+
+var catalogs = map[Lang]map[Code]string{
+	LANG_ZH: zh,
+	LANG_EN: en,
+}
+
+// current 是默认语言，由命令行在启动时设置。
+// 编译器的诊断信息只记录编号和参数，输出时才生成文本，可以用 Format 指定语言而不依赖默认语言
+var current atomic.Value
+
+// SetLang 设置默认语言，可以与其他 goroutine 生成消息同时进行
+func SetLang(lang Lang) {
+	current.Store(lang)
+}
+
+// Current 返回默认语言，没有设置时为中文
+func Current() Lang {
+	if lang, ok := current.Load().(Lang); ok {
+		return lang
+	}
+	return LANG_ZH
+}
+
+// Localizer 是可以作为消息参数的值，生成消息时按同一种语言转换为文本
+type Localizer interface {
+	Localize(lang Lang) string
+}
+
+// Langs 返回所有支持的语言
+func Langs() []string {
+	return []string{string(LANG_ZH), string(LANG_EN)}
+}
+
+// ParseLang 识别 "zh"、"en" 以及 "en_US.UTF-8" 这样的区域设置名
+func ParseLang(s string) (Lang, bool) {
+	s = strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(s, "zh"):
+		return LANG_ZH, true
+	case strings.HasPrefix(s, "en"):
+		return LANG_EN, true
+	}
+	return "", false
+}
+
+// FromEnv 按 LC_ALL、LC_MESSAGES、LANG 的顺序从环境变量确定语言，无法识别时使用中文
+func FromEnv() Lang {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if lang, ok := ParseLang(value); ok {
+			return lang
+		}
+		// 变量已设置但不是支持的语言（如 C.UTF-8），不再查看优先级更低的变量
+		break
+	}
+	return LANG_ZH
+}
+
+// Get 按默认语言生成编号为 code 的消息
+func Get(code Code, args ...interface{}) string {
+	return Format(Current(), code, args...)
+}
+
+// Format 按语言 lang 生成编号为 code 的消息。
+// 该语言缺少这条消息时使用中文，目录中没有这条消息时返回编号本身。
+func Format(lang Lang, code Code, args ...interface{}) string {
+	text, ok := catalogs[lang][code]
+	if !ok {
+		if text, ok = zh[code]; !ok {
+			return string(code)
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	localized := make([]interface{}, len(args))
+	for i, arg := range args {
+		if l, ok := arg.(Localizer); ok {
+			arg = l.Localize(lang)
+		}
+		localized[i] = arg
+	}
+	return fmt.Sprintf(text, localized...)
+}
+
+// Error 是带编号的错误，Error() 按默认语言生成消息
+type Error struct {
+	Code Code
+	Args []interface{}
+}
+
+// Errorf 创建编号为 code 的错误
+func Errorf(code Code, args ...interface{}) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+func (e *Error) Error() string {
+	return Get(e.Code, e.Args...)
+}
+
+// Localize 按语言 lang 生成错误消息
+func (e *Error) Localize(lang Lang) string {
+	return Format(lang, e.Code, e.Args...)
+}
+
+// Diagnostic 按默认语言生成一条诊断信息，见 FormatDiagnostic
+func Diagnostic(title Code, code Code, file string, line, column int, message string) string {
+	return FormatDiagnostic(Current(), title, code, file, line, column, message)
+}
+
+// FormatDiagnostic 按语言 lang 生成一条诊断信息，如 "语法错误[P024]：main.src 第2行第10列: 非法表达式"。
+// title 是 DIAG_* 标题编号；file 为空或 line 为 0 时省略对应部分；code 为空时不显示编号。
+func FormatDiagnostic(lang Lang, title Code, code Code, file string, line, column int, message string) string {
+	var sb strings.Builder
+	sb.WriteString(Format(lang, title))
+	if code != "" {
+		sb.WriteString("[" + string(code) + "]")
+	}
+	sb.WriteString(Format(lang, DIAG_SEPARATOR))
+	switch {
+	case file != "" && line > 0:
+		sb.WriteString(Format(lang, DIAG_FILE_POSITION, file, line, column))
+	case file != "":
+		sb.WriteString(file + ": ")
+	case line > 0:
+		sb.WriteString(Format(lang, DIAG_POSITION, line, column))
+	}
+	sb.WriteString(message)
+	return sb.String()
+}
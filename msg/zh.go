@@ -0,0 +1,262 @@
+package msg
+
+// zh 是中文消息目录，包含全部消息
+var zh = map[Code]string{
+	DIAG_LEXER:         "词法错误",
+	DIAG_SYNTAX:        "语法错误",
+	DIAG_SEMANTIC:      "语义错误",
+	DIAG_CODEGEN:       "代码生成错误",
+	DIAG_SEPARATOR:     "：",
+	DIAG_FILE_POSITION: "%s 第%d行第%d列: ",
+	DIAG_POSITION:      "第%d行第%d列: ",
+	DIAG_MAIN_FILE:     "主程序",
+	DIAG_RUNTIME:       "运行时错误[%s]：地址 %04x: %s",
+
+	LEX_ILLEGAL_CHAR:        "非法字符: %c",
+	LEX_UNTERMINATED_STRING: "字符串缺少结束的双引号",
+	LEX_BAD_CHAR:            "字符常量 %s 不正确，应为单引号括起的一个 ASCII 字符，如 'A'、'\\n'、'\\x41'",
+
+	PARSE_TRAILING_INPUT:       "表达式之后有多余的内容",
+	PARSE_UNKNOWN_STATEMENT:    "未知语句",
+	PARSE_IMPORT_NOT_TOP_LEVEL: "import 只能出现在文件顶层",
+	PARSE_IMPORT_NEEDS_PATH:    "import语句需要用双引号括起的文件名",
+	PARSE_IMPORT_EMPTY_PATH:    "import的文件名不能为空",
+	PARSE_IMPORT_SEMICOLON:     "import语句缺少分号",
+	PARSE_ASSIGN_MISSING_EQUAL: "赋值语句缺少 '='",
+	PARSE_ASSIGN_SEMICOLON:     "赋值语句缺少分号",
+	PARSE_PRINT_SEMICOLON:      "print语句缺少分号",
+	PARSE_INPUT_NEEDS_IDENT:    "input语句需要变量名",
+	PARSE_INPUT_SEMICOLON:      "input语句缺少分号",
+	PARSE_IF_LPAREN:            "if语句缺少左括号",
+	PARSE_IF_RPAREN:            "if语句缺少右括号",
+	PARSE_IF_LBRACE:            "if语句缺少左花括号",
+	PARSE_IF_RBRACE:            "if语句缺少右花括号",
+	PARSE_ELSE_LBRACE:          "else语句缺少左花括号",
+	PARSE_ELSE_RBRACE:          "else语句缺少右花括号",
+	PARSE_WHILE_LPAREN:         "while语句缺少左括号",
+	PARSE_WHILE_RPAREN:         "while语句缺少右括号",
+	PARSE_WHILE_LBRACE:         "while语句缺少左花括号",
+	PARSE_WHILE_RBRACE:         "while语句缺少右花括号",
+	PARSE_ILLEGAL_KEYWORD:      "非法的关键字",
+	PARSE_MISSING_RPAREN:       "缺少右括号",
+	PARSE_ILLEGAL_EXPR:         "非法表达式",
+	PARSE_FOR_LPAREN:           "for语句缺少左括号",
+	PARSE_FOR_SEMICOLON:        "for语句缺少分号",
+	PARSE_FOR_RPAREN:           "for语句缺少右括号",
+	PARSE_FOR_LBRACE:           "for语句缺少左花括号",
+	PARSE_FOR_RBRACE:           "for语句缺少右花括号",
+	PARSE_FOR_CLAUSE:           "for语句的初始化和步进部分只能是赋值、复合赋值或自增自减语句",
+	PARSE_BREAK_SEMICOLON:      "break语句缺少分号",
+	PARSE_CONTINUE_SEMICOLON:   "continue语句缺少分号",
+	PARSE_DO_LBRACE:            "do语句缺少左花括号",
+	PARSE_DO_RBRACE:            "do语句缺少右花括号",
+	PARSE_DO_WHILE:             "do语句的右花括号之后缺少 while",
+	PARSE_DO_LPAREN:            "do-while语句缺少左括号",
+	PARSE_DO_RPAREN:            "do-while语句缺少右括号",
+	PARSE_DO_SEMICOLON:         "do-while语句缺少分号",
+	PARSE_SWITCH_LPAREN:        "switch语句缺少左括号",
+	PARSE_SWITCH_RPAREN:        "switch语句缺少右括号",
+	PARSE_SWITCH_LBRACE:        "switch语句缺少左花括号",
+	PARSE_SWITCH_RBRACE:        "switch语句缺少右花括号",
+	PARSE_SWITCH_CASE:          "switch语句中的语句必须写在 case 或 default 之后",
+	PARSE_CASE_VALUE:           "case 后面应为常量表达式",
+	PARSE_CASE_COLON:           "case 或 default 缺少冒号",
+	PARSE_INCDEC_IDENT:         "%s 后面应为变量名",
+	PARSE_CONST_NOT_TOP_LEVEL:  "const 只能出现在文件顶层",
+	PARSE_CONST_NAME:           "const 后面应为常量名",
+	PARSE_CONST_EQUAL:          "常量声明缺少 '='",
+	PARSE_CONST_SEMICOLON:      "常量声明缺少分号",
+	PARSE_DECL_NAME:            "%s 后面应为变量名",
+	PARSE_DECL_SEMICOLON:       "变量声明缺少分号",
+
+	SEM_UNDEFINED_VAR:     "变量 '%s' 未定义",
+	SEM_IMPORT_UNLINKED:   "无法解析 import \"%s\"：导入需要从文件编译",
+	SEM_IMPORT_CYCLE:      "循环导入：%s",
+	SEM_IMPORT_READ:       "无法读取导入的文件 %s：%v",
+	SEM_VAR_OWNER:         "变量 '%s' 属于 %s，不能在 %s 中修改",
+	SEM_BREAK_OUTSIDE:     "break 只能出现在循环或 switch 中",
+	SEM_CONTINUE_OUTSIDE:  "continue 只能出现在循环中",
+	SEM_DUPLICATE_CASE:    "case %d 重复（第%d行已有）",
+	SEM_DUPLICATE_DEFAULT: "switch语句有多个 default（第%d行已有）",
+	SEM_CASE_RANGE:        "case 的值 %s 超出 16 位整数范围",
+	SEM_NOT_CONSTANT:      "'%s' 不是常量，不能出现在常量表达式中",
+	SEM_CONST_ASSIGN:      "不能修改常量 '%s'",
+	SEM_CONST_REDECLARED:  "常量 '%s' 重复声明",
+	SEM_CONST_IS_VAR:      "'%s' 已经是变量，不能再声明为常量",
+	SEM_CONST_DIV_ZERO:    "常量表达式中除数为 0",
+	SEM_NUMBER_RANGE:      "数字 %s 超出 16 位整数范围",
+	SEM_REDECLARED:        "'%s' 已经定义，不能再声明为变量",
+	SEM_TYPE_ASSIGN:       "不能把 %s 类型的值赋给 %s 类型的变量 '%s'",
+	SEM_TYPE_OPERAND:      "运算符 %s 的操作数应为 int 或 long 类型，实际为 %s",
+	SEM_TYPE_COMPARE:      "不能比较 %s 类型和 %s 类型的值",
+	SEM_TYPE_ORDER:        "%s 类型的值不能用 %s 比较大小",
+	SEM_TYPE_CONDITION:    "条件应为 bool 类型，实际为 %s",
+	SEM_TYPE_SWITCH:       "switch 的值应为 int 或 char 类型，实际为 %s",
+	SEM_TYPE_CASE:         "case 的值应为 %s 类型，实际为 %s",
+	SEM_TYPE_INPUT:        "input 只能读入 int 或 long 类型的变量，'%s' 是 %s 类型",
+	SEM_CONST_LONG:        "常量 '%s' 的值是 long 类型，常量只能是 int、bool 或 char 类型",
+	SEM_LONG_RANGE:        "数字 %s 超出 32 位整数范围",
+
+	GEN_UNKNOWN_TARGET:       "未知的目标后端：%s",
+	GEN_UNSUPPORTED_FEATURES: "目标 %s 不支持以下特性：%s",
+	GEN_NO_ANNOTATE:          "目标 %s 不支持源代码注释和源码映射",
+	GEN_ANNOTATE_MULTI_FILE:  "源代码注释和源码映射暂不支持导入了其他文件的程序",
+	GEN_UNKNOWN_DIALECT:      "未知的汇编方言：%s",
+	GEN_NO_OVERFLOW_TRAP:     "目标 %s 不支持溢出检查（--overflow=trap）",
+	GEN_FEATURE_PRINT:        "print",
+	GEN_FEATURE_INPUT:        "input",
+	GEN_FEATURE_IF:           "if",
+	GEN_FEATURE_WHILE:        "while",
+	GEN_FEATURE_DIVISION:     "除法",
+	GEN_FEATURE_COMPARISON:   "比较运算",
+	GEN_FEATURE_BOOLEAN:      "布尔常量",
+	GEN_FEATURE_FOR:          "for",
+	GEN_FEATURE_BREAK:        "break/continue",
+	GEN_FEATURE_DO_WHILE:     "do-while",
+	GEN_FEATURE_SWITCH:       "switch",
+	GEN_FEATURE_BITWISE:      "位运算",
+	GEN_FEATURE_CONST:        "常量",
+	GEN_FEATURE_CHAR:         "char 类型",
+	GEN_FEATURE_LONG:         "long 类型",
+
+	BC_CODE_TOO_LONG:          "字节码编译错误：代码长度超过 64KB",
+	BC_UNSUPPORTED_STATEMENT:  "字节码编译错误：不支持的语句 %T",
+	BC_NUMBER_RANGE:           "字节码编译错误：数字 %s 超出 16 位范围",
+	BC_UNSUPPORTED_OPERATOR:   "字节码编译错误：不支持的运算符 %s",
+	BC_UNSUPPORTED_COMPARISON: "字节码编译错误：不支持的比较运算符 %s",
+	BC_UNSUPPORTED_EXPR:       "字节码编译错误：不支持的表达式 %T",
+	BC_TOO_MANY:               "字节码格式错误：常量或变量数量过多",
+	BC_NAME_TOO_LONG:          "字节码格式错误：变量名 '%s' 过长",
+	BC_BAD_HEADER:             "字节码格式错误：文件头不完整",
+	BC_BAD_MAGIC:              "字节码格式错误：不是有效的 .bc 文件",
+	BC_BAD_VERSION:            "字节码格式错误：不支持的版本 %d",
+	BC_BAD_CONSTANTS:          "字节码格式错误：常量池不完整",
+	BC_BAD_GLOBALS:            "字节码格式错误：变量表不完整",
+	BC_BAD_CODE:               "字节码格式错误：代码段不完整",
+	BC_DIS_CONSTANTS:          "常量池",
+	BC_DIS_GLOBALS:            "变量表",
+
+	RT_TRUNCATED:       "指令被截断",
+	RT_CONST_INDEX:     "常量下标 %d 越界",
+	RT_SLOT:            "变量槽位 %d 越界",
+	RT_CALL_OVERFLOW:   "调用栈溢出",
+	RT_ILLEGAL_OPCODE:  "非法指令 %02x",
+	RT_DIV_ZERO:        "除数为0",
+	RT_READ_INPUT:      "读取输入失败：%v",
+	RT_BAD_INPUT:       "输入 '%s' 不是合法的 16 位整数，请重新输入：",
+	RT_STACK_OVERFLOW:  "栈溢出",
+	RT_STACK_UNDERFLOW: "栈下溢",
+
+	JSON_BAD_VERSION:       "不支持的 AST JSON 版本：%d",
+	JSON_MISSING_STATEMENT: "缺少语句节点",
+	JSON_UNKNOWN_STATEMENT: "第%d行第%d列: 未知的语句类型 %q",
+	JSON_MISSING_EXPR:      "缺少表达式节点",
+	JSON_NUMBER_VALUE:      "第%d行第%d列: NumberExpr 的 value 必须是字符串",
+	JSON_BOOLEAN_VALUE:     "第%d行第%d列: BooleanExpr 的 value 必须是布尔值",
+	JSON_UNKNOWN_EXPR:      "第%d行第%d列: 未知的表达式类型 %q",
+	JSON_CASE_CLAUSE:       "SwitchStatement 的 cases 只能是 CaseClause 节点，其 values 只能是表达式节点",
+	JSON_CHAR_VALUE:        "第%d行第%d列: CharExpr 的 value 必须是只含一个 ASCII 字符的字符串",
+	JSON_UNKNOWN_TYPE:      "第%d行第%d列: 未知的类型 %q",
+
+	CLI_USAGE: `用法：compiler [--lang=zh|en] <命令> [选项] <源文件>
+
+命令：
+  build   编译源文件（默认命令，可省略）
+  run     编译为字节码并立即执行
+  check   只做词法、语法和语义检查
+  fmt     格式化源代码（-w 写回文件，-d 输出 diff，-l 列出未格式化的文件）
+  tokens  输出词法单元
+  ast     输出语法树
+  repl    交互式执行语句
+  lsp     通过标准输入输出运行语言服务器
+  vm      执行 .bc 字节码文件
+  dis     反汇编 .bc 字节码文件
+
+源文件为 - 时从标准输入读取。使用 compiler <命令> -h 查看各命令的选项。
+--lang 选择消息语言，省略时按 LC_ALL、LC_MESSAGES、LANG 环境变量选择，默认中文。
+`,
+	CLI_NEED_FILE:        "%s：请指定一个源文件路径",
+	CLI_READ_SOURCE:      "读取源文件错误：%v",
+	CLI_WRITE_OUTPUT:     "写入输出文件失败：%v",
+	CLI_FLAG_OUTPUT:      "输出文件路径，- 表示标准输出（默认 output 加目标扩展名）",
+	CLI_FLAG_TARGET:      "目标后端：%s",
+	CLI_FLAG_OPT:         "优化级别：0 不优化，1 常量折叠，2 删除死分支",
+	CLI_FLAG_EMIT:        "输出的阶段，逗号分隔：%s",
+	CLI_FLAG_ANNOTATE:    "在汇编中插入源代码行注释，并输出源码映射（输出文件名加 .map）",
+	CLI_FLAG_WATCH:       "监视源文件，修改后自动重新编译",
+	CLI_FLAG_RUN:         "与 --watch 一起使用：每次编译成功后在字节码虚拟机中运行程序",
+	CLI_UNKNOWN_STAGE:    "未知的 --emit 阶段：%s（可选：%s）",
+	CLI_UNKNOWN_TARGET:   "未知的目标后端：%s（可选：%s）",
+	CLI_RUN_NEEDS_WATCH:  "build：--run 只能与 --watch 一起使用，直接运行请用 compiler run",
+	CLI_WATCH_STDIN:      "build：--watch 不能用于标准输入",
+	CLI_NO_IR:            "该程序无法生成字节码 IR",
+	CLI_CODEGEN_ERROR:    "代码生成错误：%v",
+	CLI_BUILD_OK:         "编译成功！输出文件：%s",
+	CLI_EXTRA_OUTPUT:     "附加输出文件：%s",
+	CLI_EMU8086_HINT:     "您可以使用emu8086打开并运行此文件",
+	CLI_CANNOT_RUN:       "该程序无法生成字节码，不能直接运行",
+	CLI_CHECK_OK:         "检查通过",
+	CLI_FLAG_FMT_WRITE:   "把格式化结果写回源文件",
+	CLI_FLAG_FMT_DIFF:    "输出格式化前后的 diff",
+	CLI_FLAG_FMT_LIST:    "列出格式不规范的文件",
+	CLI_FMT_NEED_FILE:    "fmt：请指定源文件路径",
+	CLI_FMT_WRITE_STDIN:  "fmt：-w 不能用于标准输入",
+	CLI_WRITE_FILE:       "写入文件失败：%v",
+	CLI_FLAG_AST_JSON:    "以 JSON 格式输出（可用 parser.UnmarshalJSON 读回）",
+	CLI_DIFF_FORMATTED:   "%s（格式化后）",
+	CLI_NO_FILE_ARGS:     "%s：不接受文件参数",
+	CLI_FLAG_HISTORY:     "历史记录文件，为空时不保存历史",
+	CLI_READ_BYTECODE:    "读取字节码文件错误：%v",
+	CLI_WATCH_BUILDING:   "[%s] 编译 %s",
+	CLI_WATCH_WAITING:    "正在监视文件修改，按 Ctrl+C 退出",
+	CLI_WATCH_RUN:        "---- 运行 ----",
+	CLI_WATCH_RUN_DONE:   "---- 运行结束 ----",
+	CLI_UNKNOWN_LANG:     "未知的语言：%s（可选：%s）",
+	CLI_FLAG_OVERFLOW:    "整数溢出的处理方式：wrap 按补码回绕，trap 在运行时报告溢出和超出范围的输入并退出",
+	CLI_UNKNOWN_OVERFLOW: "未知的 --overflow 取值：%s（可选：%s）",
+
+	REPL_HELP: `直接输入语句执行，输入表达式输出其值；if/while/for 语句可以跨行，花括号配对后执行。
+
+元命令：
+  :vars           列出所有变量
+  :ast [代码]     输出语法树（省略代码时使用上一次输入）
+  :asm [代码]     输出 emu8086 汇编
+  :tokens [代码]  输出词法单元
+  :history        列出历史输入
+  :help           显示本帮助
+  :quit           退出
+`,
+	REPL_BANNER:          "输入语句或表达式执行，:help 查看帮助，:quit 退出",
+	REPL_UNKNOWN_COMMAND: "未知命令 %s，输入 :help 查看帮助",
+
+	LSP_VAR_UNDEFINED:    "变量 `%s`（未定义）",
+	LSP_VAR_INFO:         "变量 `%s`：16 位有符号整数\n\n定义于第 %d 行，共出现 %d 次",
+	LSP_KEYWORD:          "关键字 `%s`：%s",
+	LSP_KEYWORD_LABEL:    "关键字",
+	LSP_SHUT_DOWN:        "服务器已关闭",
+	LSP_UNKNOWN_METHOD:   "不支持的方法：%s",
+	LSP_DISCONNECTED:     "连接已断开",
+	LSP_EXIT_NO_SHUTDOWN: "未收到 shutdown 请求就退出",
+	LSP_BAD_LENGTH:       "消息头中的 Content-Length 不合法：%q",
+
+	LSP_DOC_IF:       "条件语句 `if (条件) { ... } else { ... }`",
+	LSP_DOC_ELSE:     "条件不成立时执行的分支，可以写成 `else if (条件) { ... }`",
+	LSP_DOC_WHILE:    "循环语句 `while (条件) { ... }`",
+	LSP_DOC_PRINT:    "输出表达式的值 `print 表达式;`",
+	LSP_DOC_INPUT:    "读入一个整数到变量 `input 变量;`",
+	LSP_DOC_TRUE:     "布尔常量，值为 1",
+	LSP_DOC_FALSE:    "布尔常量，值为 0",
+	LSP_DOC_IMPORT:   "导入另一个源文件 `import \"util.src\";`，路径相对于当前文件",
+	LSP_DOC_FOR:      "循环语句 `for (初始化; 条件; 步进) { ... }`，三部分都可以省略",
+	LSP_DOC_BREAK:    "跳出最内层的循环 `break;`",
+	LSP_DOC_CONTINUE: "跳到最内层循环的下一次迭代 `continue;`，for 循环会先执行步进部分",
+	LSP_DOC_DO:       "先执行后判断的循环 `do { ... } while (条件);`，循环体至少执行一次",
+	LSP_DOC_SWITCH:   "多分支选择 `switch (表达式) { case 1, 2: ... default: ... }`，分支执行完后跳出 switch",
+	LSP_DOC_CASE:     "switch 的分支 `case 1, N + 1:`，值必须是不重复的常量表达式",
+	LSP_DOC_DEFAULT:  "switch 中没有 case 匹配时执行的分支 `default:`",
+	LSP_DOC_CONST:    "声明编译期常量 `const N = 10 * 2;`，只能在文件顶层声明，不能修改",
+	LSP_DOC_INT:      "16 位有符号整数类型 `int x = 3;`，省略初始值时为 0",
+	LSP_DOC_BOOL:     "布尔类型 `bool ok = x > 0;`，只能取 true 或 false，条件必须是 bool 类型",
+	LSP_DOC_CHAR:     "字符类型 `char c = 'A';`，占一个字节，print 时输出字符本身",
+	LSP_DOC_LONG:     "32 位有符号整数类型 `long f = 100000;`，int 的值可以直接赋给 long，8086 上用 dd 定义",
+}
@@ -0,0 +1,263 @@
+package optimize
+
+import (
+	"compiler/parser"
+	"strconv"
+)
+
+// Optimize 按优化级别改写 AST：
+//
+//	0  不做优化
+//	1  常量折叠（按 16 位有符号整数计算，加减乘溢出、除数为 0 和 -32768 / -1 的表达式保留到运行时），
+//	   用到命名常量的地方替换为它的值
+//	2  在 1 的基础上删除条件恒定的 if 分支以及条件恒为假的 while、for 循环
+func Optimize(ast *parser.AST, level int) *parser.AST {
+	if level <= 0 {
+		return ast
+	}
+	o := &optimizer{level: level, consts: make(map[string]parser.Expr)}
+	return &parser.AST{Statements: o.block(ast.Statements)}
+}
+
+type optimizer struct {
+	level  int
+	consts map[string]parser.Expr // 已声明的常量折叠后的值：数字、布尔值或字符
+}
+
+func (o *optimizer) block(stmts []parser.Statement) []parser.Statement {
+	out := make([]parser.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		out = append(out, o.statement(stmt)...)
+	}
+	return out
+}
+
+// statement 返回优化后的语句，删除分支时可能返回零条或多条语句
+func (o *optimizer) statement(stmt parser.Statement) []parser.Statement {
+	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		// 保留声明，汇编中仍会定义这个常量
+		value := o.expr(s.Value)
+		if _, ok := constValue(value); ok {
+			o.consts[s.Name] = value
+		}
+		return []parser.Statement{&parser.ConstDeclaration{Pos: s.Pos, Name: s.Name, Value: value}}
+	case *parser.VarDeclaration:
+		var value parser.Expr
+		if s.Value != nil {
+			value = o.expr(s.Value)
+		}
+		return []parser.Statement{&parser.VarDeclaration{Pos: s.Pos, Type: s.Type, Name: s.Name, Value: value}}
+	case *parser.Assignment:
+		return []parser.Statement{&parser.Assignment{Pos: s.Pos, Ident: s.Ident, Value: o.expr(s.Value)}}
+	case *parser.CompoundAssignment:
+		return []parser.Statement{&parser.CompoundAssignment{Pos: s.Pos, Ident: s.Ident, Op: s.Op, Value: o.expr(s.Value)}}
+	case *parser.PrintStatement:
+		return []parser.Statement{&parser.PrintStatement{Pos: s.Pos, Expr: o.expr(s.Expr)}}
+	case *parser.IfStatement:
+		cond := o.expr(s.Condition)
+		if v, ok := constValue(cond); ok && o.level >= 2 {
+			if v != 0 {
+				return o.block(s.Then)
+			}
+			return o.block(s.Else)
+		}
+		els := o.block(s.Else)
+		// else if 中的 if 可能被删除或展开，此时不再是 else if
+		elseIf := false
+		if len(els) == 1 {
+			_, elseIf = els[0].(*parser.IfStatement)
+		}
+		return []parser.Statement{&parser.IfStatement{
+			Pos:       s.Pos,
+			Condition: cond,
+			Then:      o.block(s.Then),
+			Else:      els,
+			ThenEnd:   s.ThenEnd,
+			ElseEnd:   s.ElseEnd,
+			ElseIf:    s.ElseIf && elseIf,
+		}}
+	case *parser.WhileStatement:
+		cond := o.expr(s.Condition)
+		if v, ok := constValue(cond); ok && v == 0 && o.level >= 2 {
+			return nil
+		}
+		return []parser.Statement{&parser.WhileStatement{
+			Pos:       s.Pos,
+			Condition: cond,
+			Body:      o.block(s.Body),
+			End:       s.End,
+		}}
+	case *parser.DoWhileStatement:
+		// 循环体至少执行一次，且可能含有 break、continue，条件恒为假时也保留循环
+		return []parser.Statement{&parser.DoWhileStatement{
+			Pos:       s.Pos,
+			Body:      o.block(s.Body),
+			Condition: o.expr(s.Condition),
+			End:       s.End,
+		}}
+	case *parser.SwitchStatement:
+		// 分支中的 break 作用于 switch，值为常量时也不能直接展开分支
+		cases := make([]*parser.CaseClause, len(s.Cases))
+		for i, c := range s.Cases {
+			values := make([]parser.Expr, len(c.Values))
+			for j, v := range c.Values {
+				values[j] = o.expr(v)
+			}
+			cases[i] = &parser.CaseClause{Pos: c.Pos, Values: values, Body: o.block(c.Body)}
+		}
+		return []parser.Statement{&parser.SwitchStatement{
+			Pos:   s.Pos,
+			Value: o.expr(s.Value),
+			Cases: cases,
+			End:   s.End,
+		}}
+	case *parser.ForStatement:
+		var cond parser.Expr
+		if s.Condition != nil {
+			cond = o.expr(s.Condition)
+			if v, ok := constValue(cond); ok && v == 0 && o.level >= 2 {
+				// 循环体一次也不执行，只保留初始化部分
+				if s.Init == nil {
+					return nil
+				}
+				return o.statement(s.Init)
+			}
+		}
+		return []parser.Statement{&parser.ForStatement{
+			Pos:       s.Pos,
+			Init:      o.simple(s.Init),
+			Condition: cond,
+			Step:      o.simple(s.Step),
+			Body:      o.block(s.Body),
+			End:       s.End,
+		}}
+	}
+	return []parser.Statement{stmt}
+}
+
+// simple 优化 for 的初始化或步进部分，这些部分总是恰好一条赋值语句或 nil
+func (o *optimizer) simple(stmt parser.Statement) parser.Statement {
+	if stmt == nil {
+		return nil
+	}
+	return o.statement(stmt)[0]
+}
+
+func (o *optimizer) expr(expr parser.Expr) parser.Expr {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		if v, ok := o.consts[e.Name]; ok {
+			// 替换后的值保持常量的类型
+			return at(e.Pos, v)
+		}
+	case *parser.BinaryExpr:
+		left, right := o.expr(e.Left), o.expr(e.Right)
+		l, lok := constValue(left)
+		r, rok := constValue(right)
+		if lok && rok {
+			switch e.Op {
+			// 结果溢出的加减乘留到运行时计算，--overflow=trap 时由运行时检查报告
+			case "+":
+				if v := int32(l) + int32(r); v == int32(int16(v)) {
+					return number(e.Pos, int16(v))
+				}
+			case "-":
+				if v := int32(l) - int32(r); v == int32(int16(v)) {
+					return number(e.Pos, int16(v))
+				}
+			case "*":
+				if v := int32(l) * int32(r); v == int32(int16(v)) {
+					return number(e.Pos, int16(v))
+				}
+			case "/":
+				if r != 0 && !(l == -32768 && r == -1) {
+					return number(e.Pos, l/r)
+				}
+			case "%":
+				// -32768 % -1 的余数 0 没有溢出，照常折叠
+				if r != 0 {
+					return number(e.Pos, l%r)
+				}
+			case "&":
+				return number(e.Pos, l&r)
+			case "|":
+				return number(e.Pos, l|r)
+			case "^":
+				return number(e.Pos, l^r)
+			case "<<":
+				return number(e.Pos, l<<(uint16(r)&15))
+			case ">>":
+				return number(e.Pos, l>>(uint16(r)&15))
+			}
+		}
+		return &parser.BinaryExpr{Pos: e.Pos, Op: e.Op, Left: left, Right: right}
+	case *parser.ComparisonExpr:
+		left, right := o.expr(e.Left), o.expr(e.Right)
+		l, lok := constValue(left)
+		r, rok := constValue(right)
+		if lok && rok {
+			var v bool
+			switch e.Op {
+			case "==":
+				v = l == r
+			case "!=":
+				v = l != r
+			case "<":
+				v = l < r
+			case ">":
+				v = l > r
+			case "<=":
+				v = l <= r
+			case ">=":
+				v = l >= r
+			}
+			return &parser.BooleanExpr{Pos: e.Pos, Value: v}
+		}
+		return &parser.ComparisonExpr{Pos: e.Pos, Op: e.Op, Left: left, Right: right}
+	case *parser.UnaryExpr:
+		operand := o.expr(e.Operand)
+		if v, ok := constValue(operand); ok && e.Op == "~" {
+			return number(e.Pos, ^v)
+		}
+		return &parser.UnaryExpr{Pos: e.Pos, Op: e.Op, Operand: operand}
+	}
+	return expr
+}
+
+// constValue 返回常量表达式的 16 位值
+func constValue(expr parser.Expr) (int16, bool) {
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		n, err := strconv.ParseInt(e.Value, 10, 16)
+		if err != nil {
+			return 0, false
+		}
+		return int16(n), true
+	case *parser.BooleanExpr:
+		if e.Value {
+			return 1, true
+		}
+		return 0, true
+	case *parser.CharExpr:
+		return int16(e.Value), true
+	}
+	return 0, false
+}
+
+// at 返回位于 pos 处的常量值 v 的副本
+func at(pos parser.Pos, v parser.Expr) parser.Expr {
+	switch v := v.(type) {
+	case *parser.BooleanExpr:
+		return &parser.BooleanExpr{Pos: pos, Value: v.Value}
+	case *parser.CharExpr:
+		return &parser.CharExpr{Pos: pos, Value: v.Value}
+	case *parser.NumberExpr:
+		return &parser.NumberExpr{Pos: pos, Value: v.Value}
+	}
+	return v
+}
+
+func number(pos parser.Pos, v int16) parser.Expr {
+	return &parser.NumberExpr{Pos: pos, Value: strconv.Itoa(int(v))}
+}
@@ -4,49 +4,223 @@ type AST struct {
 	Statements []Statement
 }
 
+// Pos 是节点在源代码中的位置（行、列均从 1 开始）。
+// File 是节点所在的源文件在编译的文件列表中的下标，主文件和单文件程序为 0，
+// 导入的文件按第一次导入的顺序从 1 开始编号
+type Pos struct {
+	Line   int
+	Column int
+	File   int
+}
+
+// Position 返回节点的位置，供诊断信息使用
+func (p Pos) Position() Pos { return p }
+
 type Statement interface {
 	stmtNode()
+	Position() Pos
 }
 
 type Assignment struct {
+	Pos
 	Ident string
 	Value Expr
 }
 
 func (a *Assignment) stmtNode() {}
 
+// CompoundAssignment 是复合赋值 x += 表达式，Op 是去掉等号的运算符 "+"、"-"、"*"、"/"、"%"
+type CompoundAssignment struct {
+	Pos
+	Ident string
+	Op    string
+	Value Expr
+}
+
+func (c *CompoundAssignment) stmtNode() {}
+
+// Desugar 返回等价的普通赋值 x = x op 表达式
+func (c *CompoundAssignment) Desugar() *Assignment {
+	return &Assignment{Pos: c.Pos, Ident: c.Ident, Value: &BinaryExpr{
+		Pos:   c.Pos,
+		Op:    c.Op,
+		Left:  &IdentExpr{Pos: c.Pos, Name: c.Ident},
+		Right: c.Value,
+	}}
+}
+
+// IncDecStatement 是 x++、x--、++x、--x 语句，前缀和后缀形式的效果相同
+type IncDecStatement struct {
+	Pos
+	Ident  string
+	Op     string // "++" 或 "--"
+	Prefix bool   // 源代码写的是前缀形式
+}
+
+func (i *IncDecStatement) stmtNode() {}
+
+// Desugar 返回等价的普通赋值 x = x + 1 或 x = x - 1
+func (i *IncDecStatement) Desugar() *Assignment {
+	op := "+"
+	if i.Op == "--" {
+		op = "-"
+	}
+	return (&CompoundAssignment{Pos: i.Pos, Ident: i.Ident, Op: op, Value: &NumberExpr{Pos: i.Pos, Value: "1"}}).Desugar()
+}
+
+// ConstDeclaration 声明一个命名常量 const N = 表达式;，初始值在编译期计算，
+// 只能由数字、true/false 和之前声明的常量组成。常量只能在文件顶层声明
+type ConstDeclaration struct {
+	Pos
+	Name  string
+	Value Expr
+}
+
+func (c *ConstDeclaration) stmtNode() {}
+
+// VarDeclaration 声明一个带类型的变量 int x = 3;，省略初始值时 Value 为 nil，
+// 变量的值为 0、false 或 '\0'
+type VarDeclaration struct {
+	Pos
+	Type  Type
+	Name  string
+	Value Expr
+}
+
+func (v *VarDeclaration) stmtNode() {}
+
+// Desugar 返回等价的普通赋值 x = 初始值，省略初始值时赋类型的零值
+func (v *VarDeclaration) Desugar() *Assignment {
+	value := v.Value
+	if value == nil {
+		switch v.Type {
+		case TYPE_BOOL:
+			value = &BooleanExpr{Pos: v.Pos, Value: false}
+		case TYPE_CHAR:
+			value = &CharExpr{Pos: v.Pos, Value: 0}
+		default:
+			value = &NumberExpr{Pos: v.Pos, Value: "0"}
+		}
+	}
+	return &Assignment{Pos: v.Pos, Ident: v.Name, Value: value}
+}
+
 type PrintStatement struct {
+	Pos
 	Expr Expr
 }
 
 func (p *PrintStatement) stmtNode() {}
 
 type InputStatement struct {
+	Pos
 	Ident string
 }
 
 func (i *InputStatement) stmtNode() {}
 
 type IfStatement struct {
+	Pos
 	Condition Expr
 	Then      []Statement
 	Else      []Statement
+	ThenEnd   Pos // then 分支右花括号的位置
+	ElseEnd   Pos // else 分支右花括号的位置，没有 else 时为零值
+	// ElseIf 为真表示源代码写的是 else if，此时 Else 只有一条 IfStatement，
+	// ElseEnd 是整个 else if 链最后一个右花括号的位置
+	ElseIf bool
 }
 
 func (i *IfStatement) stmtNode() {}
 
+// end 返回 if 语句最后一个右花括号的位置
+func (i *IfStatement) end() Pos {
+	if len(i.Else) > 0 {
+		return i.ElseEnd
+	}
+	return i.ThenEnd
+}
+
 type WhileStatement struct {
+	Pos
 	Condition Expr
 	Body      []Statement
+	End       Pos // 右花括号的位置
 }
 
 func (w *WhileStatement) stmtNode() {}
 
+// DoWhileStatement 先执行循环体，再在循环末尾检查条件
+type DoWhileStatement struct {
+	Pos
+	Body      []Statement
+	Condition Expr
+	End       Pos // 右花括号的位置
+}
+
+func (d *DoWhileStatement) stmtNode() {}
+
+// ForStatement 是 C 风格的 for 循环，Init、Condition、Step 都可以省略，
+// 省略 Condition 表示条件恒为真
+type ForStatement struct {
+	Pos
+	Init      Statement // 循环开始前执行一次的赋值语句，可为 nil
+	Condition Expr      // 可为 nil
+	Step      Statement // 每次循环体结束后（包括 continue 后）执行的赋值语句，可为 nil
+	Body      []Statement
+	End       Pos // 右花括号的位置
+}
+
+func (f *ForStatement) stmtNode() {}
+
+// SwitchStatement 按 Value 的值选择执行一个分支。分支执行完后跳出 switch，
+// 不会落入下一个分支
+type SwitchStatement struct {
+	Pos
+	Value Expr
+	Cases []*CaseClause
+	End   Pos // 右花括号的位置
+}
+
+func (s *SwitchStatement) stmtNode() {}
+
+// CaseClause 是 switch 中的一个 case 或 default 分支
+type CaseClause struct {
+	Pos
+	Values []Expr // case 后的常量表达式，default 分支为空
+	Body   []Statement
+}
+
+// BreakStatement 跳出最内层的循环或 switch
+type BreakStatement struct {
+	Pos
+}
+
+func (b *BreakStatement) stmtNode() {}
+
+// ContinueStatement 跳到最内层循环的下一次迭代
+type ContinueStatement struct {
+	Pos
+}
+
+func (c *ContinueStatement) stmtNode() {}
+
+// ImportStatement 导入另一个源文件。编译时被展开为该文件的语句，
+// 同一个文件只在第一次导入的位置展开一次。
+type ImportStatement struct {
+	Pos
+	Path string // 源代码中写的路径，相对于导入它的文件
+}
+
+func (i *ImportStatement) stmtNode() {}
+
 type Expr interface {
 	exprNode()
+	Position() Pos
 }
 
 type BinaryExpr struct {
+	Pos
 	Op    string
 	Left  Expr
 	Right Expr
@@ -54,25 +228,46 @@ type BinaryExpr struct {
 
 func (b *BinaryExpr) exprNode() {}
 
+// UnaryExpr 是一元运算，目前只有按位取反 ~
+type UnaryExpr struct {
+	Pos
+	Op      string
+	Operand Expr
+}
+
+func (u *UnaryExpr) exprNode() {}
+
 type IdentExpr struct {
+	Pos
 	Name string
 }
 
 func (i *IdentExpr) exprNode() {}
 
 type NumberExpr struct {
+	Pos
 	Value string
 }
 
 func (n *NumberExpr) exprNode() {}
 
 type BooleanExpr struct {
+	Pos
 	Value bool
 }
 
 func (b *BooleanExpr) exprNode() {}
 
+// CharExpr 是字符常量 'A'
+type CharExpr struct {
+	Pos
+	Value byte
+}
+
+func (c *CharExpr) exprNode() {}
+
 type ComparisonExpr struct {
+	Pos
 	Op    string
 	Left  Expr
 	Right Expr
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"compiler/lexer"
+	"compiler/msg"
+	"testing"
+)
+
+// errorPosition 返回解析和检查 source 时第一个错误的编号和位置，没有错误时 code 为空
+func errorPosition(t *testing.T, source string) (code msg.Code, line, column int) {
+	t.Helper()
+	_, err := NewParser(lexer.NewLexer(source)).Parse()
+	switch e := err.(type) {
+	case nil:
+		return "", 0, 0
+	case *SemanticError:
+		return e.Code, e.Line, e.Column
+	case *ParseError:
+		return e.Code, e.Line, e.Column
+	case *lexer.LexerError:
+		return e.Code, e.Line, e.Column
+	}
+	t.Fatalf("%q：未知的错误 %T：%v", source, err, err)
+	return
+}
+
+// TestCheckErrors 检查类型检查、break/continue 的位置等语义检查（以及 for 子句等语法检查）报告的错误编号和位置
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		code   msg.Code
+		line   int
+		column int
+	}{
+		{"bool operand", "print true + 3;", msg.SEM_TYPE_OPERAND, 1, 7},
+		{"char operand", "char c = 'a';\nprint c * 2;", msg.SEM_TYPE_OPERAND, 2, 7},
+		{"unary operand", "bool b;\nprint ~b;", msg.SEM_TYPE_OPERAND, 2, 8},
+		{"int condition", "if (5) {\n}", msg.SEM_TYPE_CONDITION, 1, 5},
+		{"while condition", "int x;\nwhile (x) {\n}", msg.SEM_TYPE_CONDITION, 2, 8},
+		{"char from int", "char c = 65;", msg.SEM_TYPE_ASSIGN, 1, 10},
+		{"long to int", "long a = 1;\nint b;\nb = a;", msg.SEM_TYPE_ASSIGN, 3, 5},
+		{"bool to int", "int x = true;", msg.SEM_TYPE_ASSIGN, 1, 9},
+		{"long switch", "long a = 1;\nswitch (a) {\ncase 1:\n    break;\n}", msg.SEM_TYPE_SWITCH, 2, 9},
+		{"case type", "char c = 'a';\nswitch (c) {\ncase 1:\n    break;\n}", msg.SEM_TYPE_CASE, 3, 6},
+		{"compare types", "print true == 1;", msg.SEM_TYPE_COMPARE, 1, 12},
+		{"order bool", "print true < false;", msg.SEM_TYPE_ORDER, 1, 12},
+		{"input bool", "bool b;\ninput b;", msg.SEM_TYPE_INPUT, 2, 1},
+		{"redeclared", "int x;\nint x;", msg.SEM_REDECLARED, 2, 1},
+		{"undefined", "int x;\nprint x + y;", msg.SEM_UNDEFINED_VAR, 2, 11},
+		{"undefined compound", "y += 1;", msg.SEM_UNDEFINED_VAR, 1, 1},
+		{"long range", "long a = 5000000000;", msg.SEM_LONG_RANGE, 1, 10},
+		{"break outside", "int x;\nbreak;", msg.SEM_BREAK_OUTSIDE, 2, 1},
+		{"break in if", "if (true) {\n    break;\n}", msg.SEM_BREAK_OUTSIDE, 2, 5},
+		{"continue outside", "continue;", msg.SEM_CONTINUE_OUTSIDE, 1, 1},
+		{"continue in switch", "int x;\nswitch (x) {\ncase 1:\n    continue;\n}", msg.SEM_CONTINUE_OUTSIDE, 4, 5},
+		{"continue in loop switch", "int x;\nwhile (x < 3) {\n    switch (x) {\n    case 1:\n        continue;\n    }\n    x++;\n}", "", 0, 0},
+		{"break in switch", "int x;\nswitch (x) {\ncase 1:\n    break;\n}", "", 0, 0},
+		{"duplicate case", "int x;\nswitch (x) {\ncase 1:\n    break;\ncase 1:\n    break;\n}", msg.SEM_DUPLICATE_CASE, 5, 6},
+		{"duplicate case char", "char c;\nswitch (c) {\ncase 'a':\ncase 'a':\n    break;\n}", msg.SEM_DUPLICATE_CASE, 4, 6},
+		{"duplicate default", "int x;\nswitch (x) {\ndefault:\n    break;\ndefault:\n    break;\n}", msg.SEM_DUPLICATE_DEFAULT, 5, 1},
+		{"const not constant", "int x = 1;\nconst N = x + 1;", msg.SEM_NOT_CONSTANT, 2, 11},
+		{"const assign", "const N = 1;\nN = 2;", msg.SEM_CONST_ASSIGN, 2, 1},
+		{"const compound assign", "const N = 1;\nN += 2;", msg.SEM_CONST_ASSIGN, 2, 1},
+		{"const input", "const N = 1;\ninput N;", msg.SEM_CONST_ASSIGN, 2, 1},
+		{"const redeclared", "const N = 1;\nconst N = 2;", msg.SEM_CONST_REDECLARED, 2, 1},
+		{"const is var", "int N;\nconst N = 1;", msg.SEM_CONST_IS_VAR, 2, 1},
+		{"const div zero", "const Z = 1/0;", msg.SEM_CONST_DIV_ZERO, 1, 12},
+		{"const mod zero", "const Z = 1;\nconst W = 5 % (Z - 1);", msg.SEM_CONST_DIV_ZERO, 2, 13},
+		{"const long", "const N = 40000;", msg.SEM_CONST_LONG, 1, 11},
+		{"const case", "const A = 1;\nconst B = A + 1;\nint x;\nswitch (x) {\ncase A:\ncase B:\n    break;\n}", "", 0, 0},
+		{"const duplicate case", "const A = 1;\nint x;\nswitch (x) {\ncase A:\ncase 1:\n    break;\n}", msg.SEM_DUPLICATE_CASE, 5, 6},
+		{"variable case", "int a = 1;\nint x;\nswitch (x) {\ncase a:\n    break;\n}", msg.SEM_NOT_CONSTANT, 4, 6},
+		{"for init print", "for (print 1; true; ) {\n}", msg.PARSE_FOR_CLAUSE, 1, 6},
+		{"for init declaration", "for (int i = 0; i < 3; i++) {\n}", msg.PARSE_FOR_CLAUSE, 1, 6},
+		{"for step declaration", "int i;\nfor (i = 0; i < 3; int j = 1) {\n}", msg.PARSE_FOR_CLAUSE, 2, 20},
+		{"for step input", "int i;\nfor (i = 0; i < 3; input i) {\n}", msg.PARSE_FOR_CLAUSE, 2, 20},
+		{"long widening", "int a = 1;\nlong b = a;\nb = b * 100000;", "", 0, 0},
+	}
+	for _, tt := range tests {
+		code, line, column := errorPosition(t, tt.source)
+		if code != tt.code || line != tt.line || column != tt.column {
+			t.Errorf("%s：错误 %s 第%d行第%d列，期望 %s 第%d行第%d列", tt.name, code, line, column, tt.code, tt.line, tt.column)
+		}
+	}
+}
+
+// TestEvalConst 检查常量表达式按 16 位回绕计算，以及超出范围的数字报告 S016
+// （声明常量时超出 int 的数字先被检查为 long，报告 S026，所以直接调用 EvalConst）
+func TestEvalConst(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int16
+	}{
+		{"200 * 200", -25536},
+		{"32767 + 1", -32768},
+		{"-32768 / -1", -32768},
+		{"-7 % 2", -1},
+		{"1 << 17", 2},
+		{"'A' + 1", 66},
+		{"A * 2 + (3 > 2)", 11},
+	}
+	consts := map[string]int16{"A": 5}
+	for _, tt := range tests {
+		ast, err := NewParser(lexer.NewLexer("x = " + tt.expr + ";")).ParseProgram()
+		if err != nil {
+			t.Fatalf("%s：%v", tt.expr, err)
+		}
+		got, err := EvalConst(ast.Statements[0].(*Assignment).Value, consts)
+		if err != nil {
+			t.Errorf("%s：%v", tt.expr, err)
+		} else if got != tt.want {
+			t.Errorf("%s = %d，期望 %d", tt.expr, got, tt.want)
+		}
+	}
+
+	_, err := EvalConst(&NumberExpr{Pos: Pos{Line: 1, Column: 11}, Value: "40000"}, nil)
+	if e, ok := err.(*SemanticError); !ok || e.Code != msg.SEM_NUMBER_RANGE || e.Line != 1 || e.Column != 11 {
+		t.Errorf("40000：错误 %v，期望 %s 第1行第11列", err, msg.SEM_NUMBER_RANGE)
+	}
+}
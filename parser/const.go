@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"compiler/msg"
+	"strconv"
+)
+
+// EvalConst 在编译期计算常量表达式的值，consts 是已经声明的常量。
+// 运算按 16 位有符号整数回绕，与运行时一致；比较和布尔值的结果为 0 或 1，字符的值为其 ASCII 码。
+// 表达式中出现变量、数字超出范围或除数为 0 时返回语义错误。
+func EvalConst(expr Expr, consts map[string]int16) (int16, error) {
+	switch e := expr.(type) {
+	case *NumberExpr:
+		n, err := strconv.ParseInt(e.Value, 10, 16)
+		if err != nil {
+			return 0, newSemanticError(e.Pos, msg.SEM_NUMBER_RANGE, e.Value)
+		}
+		return int16(n), nil
+	case *BooleanExpr:
+		if e.Value {
+			return 1, nil
+		}
+		return 0, nil
+	case *CharExpr:
+		return int16(e.Value), nil
+	case *IdentExpr:
+		v, ok := consts[e.Name]
+		if !ok {
+			return 0, newSemanticError(e.Pos, msg.SEM_NOT_CONSTANT, e.Name)
+		}
+		return v, nil
+	case *UnaryExpr:
+		v, err := EvalConst(e.Operand, consts)
+		if err != nil {
+			return 0, err
+		}
+		return ^v, nil
+	case *BinaryExpr:
+		l, err := EvalConst(e.Left, consts)
+		if err != nil {
+			return 0, err
+		}
+		r, err := EvalConst(e.Right, consts)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/", "%":
+			if r == 0 {
+				return 0, newSemanticError(e.Pos, msg.SEM_CONST_DIV_ZERO)
+			}
+			if e.Op == "/" {
+				return l / r, nil
+			}
+			return l % r, nil
+		case "&":
+			return l & r, nil
+		case "|":
+			return l | r, nil
+		case "^":
+			return l ^ r, nil
+		case "<<":
+			return l << (uint16(r) & 15), nil
+		case ">>":
+			return l >> (uint16(r) & 15), nil
+		}
+	case *ComparisonExpr:
+		l, err := EvalConst(e.Left, consts)
+		if err != nil {
+			return 0, err
+		}
+		r, err := EvalConst(e.Right, consts)
+		if err != nil {
+			return 0, err
+		}
+		var v bool
+		switch e.Op {
+		case "==":
+			v = l == r
+		case "!=":
+			v = l != r
+		case "<":
+			v = l < r
+		case ">":
+			v = l > r
+		case "<=":
+			v = l <= r
+		case ">=":
+			v = l >= r
+		}
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	// 语法分析不会产生其他表达式和运算符
+	return 0, nil
+}
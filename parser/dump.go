@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"compiler/lexer"
+	"fmt"
+	"strings"
+)
+
+// Dump 将 AST 输出为缩进的树形文本，用于调试和教学
+func Dump(ast *AST) string {
+	var sb strings.Builder
+	sb.WriteString("AST\n")
+	for _, stmt := range ast.Statements {
+		dumpStatement(&sb, stmt, 1)
+	}
+	return sb.String()
+}
+
+func dumpLine(sb *strings.Builder, depth int, format string, args ...interface{}) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(sb, format, args...)
+	sb.WriteString("\n")
+}
+
+func dumpStatement(sb *strings.Builder, stmt Statement, depth int) {
+	switch s := stmt.(type) {
+	case *Assignment:
+		dumpLine(sb, depth, "Assignment %s", s.Ident)
+		dumpExpr(sb, s.Value, depth+1)
+	case *CompoundAssignment:
+		dumpLine(sb, depth, "CompoundAssignment %s %s=", s.Ident, s.Op)
+		dumpExpr(sb, s.Value, depth+1)
+	case *IncDecStatement:
+		if s.Prefix {
+			dumpLine(sb, depth, "IncDecStatement %s%s", s.Op, s.Ident)
+		} else {
+			dumpLine(sb, depth, "IncDecStatement %s%s", s.Ident, s.Op)
+		}
+	case *ConstDeclaration:
+		dumpLine(sb, depth, "ConstDeclaration %s", s.Name)
+		dumpExpr(sb, s.Value, depth+1)
+	case *VarDeclaration:
+		dumpLine(sb, depth, "VarDeclaration %s %s", s.Type, s.Name)
+		if s.Value != nil {
+			dumpExpr(sb, s.Value, depth+1)
+		}
+	case *PrintStatement:
+		dumpLine(sb, depth, "PrintStatement")
+		dumpExpr(sb, s.Expr, depth+1)
+	case *InputStatement:
+		dumpLine(sb, depth, "InputStatement %s", s.Ident)
+	case *ImportStatement:
+		dumpLine(sb, depth, "ImportStatement %q", s.Path)
+	case *IfStatement:
+		dumpLine(sb, depth, "IfStatement")
+		dumpLine(sb, depth+1, "Condition")
+		dumpExpr(sb, s.Condition, depth+2)
+		dumpLine(sb, depth+1, "Then")
+		for _, stmt := range s.Then {
+			dumpStatement(sb, stmt, depth+2)
+		}
+		if len(s.Else) > 0 {
+			dumpLine(sb, depth+1, "Else")
+			for _, stmt := range s.Else {
+				dumpStatement(sb, stmt, depth+2)
+			}
+		}
+	case *WhileStatement:
+		dumpLine(sb, depth, "WhileStatement")
+		dumpLine(sb, depth+1, "Condition")
+		dumpExpr(sb, s.Condition, depth+2)
+		dumpLine(sb, depth+1, "Body")
+		for _, stmt := range s.Body {
+			dumpStatement(sb, stmt, depth+2)
+		}
+	case *DoWhileStatement:
+		dumpLine(sb, depth, "DoWhileStatement")
+		dumpLine(sb, depth+1, "Body")
+		for _, stmt := range s.Body {
+			dumpStatement(sb, stmt, depth+2)
+		}
+		dumpLine(sb, depth+1, "Condition")
+		dumpExpr(sb, s.Condition, depth+2)
+	case *ForStatement:
+		dumpLine(sb, depth, "ForStatement")
+		if s.Init != nil {
+			dumpLine(sb, depth+1, "Init")
+			dumpStatement(sb, s.Init, depth+2)
+		}
+		if s.Condition != nil {
+			dumpLine(sb, depth+1, "Condition")
+			dumpExpr(sb, s.Condition, depth+2)
+		}
+		if s.Step != nil {
+			dumpLine(sb, depth+1, "Step")
+			dumpStatement(sb, s.Step, depth+2)
+		}
+		dumpLine(sb, depth+1, "Body")
+		for _, stmt := range s.Body {
+			dumpStatement(sb, stmt, depth+2)
+		}
+	case *SwitchStatement:
+		dumpLine(sb, depth, "SwitchStatement")
+		dumpLine(sb, depth+1, "Value")
+		dumpExpr(sb, s.Value, depth+2)
+		for _, c := range s.Cases {
+			if len(c.Values) == 0 {
+				dumpLine(sb, depth+1, "Default")
+			} else {
+				dumpLine(sb, depth+1, "Case")
+				dumpLine(sb, depth+2, "Values")
+				for _, v := range c.Values {
+					dumpExpr(sb, v, depth+3)
+				}
+			}
+			dumpLine(sb, depth+2, "Body")
+			for _, stmt := range c.Body {
+				dumpStatement(sb, stmt, depth+3)
+			}
+		}
+	case *BreakStatement:
+		dumpLine(sb, depth, "BreakStatement")
+	case *ContinueStatement:
+		dumpLine(sb, depth, "ContinueStatement")
+	}
+}
+
+func dumpExpr(sb *strings.Builder, expr Expr, depth int) {
+	switch e := expr.(type) {
+	case *NumberExpr:
+		dumpLine(sb, depth, "NumberExpr %s", e.Value)
+	case *IdentExpr:
+		dumpLine(sb, depth, "IdentExpr %s", e.Name)
+	case *BooleanExpr:
+		dumpLine(sb, depth, "BooleanExpr %t", e.Value)
+	case *CharExpr:
+		dumpLine(sb, depth, "CharExpr %s", lexer.QuoteChar(e.Value))
+	case *BinaryExpr:
+		dumpLine(sb, depth, "BinaryExpr %s", e.Op)
+		dumpExpr(sb, e.Left, depth+1)
+		dumpExpr(sb, e.Right, depth+1)
+	case *ComparisonExpr:
+		dumpLine(sb, depth, "ComparisonExpr %s", e.Op)
+		dumpExpr(sb, e.Left, depth+1)
+		dumpExpr(sb, e.Right, depth+1)
+	case *UnaryExpr:
+		dumpLine(sb, depth, "UnaryExpr %s", e.Op)
+		dumpExpr(sb, e.Operand, depth+1)
+	}
+}
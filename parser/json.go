@@ -0,0 +1,485 @@
+package parser
+
+import (
+	"bytes"
+	"compiler/msg"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONVersion 是 AST JSON 格式的版本号，格式发生不兼容的变化时递增
+const JSONVersion = 1
+
+// AST 的 JSON 格式：
+//
+//	{"version": 1, "statements": [节点, ...]}
+//
+// 每个节点都有 kind（节点类型名，如 "Assignment"）、line 和 column，导入的文件中的节点还有 file（见 Pos.File），
+// 其余字段按类型出现
+// （thenEnd、elseEnd、end 是右花括号的位置，形如 {"line": 3, "column": 1}）：
+//
+//	Assignment      ident, expr
+//	CompoundAssignment ident, op（"+"、"-"、"*"、"/"、"%"）, expr
+//	IncDecStatement ident, op（"++" 或 "--"）, prefix
+//	ConstDeclaration name, expr
+//	VarDeclaration  name, type（"int"、"long"、"bool" 或 "char"）, expr（没有初始值时不出现）
+//	PrintStatement  expr
+//	InputStatement  ident
+//	ImportStatement path
+//	IfStatement     condition, then, else, thenEnd, elseEnd, elseIf（源代码写的是 else if 时为 true）
+//	WhileStatement  condition, body, end
+//	DoWhileStatement body, condition, end
+//	SwitchStatement expr（要比较的值）, cases, end
+//	CaseClause      values（常量表达式数组，default 分支没有）, body
+//	ForStatement    init, condition, step, body, end（init、condition、step 省略时不出现）
+//	BreakStatement
+//	ContinueStatement
+//	BinaryExpr      op, left, right
+//	ComparisonExpr  op, left, right
+//	UnaryExpr       op, expr
+//	IdentExpr       name
+//	NumberExpr      value（字符串，保留源代码中的写法）
+//	BooleanExpr     value（布尔值）
+//	CharExpr        value（只含一个 ASCII 字符的字符串）
+type jsonAST struct {
+	Version    int         `json:"version"`
+	Statements []*jsonNode `json:"statements"`
+}
+
+type jsonNode struct {
+	Kind      string          `json:"kind"`
+	Line      int             `json:"line"`
+	Column    int             `json:"column"`
+	File      int             `json:"file,omitempty"`
+	Ident     string          `json:"ident,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Op        string          `json:"op,omitempty"`
+	Path      string          `json:"path,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Expr      *jsonNode       `json:"expr,omitempty"`
+	Init      *jsonNode       `json:"init,omitempty"`
+	Condition *jsonNode       `json:"condition,omitempty"`
+	Step      *jsonNode       `json:"step,omitempty"`
+	Left      *jsonNode       `json:"left,omitempty"`
+	Right     *jsonNode       `json:"right,omitempty"`
+	Then      []*jsonNode     `json:"then,omitempty"`
+	Else      []*jsonNode     `json:"else,omitempty"`
+	Body      []*jsonNode     `json:"body,omitempty"`
+	Values    []*jsonNode     `json:"values,omitempty"`
+	Cases     []*jsonNode     `json:"cases,omitempty"`
+	ThenEnd   *jsonPos        `json:"thenEnd,omitempty"`
+	ElseEnd   *jsonPos        `json:"elseEnd,omitempty"`
+	End       *jsonPos        `json:"end,omitempty"`
+	ElseIf    bool            `json:"elseIf,omitempty"`
+	Prefix    bool            `json:"prefix,omitempty"`
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// MarshalJSON 把 AST 编码为带缩进的 JSON
+func MarshalJSON(ast *AST) ([]byte, error) {
+	doc := jsonAST{Version: JSONVersion, Statements: encodeStatements(ast.Statements)}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // 保持 < > 等运算符可读
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// UnmarshalJSON 把 MarshalJSON 输出的 JSON 解码为 AST
+func UnmarshalJSON(data []byte) (*AST, error) {
+	var doc jsonAST
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != JSONVersion {
+		return nil, msg.Errorf(msg.JSON_BAD_VERSION, doc.Version)
+	}
+	stmts, err := decodeStatements(doc.Statements)
+	if err != nil {
+		return nil, err
+	}
+	return &AST{Statements: stmts}, nil
+}
+
+func newJSONNode(kind string, pos Pos) *jsonNode {
+	return &jsonNode{Kind: kind, Line: pos.Line, Column: pos.Column, File: pos.File}
+}
+
+func encodeStatements(stmts []Statement) []*jsonNode {
+	nodes := make([]*jsonNode, 0, len(stmts))
+	for _, stmt := range stmts {
+		nodes = append(nodes, encodeStatement(stmt))
+	}
+	return nodes
+}
+
+func encodeStatement(stmt Statement) *jsonNode {
+	switch s := stmt.(type) {
+	case *Assignment:
+		n := newJSONNode("Assignment", s.Pos)
+		n.Ident = s.Ident
+		n.Expr = encodeExpr(s.Value)
+		return n
+	case *CompoundAssignment:
+		n := newJSONNode("CompoundAssignment", s.Pos)
+		n.Ident = s.Ident
+		n.Op = s.Op
+		n.Expr = encodeExpr(s.Value)
+		return n
+	case *IncDecStatement:
+		n := newJSONNode("IncDecStatement", s.Pos)
+		n.Ident = s.Ident
+		n.Op = s.Op
+		n.Prefix = s.Prefix
+		return n
+	case *ConstDeclaration:
+		n := newJSONNode("ConstDeclaration", s.Pos)
+		n.Name = s.Name
+		n.Expr = encodeExpr(s.Value)
+		return n
+	case *VarDeclaration:
+		n := newJSONNode("VarDeclaration", s.Pos)
+		n.Name = s.Name
+		n.Type = s.Type.String()
+		if s.Value != nil {
+			n.Expr = encodeExpr(s.Value)
+		}
+		return n
+	case *PrintStatement:
+		n := newJSONNode("PrintStatement", s.Pos)
+		n.Expr = encodeExpr(s.Expr)
+		return n
+	case *InputStatement:
+		n := newJSONNode("InputStatement", s.Pos)
+		n.Ident = s.Ident
+		return n
+	case *ImportStatement:
+		n := newJSONNode("ImportStatement", s.Pos)
+		n.Path = s.Path
+		return n
+	case *IfStatement:
+		n := newJSONNode("IfStatement", s.Pos)
+		n.Condition = encodeExpr(s.Condition)
+		n.Then = encodeStatements(s.Then)
+		n.Else = encodeStatements(s.Else)
+		n.ThenEnd = &jsonPos{s.ThenEnd.Line, s.ThenEnd.Column}
+		if len(s.Else) > 0 {
+			n.ElseEnd = &jsonPos{s.ElseEnd.Line, s.ElseEnd.Column}
+		}
+		n.ElseIf = s.ElseIf
+		return n
+	case *WhileStatement:
+		n := newJSONNode("WhileStatement", s.Pos)
+		n.Condition = encodeExpr(s.Condition)
+		n.Body = encodeStatements(s.Body)
+		n.End = &jsonPos{s.End.Line, s.End.Column}
+		return n
+	case *DoWhileStatement:
+		n := newJSONNode("DoWhileStatement", s.Pos)
+		n.Body = encodeStatements(s.Body)
+		n.Condition = encodeExpr(s.Condition)
+		n.End = &jsonPos{s.End.Line, s.End.Column}
+		return n
+	case *ForStatement:
+		n := newJSONNode("ForStatement", s.Pos)
+		if s.Init != nil {
+			n.Init = encodeStatement(s.Init)
+		}
+		if s.Condition != nil {
+			n.Condition = encodeExpr(s.Condition)
+		}
+		if s.Step != nil {
+			n.Step = encodeStatement(s.Step)
+		}
+		n.Body = encodeStatements(s.Body)
+		n.End = &jsonPos{s.End.Line, s.End.Column}
+		return n
+	case *SwitchStatement:
+		n := newJSONNode("SwitchStatement", s.Pos)
+		n.Expr = encodeExpr(s.Value)
+		for _, c := range s.Cases {
+			cn := newJSONNode("CaseClause", c.Pos)
+			for _, v := range c.Values {
+				cn.Values = append(cn.Values, encodeExpr(v))
+			}
+			cn.Body = encodeStatements(c.Body)
+			n.Cases = append(n.Cases, cn)
+		}
+		n.End = &jsonPos{s.End.Line, s.End.Column}
+		return n
+	case *BreakStatement:
+		return newJSONNode("BreakStatement", s.Pos)
+	case *ContinueStatement:
+		return newJSONNode("ContinueStatement", s.Pos)
+	}
+	panic(fmt.Sprintf("未知的语句类型 %T", stmt))
+}
+
+func encodeExpr(expr Expr) *jsonNode {
+	switch e := expr.(type) {
+	case *NumberExpr:
+		n := newJSONNode("NumberExpr", e.Pos)
+		n.Value, _ = json.Marshal(e.Value)
+		return n
+	case *IdentExpr:
+		n := newJSONNode("IdentExpr", e.Pos)
+		n.Name = e.Name
+		return n
+	case *BooleanExpr:
+		n := newJSONNode("BooleanExpr", e.Pos)
+		n.Value, _ = json.Marshal(e.Value)
+		return n
+	case *CharExpr:
+		n := newJSONNode("CharExpr", e.Pos)
+		n.Value, _ = json.Marshal(string(rune(e.Value)))
+		return n
+	case *BinaryExpr:
+		n := newJSONNode("BinaryExpr", e.Pos)
+		n.Op = e.Op
+		n.Left = encodeExpr(e.Left)
+		n.Right = encodeExpr(e.Right)
+		return n
+	case *ComparisonExpr:
+		n := newJSONNode("ComparisonExpr", e.Pos)
+		n.Op = e.Op
+		n.Left = encodeExpr(e.Left)
+		n.Right = encodeExpr(e.Right)
+		return n
+	case *UnaryExpr:
+		n := newJSONNode("UnaryExpr", e.Pos)
+		n.Op = e.Op
+		n.Expr = encodeExpr(e.Operand)
+		return n
+	}
+	panic(fmt.Sprintf("未知的表达式类型 %T", expr))
+}
+
+func decodeStatements(nodes []*jsonNode) ([]Statement, error) {
+	var stmts []Statement
+	for _, n := range nodes {
+		stmt, err := decodeStatement(n)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+func decodeStatement(n *jsonNode) (Statement, error) {
+	if n == nil {
+		return nil, msg.Errorf(msg.JSON_MISSING_STATEMENT)
+	}
+	pos := n.pos()
+	switch n.Kind {
+	case "Assignment":
+		value, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &Assignment{Pos: pos, Ident: n.Ident, Value: value}, nil
+	case "CompoundAssignment":
+		value, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &CompoundAssignment{Pos: pos, Ident: n.Ident, Op: n.Op, Value: value}, nil
+	case "IncDecStatement":
+		return &IncDecStatement{Pos: pos, Ident: n.Ident, Op: n.Op, Prefix: n.Prefix}, nil
+	case "ConstDeclaration":
+		value, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &ConstDeclaration{Pos: pos, Name: n.Name, Value: value}, nil
+	case "VarDeclaration":
+		t, ok := LookupType(n.Type)
+		if !ok {
+			return nil, msg.Errorf(msg.JSON_UNKNOWN_TYPE, n.Line, n.Column, n.Type)
+		}
+		d := &VarDeclaration{Pos: pos, Type: t, Name: n.Name}
+		if n.Expr != nil {
+			value, err := decodeExpr(n.Expr)
+			if err != nil {
+				return nil, err
+			}
+			d.Value = value
+		}
+		return d, nil
+	case "PrintStatement":
+		expr, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &PrintStatement{Pos: pos, Expr: expr}, nil
+	case "InputStatement":
+		return &InputStatement{Pos: pos, Ident: n.Ident}, nil
+	case "ImportStatement":
+		return &ImportStatement{Pos: pos, Path: n.Path}, nil
+	case "IfStatement":
+		cond, err := decodeExpr(n.Condition)
+		if err != nil {
+			return nil, err
+		}
+		then, err := decodeStatements(n.Then)
+		if err != nil {
+			return nil, err
+		}
+		els, err := decodeStatements(n.Else)
+		if err != nil {
+			return nil, err
+		}
+		return &IfStatement{Pos: pos, Condition: cond, Then: then, Else: els, ThenEnd: n.posOf(n.ThenEnd), ElseEnd: n.posOf(n.ElseEnd), ElseIf: n.ElseIf}, nil
+	case "WhileStatement":
+		cond, err := decodeExpr(n.Condition)
+		if err != nil {
+			return nil, err
+		}
+		body, err := decodeStatements(n.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &WhileStatement{Pos: pos, Condition: cond, Body: body, End: n.posOf(n.End)}, nil
+	case "DoWhileStatement":
+		body, err := decodeStatements(n.Body)
+		if err != nil {
+			return nil, err
+		}
+		cond, err := decodeExpr(n.Condition)
+		if err != nil {
+			return nil, err
+		}
+		return &DoWhileStatement{Pos: pos, Body: body, Condition: cond, End: n.posOf(n.End)}, nil
+	case "ForStatement":
+		f := &ForStatement{Pos: pos, End: n.posOf(n.End)}
+		var err error
+		if n.Init != nil {
+			if f.Init, err = decodeStatement(n.Init); err != nil {
+				return nil, err
+			}
+		}
+		if n.Condition != nil {
+			if f.Condition, err = decodeExpr(n.Condition); err != nil {
+				return nil, err
+			}
+		}
+		if n.Step != nil {
+			if f.Step, err = decodeStatement(n.Step); err != nil {
+				return nil, err
+			}
+		}
+		if f.Body, err = decodeStatements(n.Body); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case "SwitchStatement":
+		value, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		s := &SwitchStatement{Pos: pos, Value: value, End: n.posOf(n.End)}
+		for _, cn := range n.Cases {
+			c, err := decodeCaseClause(cn)
+			if err != nil {
+				return nil, err
+			}
+			s.Cases = append(s.Cases, c)
+		}
+		return s, nil
+	case "BreakStatement":
+		return &BreakStatement{Pos: pos}, nil
+	case "ContinueStatement":
+		return &ContinueStatement{Pos: pos}, nil
+	}
+	return nil, msg.Errorf(msg.JSON_UNKNOWN_STATEMENT, n.Line, n.Column, n.Kind)
+}
+
+func decodeCaseClause(n *jsonNode) (*CaseClause, error) {
+	if n == nil || n.Kind != "CaseClause" {
+		return nil, msg.Errorf(msg.JSON_CASE_CLAUSE)
+	}
+	c := &CaseClause{Pos: n.pos()}
+	for _, vn := range n.Values {
+		v, err := decodeExpr(vn)
+		if err != nil {
+			return nil, err
+		}
+		c.Values = append(c.Values, v)
+	}
+	body, err := decodeStatements(n.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Body = body
+	return c, nil
+}
+
+func decodeExpr(n *jsonNode) (Expr, error) {
+	if n == nil {
+		return nil, msg.Errorf(msg.JSON_MISSING_EXPR)
+	}
+	pos := n.pos()
+	switch n.Kind {
+	case "NumberExpr":
+		var value string
+		if err := json.Unmarshal(n.Value, &value); err != nil {
+			return nil, msg.Errorf(msg.JSON_NUMBER_VALUE, n.Line, n.Column)
+		}
+		return &NumberExpr{Pos: pos, Value: value}, nil
+	case "IdentExpr":
+		return &IdentExpr{Pos: pos, Name: n.Name}, nil
+	case "BooleanExpr":
+		var value bool
+		if err := json.Unmarshal(n.Value, &value); err != nil {
+			return nil, msg.Errorf(msg.JSON_BOOLEAN_VALUE, n.Line, n.Column)
+		}
+		return &BooleanExpr{Pos: pos, Value: value}, nil
+	case "CharExpr":
+		var value string
+		if err := json.Unmarshal(n.Value, &value); err != nil || len(value) != 1 || value[0] >= 0x80 {
+			return nil, msg.Errorf(msg.JSON_CHAR_VALUE, n.Line, n.Column)
+		}
+		return &CharExpr{Pos: pos, Value: value[0]}, nil
+	case "BinaryExpr", "ComparisonExpr":
+		left, err := decodeExpr(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := decodeExpr(n.Right)
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind == "BinaryExpr" {
+			return &BinaryExpr{Pos: pos, Op: n.Op, Left: left, Right: right}, nil
+		}
+		return &ComparisonExpr{Pos: pos, Op: n.Op, Left: left, Right: right}, nil
+	case "UnaryExpr":
+		operand, err := decodeExpr(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Pos: pos, Op: n.Op, Operand: operand}, nil
+	}
+	return nil, msg.Errorf(msg.JSON_UNKNOWN_EXPR, n.Line, n.Column, n.Kind)
+}
+
+// pos 返回节点的位置
+func (n *jsonNode) pos() Pos {
+	return Pos{Line: n.Line, Column: n.Column, File: n.File}
+}
+
+// posOf 返回节点中右花括号的位置，它与节点在同一个文件中
+func (n *jsonNode) posOf(p *jsonPos) Pos {
+	if p == nil {
+		return Pos{}
+	}
+	return Pos{Line: p.Line, Column: p.Column, File: n.File}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"compiler/lexer"
+	"compiler/msg"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestJSONRoundTrip 检查示例和一致性测试程序的 AST 编码为 JSON 再解码后 Dump 的结果不变，再次编码得到同样的 JSON
+func TestJSONRoundTrip(t *testing.T) {
+	var sources []string
+	for _, pattern := range []string{"../code/*.src", "../code/conformance/*.src"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			t.Fatalf("找不到 %s：%v", pattern, err)
+		}
+		sources = append(sources, matches...)
+	}
+	for _, path := range sources {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := NewParser(lexer.NewLexer(string(source))).ParseProgram()
+		if err != nil {
+			t.Fatalf("%s：%v", path, err)
+		}
+		data, err := MarshalJSON(ast)
+		if err != nil {
+			t.Fatalf("%s：%v", path, err)
+		}
+		decoded, err := UnmarshalJSON(data)
+		if err != nil {
+			t.Fatalf("%s：解码失败：%v", path, err)
+		}
+		if got, want := Dump(decoded), Dump(ast); got != want {
+			t.Errorf("%s：解码得到\n%s\n期望\n%s", path, got, want)
+		}
+		again, err := MarshalJSON(decoded)
+		if err != nil {
+			t.Fatalf("%s：%v", path, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("%s：再次编码得到\n%s\n期望\n%s", path, again, data)
+		}
+	}
+}
+
+// TestUnmarshalJSONErrors 检查不合法的 JSON 报告的错误编号
+func TestUnmarshalJSONErrors(t *testing.T) {
+	node := func(s string) string { return `{"version": 1, "statements": [` + s + `]}` }
+	printOf := func(expr string) string {
+		return node(`{"kind": "PrintStatement", "line": 1, "column": 1, "expr": ` + expr + `}`)
+	}
+	tests := []struct {
+		name string
+		json string
+		code msg.Code
+	}{
+		{"bad version", `{"version": 2, "statements": []}`, msg.JSON_BAD_VERSION},
+		{"missing version", `{"statements": []}`, msg.JSON_BAD_VERSION},
+		{"unknown statement", node(`{"kind": "GotoStatement", "line": 1, "column": 1}`), msg.JSON_UNKNOWN_STATEMENT},
+		{"missing statement", node(`null`), msg.JSON_MISSING_STATEMENT},
+		{"unknown expr", printOf(`{"kind": "CallExpr", "line": 1, "column": 7}`), msg.JSON_UNKNOWN_EXPR},
+		{"missing expr", printOf(`null`), msg.JSON_MISSING_EXPR},
+		{"unknown type", node(`{"kind": "VarDeclaration", "line": 1, "column": 1, "name": "x", "type": "float"}`), msg.JSON_UNKNOWN_TYPE},
+		{"number value", printOf(`{"kind": "NumberExpr", "line": 1, "column": 7, "value": 1}`), msg.JSON_NUMBER_VALUE},
+		{"boolean value", printOf(`{"kind": "BooleanExpr", "line": 1, "column": 7, "value": "true"}`), msg.JSON_BOOLEAN_VALUE},
+		{"char value too long", printOf(`{"kind": "CharExpr", "line": 1, "column": 7, "value": "ab"}`), msg.JSON_CHAR_VALUE},
+		{"char value empty", printOf(`{"kind": "CharExpr", "line": 1, "column": 7, "value": ""}`), msg.JSON_CHAR_VALUE},
+		{"char value not ascii", printOf(`{"kind": "CharExpr", "line": 1, "column": 7, "value": "é"}`), msg.JSON_CHAR_VALUE},
+	}
+	for _, tt := range tests {
+		_, err := UnmarshalJSON([]byte(tt.json))
+		var e *msg.Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s：错误 %v，期望 %s", tt.name, err, tt.code)
+		} else if e.Code != tt.code {
+			t.Errorf("%s：错误编号 %s，期望 %s", tt.name, e.Code, tt.code)
+		}
+	}
+
+	if _, err := UnmarshalJSON([]byte(`{"version": 1, "statements": [`)); err == nil {
+		t.Errorf("不完整的 JSON 没有报告错误")
+	}
+}
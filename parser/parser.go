@@ -2,12 +2,18 @@ package parser
 
 import (
 	"compiler/lexer"
-	"fmt"
+	"compiler/msg"
+	"strconv"
 )
 
 type Parser struct {
 	lex       *lexer.Lexer
 	lookahead lexer.Token
+	defined   map[string]bool  // 语义分析前已定义的变量
+	types     map[string]Type  // 语义分析前已定义的变量和常量的类型，没有记录的为 int
+	consts    map[string]int16 // 语义分析前已声明的常量
+	depth     int              // 当前语句所在块的嵌套层数，0 为文件顶层
+	file      int              // 正在解析的源文件的下标，见 Pos.File
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
@@ -16,11 +22,76 @@ func NewParser(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Define 声明在源代码之外已经定义的变量（如 REPL 中之前输入的变量），
+// 语义分析时视为已定义
+func (p *Parser) Define(names ...string) {
+	if p.defined == nil {
+		p.defined = make(map[string]bool)
+	}
+	for _, name := range names {
+		p.defined[name] = true
+	}
+}
+
+// SetFile 设置正在解析的源文件的下标，记录在之后生成的节点位置中（见 Pos.File）
+func (p *Parser) SetFile(index int) {
+	p.file = index
+}
+
+// DefineVar 声明在源代码之外已经定义的 t 类型的变量
+func (p *Parser) DefineVar(name string, t Type) {
+	p.Define(name)
+	if p.types == nil {
+		p.types = make(map[string]Type)
+	}
+	p.types[name] = t
+}
+
+// DefineConst 声明在源代码之外已经声明的常量（如 REPL 中之前输入的常量）
+func (p *Parser) DefineConst(name string, t Type, value int16) {
+	if p.consts == nil {
+		p.consts = make(map[string]int16)
+	}
+	if p.types == nil {
+		p.types = make(map[string]Type)
+	}
+	p.consts[name] = value
+	p.types[name] = t
+}
+
+// newChecker 创建语义检查器，在源代码之外已经定义的变量和常量视为已定义
+func (p *Parser) newChecker() *Checker {
+	c := NewChecker(nil)
+	for name := range p.defined {
+		c.DefineVar(name, p.types[name])
+	}
+	for name, value := range p.consts {
+		c.DefineConst(name, p.types[name], value)
+	}
+	return c
+}
+
 func (p *Parser) nextToken() {
 	p.lookahead = p.lex.NextToken()
 }
 
 func (p *Parser) Parse() (*AST, error) {
+	ast, err := p.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+	// 语义分析：检查所有变量使用是否已定义，以及类型是否匹配
+	c := p.newChecker()
+	for _, stmt := range ast.Statements {
+		if err := c.CheckStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
+}
+
+// ParseProgram 只做语法分析，不检查变量是否已定义（供格式化等工具使用）
+func (p *Parser) ParseProgram() (*AST, error) {
 	ast := &AST{}
 	for p.lookahead.Type != lexer.TOKEN_EOF {
 		stmt, err := p.parseStatement()
@@ -29,16 +100,30 @@ func (p *Parser) Parse() (*AST, error) {
 		}
 		ast.Statements = append(ast.Statements, stmt)
 	}
-	// 语义分析：检查所有变量使用是否已定义
-	if err := semanticCheck(ast); err != nil {
+	return ast, nil
+}
+
+// ParseExpr 把整个输入解析为单个表达式（允许末尾带分号），并检查变量是否已定义以及类型是否匹配
+func (p *Parser) ParseExpr() (Expr, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
 		return nil, err
 	}
-	return ast, nil
+	if p.lookahead.Type == lexer.TOKEN_SEMICOLON {
+		p.nextToken()
+	}
+	if p.lookahead.Type != lexer.TOKEN_EOF {
+		return nil, p.newError(msg.PARSE_TRAILING_INPUT)
+	}
+	if _, err := p.newChecker().exprType(expr); err != nil {
+		return nil, err
+	}
+	return expr, nil
 }
 
 func (p *Parser) parseStatement() (Statement, error) {
 	switch p.lookahead.Type {
-	case lexer.TOKEN_IDENT:
+	case lexer.TOKEN_IDENT, lexer.TOKEN_INCREMENT, lexer.TOKEN_DECREMENT:
 		return p.parseAssignment()
 	case lexer.TOKEN_KEYWORD:
 		switch p.lookahead.Literal {
@@ -50,31 +135,166 @@ func (p *Parser) parseStatement() (Statement, error) {
 			return p.parseIf()
 		case "while":
 			return p.parseWhile()
+		case "do":
+			return p.parseDoWhile()
+		case "for":
+			return p.parseFor()
+		case "switch":
+			return p.parseSwitch()
+		case "break":
+			return p.parseBreak()
+		case "continue":
+			return p.parseContinue()
+		case "import":
+			return p.parseImport()
+		case "const":
+			return p.parseConst()
+		case "int", "bool", "char", "long":
+			return p.parseDeclaration()
 		}
 	}
-	return nil, p.newError("未知语句")
+	return nil, p.newError(msg.PARSE_UNKNOWN_STATEMENT)
 }
 
-func (p *Parser) parseAssignment() (Statement, error) {
-	ident := p.lookahead.Literal
+func (p *Parser) parseImport() (Statement, error) {
+	pos := p.pos()
+	if p.depth > 0 {
+		return nil, p.newError(msg.PARSE_IMPORT_NOT_TOP_LEVEL)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_STRING {
+		return nil, p.newError(msg.PARSE_IMPORT_NEEDS_PATH)
+	}
+	path := p.lookahead.Literal
+	if path == "" {
+		return nil, p.newError(msg.PARSE_IMPORT_EMPTY_PATH)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_IMPORT_SEMICOLON)
+	}
+	p.nextToken()
+	return &ImportStatement{Pos: pos, Path: path}, nil
+}
+
+// parseConst 解析常量声明 const N = 表达式;
+func (p *Parser) parseConst() (Statement, error) {
+	pos := p.pos()
+	if p.depth > 0 {
+		return nil, p.newError(msg.PARSE_CONST_NOT_TOP_LEVEL)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_IDENT {
+		return nil, p.newError(msg.PARSE_CONST_NAME)
+	}
+	name := p.lookahead.Literal
 	p.nextToken()
 	if p.lookahead.Type != lexer.TOKEN_ASSIGN {
-		return nil, p.newError("赋值语句缺少 '='")
+		return nil, p.newError(msg.PARSE_CONST_EQUAL)
 	}
 	p.nextToken()
-	expr, err := p.parseExpr()
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_CONST_SEMICOLON)
+	}
+	p.nextToken()
+	return &ConstDeclaration{Pos: pos, Name: name, Value: value}, nil
+}
+
+// parseDeclaration 解析变量声明 int x = 表达式; 或不带初始值的 int x;
+func (p *Parser) parseDeclaration() (Statement, error) {
+	pos := p.pos()
+	typeName := p.lookahead.Literal
+	t, _ := LookupType(typeName)
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_IDENT {
+		return nil, p.newError(msg.PARSE_DECL_NAME, typeName)
+	}
+	name := p.lookahead.Literal
+	p.nextToken()
+	var value Expr
+	if p.lookahead.Type == lexer.TOKEN_ASSIGN {
+		p.nextToken()
+		var err error
+		if value, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_DECL_SEMICOLON)
+	}
+	p.nextToken()
+	return &VarDeclaration{Pos: pos, Type: t, Name: name, Value: value}, nil
+}
+
+func (p *Parser) parseAssignment() (Statement, error) {
+	stmt, err := p.parseSimpleStatement()
 	if err != nil {
 		return nil, err
 	}
 	// fmt.Printf("DEBUG: In parseAssignment, before semicolon check. Lookahead: Type=%s, Literal=\"%s\", Line=%d, Column=%d\n", p.lookahead.Type, p.lookahead.Literal, p.lookahead.Line, p.lookahead.Column)
 	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
-		return nil, p.newError("赋值语句缺少分号")
+		return nil, p.newError(msg.PARSE_ASSIGN_SEMICOLON)
+	}
+	p.nextToken()
+	return stmt, nil
+}
+
+// compoundOps 是复合赋值运算符对应的二元运算符
+var compoundOps = map[lexer.TokenType]string{
+	lexer.TOKEN_PLUS_ASSIGN:     "+",
+	lexer.TOKEN_MINUS_ASSIGN:    "-",
+	lexer.TOKEN_MULTIPLY_ASSIGN: "*",
+	lexer.TOKEN_DIVIDE_ASSIGN:   "/",
+	lexer.TOKEN_MODULO_ASSIGN:   "%",
+}
+
+// parseSimpleStatement 解析不带分号的赋值、复合赋值或自增自减语句，
+// 用于普通赋值以及 for 的初始化和步进部分
+func (p *Parser) parseSimpleStatement() (Statement, error) {
+	pos := p.pos()
+	if p.lookahead.Type == lexer.TOKEN_INCREMENT || p.lookahead.Type == lexer.TOKEN_DECREMENT {
+		op := p.lookahead.Literal
+		p.nextToken()
+		if p.lookahead.Type != lexer.TOKEN_IDENT {
+			return nil, p.newError(msg.PARSE_INCDEC_IDENT, op)
+		}
+		ident := p.lookahead.Literal
+		p.nextToken()
+		return &IncDecStatement{Pos: pos, Ident: ident, Op: op, Prefix: true}, nil
+	}
+	ident := p.lookahead.Literal
+	p.nextToken()
+	switch p.lookahead.Type {
+	case lexer.TOKEN_INCREMENT, lexer.TOKEN_DECREMENT:
+		op := p.lookahead.Literal
+		p.nextToken()
+		return &IncDecStatement{Pos: pos, Ident: ident, Op: op}, nil
+	case lexer.TOKEN_ASSIGN:
+		p.nextToken()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &Assignment{Pos: pos, Ident: ident, Value: expr}, nil
+	}
+	op, ok := compoundOps[p.lookahead.Type]
+	if !ok {
+		return nil, p.newError(msg.PARSE_ASSIGN_MISSING_EQUAL)
 	}
 	p.nextToken()
-	return &Assignment{Ident: ident, Value: expr}, nil
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &CompoundAssignment{Pos: pos, Ident: ident, Op: op, Value: expr}, nil
 }
 
 func (p *Parser) parsePrint() (Statement, error) {
+	pos := p.pos()
 	p.nextToken()
 	expr, err := p.parseExpr()
 	if err != nil {
@@ -82,30 +302,34 @@ func (p *Parser) parsePrint() (Statement, error) {
 	}
 	// fmt.Printf("DEBUG: In parsePrint, before semicolon check. Lookahead: Type=%s, Literal=\"%s\", Line=%d, Column=%d\n", p.lookahead.Type, p.lookahead.Literal, p.lookahead.Line, p.lookahead.Column)
 	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
-		return nil, p.newError("print语句缺少分号")
+		return nil, p.newError(msg.PARSE_PRINT_SEMICOLON)
 	}
 	p.nextToken()
-	return &PrintStatement{Expr: expr}, nil
+	return &PrintStatement{Pos: pos, Expr: expr}, nil
 }
 
 func (p *Parser) parseInput() (Statement, error) {
+	pos := p.pos()
 	p.nextToken()
 	if p.lookahead.Type != lexer.TOKEN_IDENT {
-		return nil, p.newError("input语句需要变量名")
+		return nil, p.newError(msg.PARSE_INPUT_NEEDS_IDENT)
 	}
 	ident := p.lookahead.Literal
 	p.nextToken()
 	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
-		return nil, p.newError("input语句缺少分号")
+		return nil, p.newError(msg.PARSE_INPUT_SEMICOLON)
 	}
 	p.nextToken()
-	return &InputStatement{Ident: ident}, nil
+	return &InputStatement{Pos: pos, Ident: ident}, nil
 }
 
 func (p *Parser) parseIf() (Statement, error) {
+	pos := p.pos()
+	p.depth++
+	defer func() { p.depth-- }()
 	p.nextToken()
 	if p.lookahead.Type != lexer.TOKEN_LPAREN {
-		return nil, p.newError("if语句缺少左括号")
+		return nil, p.newError(msg.PARSE_IF_LPAREN)
 	}
 	p.nextToken()
 	condition, err := p.parseExpr()
@@ -113,19 +337,19 @@ func (p *Parser) parseIf() (Statement, error) {
 		return nil, err
 	}
 	if p.lookahead.Type != lexer.TOKEN_RPAREN {
-		return nil, p.newError("if语句缺少右括号")
+		return nil, p.newError(msg.PARSE_IF_RPAREN)
 	}
 	p.nextToken()
 	
 	if p.lookahead.Type != lexer.TOKEN_LBRACE {
-		return nil, p.newError("if语句缺少左花括号")
+		return nil, p.newError(msg.PARSE_IF_LBRACE)
 	}
 	p.nextToken()
 	
 	thenStmts := []Statement{}
 	for p.lookahead.Type != lexer.TOKEN_RBRACE {
 		if p.lookahead.Type == lexer.TOKEN_EOF {
-			return nil, p.newError("if语句缺少右花括号")
+			return nil, p.newError(msg.PARSE_IF_RBRACE)
 		}
 		stmt, err := p.parseStatement()
 		if err != nil {
@@ -133,19 +357,38 @@ func (p *Parser) parseIf() (Statement, error) {
 		}
 		thenStmts = append(thenStmts, stmt)
 	}
+	thenEnd := p.pos()
 	p.nextToken()
 	
 	elseStmts := []Statement{}
+	var elseEnd Pos
 	if p.lookahead.Type == lexer.TOKEN_KEYWORD && p.lookahead.Literal == "else" {
 		p.nextToken()
+		if p.lookahead.Type == lexer.TOKEN_KEYWORD && p.lookahead.Literal == "if" {
+			// else if 展开为只含一条 if 语句的 else 分支
+			stmt, err := p.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			nested := stmt.(*IfStatement)
+			return &IfStatement{
+				Pos:       pos,
+				Condition: condition,
+				Then:      thenStmts,
+				Else:      []Statement{nested},
+				ThenEnd:   thenEnd,
+				ElseEnd:   nested.end(),
+				ElseIf:    true,
+			}, nil
+		}
 		if p.lookahead.Type != lexer.TOKEN_LBRACE {
-			return nil, p.newError("else语句缺少左花括号")
+			return nil, p.newError(msg.PARSE_ELSE_LBRACE)
 		}
 		p.nextToken()
 		
 		for p.lookahead.Type != lexer.TOKEN_RBRACE {
 			if p.lookahead.Type == lexer.TOKEN_EOF {
-				return nil, p.newError("else语句缺少右花括号")
+				return nil, p.newError(msg.PARSE_ELSE_RBRACE)
 			}
 			stmt, err := p.parseStatement()
 			if err != nil {
@@ -153,20 +396,27 @@ func (p *Parser) parseIf() (Statement, error) {
 			}
 			elseStmts = append(elseStmts, stmt)
 		}
+		elseEnd = p.pos()
 		p.nextToken()
 	}
 	
 	return &IfStatement{
+		Pos:       pos,
 		Condition: condition,
 		Then:      thenStmts,
 		Else:      elseStmts,
+		ThenEnd:   thenEnd,
+		ElseEnd:   elseEnd,
 	}, nil
 }
 
 func (p *Parser) parseWhile() (Statement, error) {
+	pos := p.pos()
+	p.depth++
+	defer func() { p.depth-- }()
 	p.nextToken()
 	if p.lookahead.Type != lexer.TOKEN_LPAREN {
-		return nil, p.newError("while语句缺少左括号")
+		return nil, p.newError(msg.PARSE_WHILE_LPAREN)
 	}
 	p.nextToken()
 	condition, err := p.parseExpr()
@@ -174,19 +424,19 @@ func (p *Parser) parseWhile() (Statement, error) {
 		return nil, err
 	}
 	if p.lookahead.Type != lexer.TOKEN_RPAREN {
-		return nil, p.newError("while语句缺少右括号")
+		return nil, p.newError(msg.PARSE_WHILE_RPAREN)
 	}
 	p.nextToken()
 	
 	if p.lookahead.Type != lexer.TOKEN_LBRACE {
-		return nil, p.newError("while语句缺少左花括号")
+		return nil, p.newError(msg.PARSE_WHILE_LBRACE)
 	}
 	p.nextToken()
 	
 	body := []Statement{}
 	for p.lookahead.Type != lexer.TOKEN_RBRACE {
 		if p.lookahead.Type == lexer.TOKEN_EOF {
-			return nil, p.newError("while语句缺少右花括号")
+			return nil, p.newError(msg.PARSE_WHILE_RBRACE)
 		}
 		stmt, err := p.parseStatement()
 		if err != nil {
@@ -194,80 +444,382 @@ func (p *Parser) parseWhile() (Statement, error) {
 		}
 		body = append(body, stmt)
 	}
+	end := p.pos()
 	p.nextToken()
 	
 	return &WhileStatement{
+		Pos:       pos,
 		Condition: condition,
 		Body:      body,
+		End:       end,
 	}, nil
 }
 
-func (p *Parser) parseExpr() (Expr, error) {
-	left, err := p.parseTerm()
+func (p *Parser) parseDoWhile() (Statement, error) {
+	pos := p.pos()
+	p.depth++
+	defer func() { p.depth-- }()
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_LBRACE {
+		return nil, p.newError(msg.PARSE_DO_LBRACE)
+	}
+	p.nextToken()
+
+	body := []Statement{}
+	for p.lookahead.Type != lexer.TOKEN_RBRACE {
+		if p.lookahead.Type == lexer.TOKEN_EOF {
+			return nil, p.newError(msg.PARSE_DO_RBRACE)
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, stmt)
+	}
+	end := p.pos()
+	p.nextToken()
+
+	if p.lookahead.Type != lexer.TOKEN_KEYWORD || p.lookahead.Literal != "while" {
+		return nil, p.newError(msg.PARSE_DO_WHILE)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_LPAREN {
+		return nil, p.newError(msg.PARSE_DO_LPAREN)
+	}
+	p.nextToken()
+	condition, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
-	
-	for p.lookahead.Type == lexer.TOKEN_PLUS || p.lookahead.Type == lexer.TOKEN_MINUS {
-		op := p.lookahead.Literal
+	if p.lookahead.Type != lexer.TOKEN_RPAREN {
+		return nil, p.newError(msg.PARSE_DO_RPAREN)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_DO_SEMICOLON)
+	}
+	p.nextToken()
+
+	return &DoWhileStatement{
+		Pos:       pos,
+		Body:      body,
+		Condition: condition,
+		End:       end,
+	}, nil
+}
+
+func (p *Parser) parseFor() (Statement, error) {
+	pos := p.pos()
+	p.depth++
+	defer func() { p.depth-- }()
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_LPAREN {
+		return nil, p.newError(msg.PARSE_FOR_LPAREN)
+	}
+	p.nextToken()
+
+	var init, step Statement
+	var condition Expr
+	var err error
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		if init, err = p.parseForClause(); err != nil {
+			return nil, err
+		}
+	}
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_FOR_SEMICOLON)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		if condition, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_FOR_SEMICOLON)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_RPAREN {
+		if step, err = p.parseForClause(); err != nil {
+			return nil, err
+		}
+	}
+	if p.lookahead.Type != lexer.TOKEN_RPAREN {
+		return nil, p.newError(msg.PARSE_FOR_RPAREN)
+	}
+	p.nextToken()
+
+	if p.lookahead.Type != lexer.TOKEN_LBRACE {
+		return nil, p.newError(msg.PARSE_FOR_LBRACE)
+	}
+	p.nextToken()
+
+	body := []Statement{}
+	for p.lookahead.Type != lexer.TOKEN_RBRACE {
+		if p.lookahead.Type == lexer.TOKEN_EOF {
+			return nil, p.newError(msg.PARSE_FOR_RBRACE)
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, stmt)
+	}
+	end := p.pos()
+	p.nextToken()
+
+	return &ForStatement{
+		Pos:       pos,
+		Init:      init,
+		Condition: condition,
+		Step:      step,
+		Body:      body,
+		End:       end,
+	}, nil
+}
+
+func (p *Parser) parseSwitch() (Statement, error) {
+	pos := p.pos()
+	p.depth++
+	defer func() { p.depth-- }()
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_LPAREN {
+		return nil, p.newError(msg.PARSE_SWITCH_LPAREN)
+	}
+	p.nextToken()
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.lookahead.Type != lexer.TOKEN_RPAREN {
+		return nil, p.newError(msg.PARSE_SWITCH_RPAREN)
+	}
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_LBRACE {
+		return nil, p.newError(msg.PARSE_SWITCH_LBRACE)
+	}
+	p.nextToken()
+
+	var cases []*CaseClause
+	for p.lookahead.Type != lexer.TOKEN_RBRACE {
+		if p.lookahead.Type == lexer.TOKEN_EOF {
+			return nil, p.newError(msg.PARSE_SWITCH_RBRACE)
+		}
+		c, err := p.parseCaseClause()
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	end := p.pos()
+	p.nextToken()
+
+	return &SwitchStatement{
+		Pos:   pos,
+		Value: value,
+		Cases: cases,
+		End:   end,
+	}, nil
+}
+
+// parseCaseClause 解析 "case 1, N + 1:" 或 "default:" 以及其后直到下一个分支的语句。
+// case 的值是否为常量由语义检查负责
+func (p *Parser) parseCaseClause() (*CaseClause, error) {
+	c := &CaseClause{Pos: p.pos()}
+	if p.lookahead.Type != lexer.TOKEN_KEYWORD || (p.lookahead.Literal != "case" && p.lookahead.Literal != "default") {
+		return nil, p.newError(msg.PARSE_SWITCH_CASE)
+	}
+	isCase := p.lookahead.Literal == "case"
+	p.nextToken()
+	for isCase {
+		if p.lookahead.Type == lexer.TOKEN_COLON || p.lookahead.Type == lexer.TOKEN_COMMA {
+			return nil, p.newError(msg.PARSE_CASE_VALUE)
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.Values = append(c.Values, value)
+		if p.lookahead.Type != lexer.TOKEN_COMMA {
+			break
+		}
 		p.nextToken()
-		right, err := p.parseTerm()
+	}
+	if p.lookahead.Type != lexer.TOKEN_COLON {
+		return nil, p.newError(msg.PARSE_CASE_COLON)
+	}
+	p.nextToken()
+
+	for p.lookahead.Type != lexer.TOKEN_RBRACE && !p.atCaseLabel() {
+		if p.lookahead.Type == lexer.TOKEN_EOF {
+			return nil, p.newError(msg.PARSE_SWITCH_RBRACE)
+		}
+		stmt, err := p.parseStatement()
 		if err != nil {
 			return nil, err
 		}
-		left = &BinaryExpr{Op: op, Left: left, Right: right}
+		c.Body = append(c.Body, stmt)
 	}
-	
+	return c, nil
+}
+
+// atCaseLabel 报告下一个词法单元是否开始一个新的 case 或 default 分支
+func (p *Parser) atCaseLabel() bool {
+	return p.lookahead.Type == lexer.TOKEN_KEYWORD && (p.lookahead.Literal == "case" || p.lookahead.Literal == "default")
+}
+
+// parseForClause 解析 for 的初始化或步进部分
+func (p *Parser) parseForClause() (Statement, error) {
+	switch p.lookahead.Type {
+	case lexer.TOKEN_IDENT, lexer.TOKEN_INCREMENT, lexer.TOKEN_DECREMENT:
+		return p.parseSimpleStatement()
+	}
+	return nil, p.newError(msg.PARSE_FOR_CLAUSE)
+}
+
+func (p *Parser) parseBreak() (Statement, error) {
+	pos := p.pos()
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_BREAK_SEMICOLON)
+	}
+	p.nextToken()
+	return &BreakStatement{Pos: pos}, nil
+}
+
+func (p *Parser) parseContinue() (Statement, error) {
+	pos := p.pos()
+	p.nextToken()
+	if p.lookahead.Type != lexer.TOKEN_SEMICOLON {
+		return nil, p.newError(msg.PARSE_CONTINUE_SEMICOLON)
+	}
+	p.nextToken()
+	return &ContinueStatement{Pos: pos}, nil
+}
+
+// 表达式的优先级从低到高：
+//
+//	比较    == != < > <= >=（不能连用）
+//	按位或  |
+//	按位异或 ^
+//	按位与  &
+//	移位    << >>
+//	加减    + -
+//	乘除    * / %
+//	一元    ~
+//
+// 位运算的优先级高于比较运算，x & 1 == 0 即 (x & 1) == 0
+func (p *Parser) parseExpr() (Expr, error) {
+	left, err := p.parseBitOr()
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查比较运算符
 	if p.lookahead.Type == lexer.TOKEN_LESS || p.lookahead.Type == lexer.TOKEN_GREATER ||
 	   p.lookahead.Type == lexer.TOKEN_EQUAL || p.lookahead.Type == lexer.TOKEN_NOT_EQUAL ||
 	   p.lookahead.Type == lexer.TOKEN_LESS_EQUAL || p.lookahead.Type == lexer.TOKEN_GREATER_EQUAL {
+		pos := p.pos()
 		op := p.lookahead.Literal
 		p.nextToken()
-		right, err := p.parseTerm()
+		right, err := p.parseBitOr()
 		if err != nil {
 			return nil, err
 		}
-		return &ComparisonExpr{Op: op, Left: left, Right: right}, nil
+		return &ComparisonExpr{Pos: pos, Op: op, Left: left, Right: right}, nil
 	}
-	
+
 	return left, nil
 }
 
+func (p *Parser) parseBitOr() (Expr, error) {
+	return p.parseBinary(p.parseBitXor, lexer.TOKEN_BIT_OR)
+}
+
+func (p *Parser) parseBitXor() (Expr, error) {
+	return p.parseBinary(p.parseBitAnd, lexer.TOKEN_BIT_XOR)
+}
+
+func (p *Parser) parseBitAnd() (Expr, error) {
+	return p.parseBinary(p.parseShift, lexer.TOKEN_BIT_AND)
+}
+
+func (p *Parser) parseShift() (Expr, error) {
+	return p.parseBinary(p.parseSum, lexer.TOKEN_SHIFT_LEFT, lexer.TOKEN_SHIFT_RIGHT)
+}
+
+func (p *Parser) parseSum() (Expr, error) {
+	return p.parseBinary(p.parseTerm, lexer.TOKEN_PLUS, lexer.TOKEN_MINUS)
+}
+
 func (p *Parser) parseTerm() (Expr, error) {
-	left, err := p.parseFactor()
+	return p.parseBinary(p.parseUnary, lexer.TOKEN_MULTIPLY, lexer.TOKEN_DIVIDE, lexer.TOKEN_MODULO)
+}
+
+// parseBinary 解析由 ops 中的运算符连接的左结合表达式，操作数由 operand 解析
+func (p *Parser) parseBinary(operand func() (Expr, error), ops ...lexer.TokenType) (Expr, error) {
+	left, err := operand()
 	if err != nil {
 		return nil, err
 	}
-	for p.lookahead.Type == lexer.TOKEN_MULTIPLY || p.lookahead.Type == lexer.TOKEN_DIVIDE {
+	for p.atOperator(ops) {
+		pos := p.pos()
 		op := p.lookahead.Literal
 		p.nextToken()
-		right, err := p.parseFactor()
+		right, err := operand()
 		if err != nil {
 			return nil, err
 		}
-		left = &BinaryExpr{Op: op, Left: left, Right: right}
+		left = &BinaryExpr{Pos: pos, Op: op, Left: left, Right: right}
 	}
 	return left, nil
 }
 
+func (p *Parser) atOperator(ops []lexer.TokenType) bool {
+	for _, op := range ops {
+		if p.lookahead.Type == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) parseUnary() (Expr, error) {
+	if p.lookahead.Type == lexer.TOKEN_BIT_NOT {
+		pos := p.pos()
+		p.nextToken()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Pos: pos, Op: "~", Operand: operand}, nil
+	}
+	return p.parseFactor()
+}
+
 func (p *Parser) parseFactor() (Expr, error) {
+	pos := p.pos()
 	switch p.lookahead.Type {
 	case lexer.TOKEN_IDENT:
 		ident := p.lookahead.Literal
 		p.nextToken()
-		return &IdentExpr{Name: ident}, nil
+		return &IdentExpr{Pos: pos, Name: ident}, nil
 	case lexer.TOKEN_NUMBER:
 		val := p.lookahead.Literal
 		p.nextToken()
-		return &NumberExpr{Value: val}, nil
+		return &NumberExpr{Pos: pos, Value: val}, nil
+	case lexer.TOKEN_CHAR:
+		// 词法分析已经检查过字符常量
+		c, _ := lexer.UnquoteChar(p.lookahead.Literal)
+		p.nextToken()
+		return &CharExpr{Pos: pos, Value: c}, nil
 	case lexer.TOKEN_KEYWORD:
 		if p.lookahead.Literal == "true" || p.lookahead.Literal == "false" {
 			val := p.lookahead.Literal == "true"
 			p.nextToken()
-			return &BooleanExpr{Value: val}, nil
+			return &BooleanExpr{Pos: pos, Value: val}, nil
 		}
-		return nil, p.newError("非法的关键字")
+		return nil, p.newError(msg.PARSE_ILLEGAL_KEYWORD)
 	case lexer.TOKEN_LPAREN:
 		p.nextToken()
 		expr, err := p.parseExpr()
@@ -275,102 +827,445 @@ func (p *Parser) parseFactor() (Expr, error) {
 			return nil, err
 		}
 		if p.lookahead.Type != lexer.TOKEN_RPAREN {
-			return nil, p.newError("缺少右括号")
+			return nil, p.newError(msg.PARSE_MISSING_RPAREN)
 		}
 		p.nextToken()
 		return expr, nil
 	default:
-		return nil, p.newError("非法表达式")
+		return nil, p.newError(msg.PARSE_ILLEGAL_EXPR)
+	}
+}
+
+// ParseError 是带位置的语法错误，消息由 Code 和 Args 在输出时按语言生成
+type ParseError struct {
+	Code   msg.Code
+	Args   []interface{}
+	Line   int
+	Column int
+}
+
+func (e *ParseError) Error() string {
+	return msg.Diagnostic(msg.DIAG_SYNTAX, e.Code, "", e.Line, e.Column, msg.Get(e.Code, e.Args...))
+}
+
+// SemanticError 是带位置的语义错误，消息由 Code 和 Args 在输出时按语言生成
+type SemanticError struct {
+	Code   msg.Code
+	Args   []interface{}
+	Line   int
+	Column int
+}
+
+func (e *SemanticError) Error() string {
+	return msg.Diagnostic(msg.DIAG_SEMANTIC, e.Code, "", e.Line, e.Column, msg.Get(e.Code, e.Args...))
+}
+
+func (p *Parser) newError(code msg.Code, args ...interface{}) error {
+	return &ParseError{Code: code, Args: args, Line: p.lookahead.Line, Column: p.lookahead.Column}
+}
+
+func (p *Parser) pos() Pos {
+	return Pos{Line: p.lookahead.Line, Column: p.lookahead.Column, File: p.file}
+}
+
+func newSemanticError(pos Pos, code msg.Code, args ...interface{}) error {
+	return &SemanticError{Code: code, Args: args, Line: pos.Line, Column: pos.Column}
+}
+
+
+// Checker 按执行顺序逐条检查顶层语句，多次调用之间保留已定义的变量及其类型。
+// 多文件程序用它依次检查来自不同文件的语句，以便把错误归到对应的文件。
+type Checker struct {
+	defined  map[string]bool
+	types    map[string]Type  // 已定义的变量和常量的类型
+	consts   map[string]int16 // 已声明的常量及其值，常量也记录在 defined 中
+	loops    int              // 当前所在循环的嵌套层数，用于检查 break 和 continue
+	switches int              // 当前所在 switch 的嵌套层数，switch 中也可以使用 break
+}
+
+// NewChecker 创建语义检查器，predefined 中的变量视为已定义的 int 变量
+func NewChecker(predefined map[string]bool) *Checker {
+	c := &Checker{defined: make(map[string]bool), types: make(map[string]Type), consts: make(map[string]int16)}
+	for name := range predefined {
+		c.DefineVar(name, TYPE_INT)
 	}
+	return c
+}
+
+// DefineVar 把 name 视为已定义的 t 类型的变量
+func (c *Checker) DefineVar(name string, t Type) {
+	c.defined[name] = true
+	c.types[name] = t
+}
+
+// DefineConst 把 name 视为已声明的 t 类型的常量
+func (c *Checker) DefineConst(name string, t Type, value int16) {
+	c.DefineVar(name, t)
+	c.consts[name] = value
 }
 
-func (p *Parser) newError(msg string) error {
-	return fmt.Errorf("语法错误：第%d行第%d列: %s", p.lookahead.Line, p.lookahead.Column, msg)
+// Types 返回已定义的变量和常量的类型，调用者不能修改返回的 map
+func (c *Checker) Types() map[string]Type {
+	return c.types
 }
 
-// 语义分析：检查所有变量使用是否已定义
-func semanticCheck(ast *AST) error {
-	defined := make(map[string]bool)
+// CheckStatement 检查一条顶层语句
+func (c *Checker) CheckStatement(stmt Statement) error {
+	return c.check(stmt)
+}
+
+// Infer 依次检查程序中的语句并返回所有变量和常量的类型，供代码生成使用。
+// 程序应已通过语义检查；优化删除语句后个别语句可能检查出错，出错的语句不影响其他语句。
+func (c *Checker) Infer(ast *AST) map[string]Type {
 	for _, stmt := range ast.Statements {
-		switch s := stmt.(type) {
-		case *Assignment:
-			// 先检查右侧表达式
-			if err := checkExprDefined(s.Value, defined); err != nil {
+		c.check(stmt)
+	}
+	return c.types
+}
+
+// VarTypes 返回已通过语义检查的程序中所有变量和常量的类型
+func VarTypes(ast *AST) map[string]Type {
+	return NewChecker(nil).Infer(ast)
+}
+
+// TypeOf 返回已通过语义检查的表达式的类型，types 是变量和常量的类型
+func TypeOf(expr Expr, types map[string]Type) Type {
+	switch e := expr.(type) {
+	case *NumberExpr:
+		return numberType(e.Value)
+	case *BooleanExpr, *ComparisonExpr:
+		return TYPE_BOOL
+	case *CharExpr:
+		return TYPE_CHAR
+	case *IdentExpr:
+		return types[e.Name]
+	case *BinaryExpr:
+		return widen(TypeOf(e.Left, types), TypeOf(e.Right, types))
+	case *UnaryExpr:
+		return TypeOf(e.Operand, types)
+	}
+	return TYPE_INT
+}
+
+// numberType 返回数字的类型：16 位以内的是 int，更大的是 long
+func numberType(value string) Type {
+	if _, err := strconv.ParseInt(value, 10, 16); err != nil {
+		return TYPE_LONG
+	}
+	return TYPE_INT
+}
+
+// widen 返回两个整数操作数运算结果的类型，有一个是 long 时结果是 long
+func widen(l, r Type) Type {
+	if l == TYPE_LONG || r == TYPE_LONG {
+		return TYPE_LONG
+	}
+	return TYPE_INT
+}
+
+func (c *Checker) check(stmt Statement) error {
+	defined := c.defined
+	switch s := stmt.(type) {
+	case *ConstDeclaration:
+		if _, ok := c.consts[s.Name]; ok {
+			return newSemanticError(s.Pos, msg.SEM_CONST_REDECLARED, s.Name)
+		}
+		if defined[s.Name] {
+			return newSemanticError(s.Pos, msg.SEM_CONST_IS_VAR, s.Name)
+		}
+		// 先报告未定义的变量和类型错误，再报告已定义但不是常量的变量
+		t, err := c.exprType(s.Value)
+		if err != nil {
+			return err
+		}
+		if t == TYPE_LONG {
+			return newSemanticError(s.Value.Position(), msg.SEM_CONST_LONG, s.Name)
+		}
+		v, err := EvalConst(s.Value, c.consts)
+		if err != nil {
+			return err
+		}
+		c.DefineConst(s.Name, t, v)
+	case *VarDeclaration:
+		if defined[s.Name] {
+			return newSemanticError(s.Pos, msg.SEM_REDECLARED, s.Name)
+		}
+		if s.Value != nil {
+			t, err := c.exprType(s.Value)
+			if err != nil {
 				return err
 			}
-			// 再标记左侧变量为已定义
-			defined[s.Ident] = true
-		case *PrintStatement:
-			if err := checkExprDefined(s.Expr, defined); err != nil {
+			if !t.assignable(s.Type) {
+				return newSemanticError(s.Value.Position(), msg.SEM_TYPE_ASSIGN, t, s.Type, s.Name)
+			}
+		}
+		c.DefineVar(s.Name, s.Type)
+	case *Assignment:
+		if err := c.checkNotConst(s.Pos, s.Ident); err != nil {
+			return err
+		}
+		// 先检查右侧表达式
+		t, err := c.exprType(s.Value)
+		if err != nil {
+			return err
+		}
+		// 已定义的变量类型不变，第一次赋值的变量取右侧表达式的类型
+		if defined[s.Ident] {
+			if !t.assignable(c.types[s.Ident]) {
+				return newSemanticError(s.Value.Position(), msg.SEM_TYPE_ASSIGN, t, c.types[s.Ident], s.Ident)
+			}
+			break
+		}
+		c.DefineVar(s.Ident, t)
+	case *CompoundAssignment:
+		if err := c.checkNotConst(s.Pos, s.Ident); err != nil {
+			return err
+		}
+		// 复合赋值先读取变量，变量必须已经定义
+		if !defined[s.Ident] {
+			return newSemanticError(s.Pos, msg.SEM_UNDEFINED_VAR, s.Ident)
+		}
+		vt := c.types[s.Ident]
+		if !vt.numeric() {
+			return newSemanticError(s.Pos, msg.SEM_TYPE_OPERAND, s.Op+"=", vt)
+		}
+		t, err := c.checkNumeric(s.Value, s.Op+"=")
+		if err != nil {
+			return err
+		}
+		if !t.assignable(vt) {
+			return newSemanticError(s.Value.Position(), msg.SEM_TYPE_ASSIGN, t, vt, s.Ident)
+		}
+	case *IncDecStatement:
+		if err := c.checkNotConst(s.Pos, s.Ident); err != nil {
+			return err
+		}
+		if !defined[s.Ident] {
+			return newSemanticError(s.Pos, msg.SEM_UNDEFINED_VAR, s.Ident)
+		}
+		if t := c.types[s.Ident]; !t.numeric() {
+			return newSemanticError(s.Pos, msg.SEM_TYPE_OPERAND, s.Op, t)
+		}
+	case *PrintStatement:
+		if _, err := c.exprType(s.Expr); err != nil {
+			return err
+		}
+	case *InputStatement:
+		if err := c.checkNotConst(s.Pos, s.Ident); err != nil {
+			return err
+		}
+		if defined[s.Ident] {
+			if t := c.types[s.Ident]; !t.numeric() {
+				return newSemanticError(s.Pos, msg.SEM_TYPE_INPUT, s.Ident, t)
+			}
+			break
+		}
+		c.DefineVar(s.Ident, TYPE_INT)
+	case *IfStatement:
+		if err := c.checkCondition(s.Condition); err != nil {
+			return err
+		}
+		if err := c.checkBlock(s.Then); err != nil {
+			return err
+		}
+		if err := c.checkBlock(s.Else); err != nil {
+			return err
+		}
+	case *WhileStatement:
+		if err := c.checkCondition(s.Condition); err != nil {
+			return err
+		}
+		return c.checkLoopBody(s.Body, nil)
+	case *DoWhileStatement:
+		// 条件在循环体之后计算，可以使用循环体中定义的变量
+		if err := c.checkLoopBody(s.Body, nil); err != nil {
+			return err
+		}
+		return c.checkCondition(s.Condition)
+	case *ForStatement:
+		if s.Init != nil {
+			if err := c.check(s.Init); err != nil {
 				return err
 			}
-		case *InputStatement:
-			defined[s.Ident] = true
-		case *IfStatement:
-			if err := checkExprDefined(s.Condition, defined); err != nil {
+		}
+		if s.Condition != nil {
+			if err := c.checkCondition(s.Condition); err != nil {
 				return err
 			}
-			for _, stmt := range s.Then {
-				if err := checkStatementDefined(stmt, defined); err != nil {
-					return err
-				}
+		}
+		// 步进部分在循环体之后执行，可以使用循环体中定义的变量
+		return c.checkLoopBody(s.Body, s.Step)
+	case *SwitchStatement:
+		return c.checkSwitch(s)
+	case *BreakStatement:
+		if c.loops == 0 && c.switches == 0 {
+			return newSemanticError(s.Pos, msg.SEM_BREAK_OUTSIDE)
+		}
+	case *ContinueStatement:
+		if c.loops == 0 {
+			return newSemanticError(s.Pos, msg.SEM_CONTINUE_OUTSIDE)
+		}
+	case *ImportStatement:
+		// import 由 compiler 包在语义分析之前展开，单独解析一个文件时无法处理
+		return newSemanticError(s.Pos, msg.SEM_IMPORT_UNLINKED, s.Path)
+	}
+	return nil
+}
+
+// checkNotConst 检查被赋值的变量 name 不是常量
+func (c *Checker) checkNotConst(pos Pos, name string) error {
+	if _, ok := c.consts[name]; ok {
+		return newSemanticError(pos, msg.SEM_CONST_ASSIGN, name)
+	}
+	return nil
+}
+
+// checkCondition 检查 if、while 等语句的条件是 bool 类型
+func (c *Checker) checkCondition(cond Expr) error {
+	t, err := c.exprType(cond)
+	if err != nil {
+		return err
+	}
+	if t != TYPE_BOOL {
+		return newSemanticError(cond.Position(), msg.SEM_TYPE_CONDITION, t)
+	}
+	return nil
+}
+
+// checkNumeric 检查运算符 op 的操作数 expr 是 int 或 long 类型，并返回它的类型
+func (c *Checker) checkNumeric(expr Expr, op string) (Type, error) {
+	t, err := c.exprType(expr)
+	if err != nil {
+		return t, err
+	}
+	if !t.numeric() {
+		return t, newSemanticError(expr.Position(), msg.SEM_TYPE_OPERAND, op, t)
+	}
+	return t, nil
+}
+
+func (c *Checker) checkBlock(stmts []Statement) error {
+	for _, stmt := range stmts {
+		if err := c.check(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLoopBody 检查循环体和 for 的步进部分（可为 nil）
+func (c *Checker) checkLoopBody(body []Statement, step Statement) error {
+	c.loops++
+	defer func() { c.loops-- }()
+	if err := c.checkBlock(body); err != nil {
+		return err
+	}
+	if step != nil {
+		return c.check(step)
+	}
+	return nil
+}
+
+// checkSwitch 检查 switch 的值和各个分支。switch 的值必须是 int 或 char 类型，
+// case 的值必须是同类型的常量表达式，case 的值和 default 都不能重复
+func (c *Checker) checkSwitch(s *SwitchStatement) error {
+	t, err := c.exprType(s.Value)
+	if err != nil {
+		return err
+	}
+	if t != TYPE_INT && t != TYPE_CHAR {
+		return newSemanticError(s.Value.Position(), msg.SEM_TYPE_SWITCH, t)
+	}
+	seen := make(map[int16]Pos)
+	var defaultPos *Pos
+	for _, clause := range s.Cases {
+		if len(clause.Values) == 0 {
+			if defaultPos != nil {
+				return newSemanticError(clause.Pos, msg.SEM_DUPLICATE_DEFAULT, defaultPos.Line)
 			}
-			for _, stmt := range s.Else {
-				if err := checkStatementDefined(stmt, defined); err != nil {
-					return err
+			defaultPos = &clause.Pos
+		}
+		for _, v := range clause.Values {
+			if num, ok := v.(*NumberExpr); ok {
+				if _, err := strconv.ParseInt(num.Value, 10, 16); err != nil {
+					return newSemanticError(num.Pos, msg.SEM_CASE_RANGE, num.Value)
 				}
 			}
-		case *WhileStatement:
-			if err := checkExprDefined(s.Condition, defined); err != nil {
+			vt, err := c.exprType(v)
+			if err != nil {
 				return err
 			}
-			for _, stmt := range s.Body {
-				if err := checkStatementDefined(stmt, defined); err != nil {
-					return err
-				}
+			if vt != t {
+				return newSemanticError(v.Position(), msg.SEM_TYPE_CASE, t, vt)
 			}
+			n, err := EvalConst(v, c.consts)
+			if err != nil {
+				return err
+			}
+			if prev, ok := seen[n]; ok {
+				return newSemanticError(v.Position(), msg.SEM_DUPLICATE_CASE, n, prev.Line)
+			}
+			seen[n] = v.Position()
+		}
+	}
+
+	c.switches++
+	defer func() { c.switches-- }()
+	for _, clause := range s.Cases {
+		if err := c.checkBlock(clause.Body); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func checkExprDefined(expr Expr, defined map[string]bool) error {
+// exprType 检查表达式中的变量都已定义、运算符的操作数类型正确，并返回表达式的类型。
+// 算术和位运算只能用于 int 和 long，有一个操作数是 long 时结果是 long；
+// == 和 != 要求两侧类型相同（int 和 long 可以互相比较）；比较大小不能用于 bool
+func (c *Checker) exprType(expr Expr) (Type, error) {
 	switch e := expr.(type) {
+	case *NumberExpr:
+		if _, err := strconv.ParseInt(e.Value, 10, 32); err != nil {
+			return TYPE_LONG, newSemanticError(e.Pos, msg.SEM_LONG_RANGE, e.Value)
+		}
+		return numberType(e.Value), nil
+	case *BooleanExpr:
+		return TYPE_BOOL, nil
+	case *CharExpr:
+		return TYPE_CHAR, nil
 	case *IdentExpr:
-		if !defined[e.Name] {
-			return fmt.Errorf("语义错误：变量 '%s' 未定义", e.Name)
+		if !c.defined[e.Name] {
+			return TYPE_INT, newSemanticError(e.Pos, msg.SEM_UNDEFINED_VAR, e.Name)
 		}
+		return c.types[e.Name], nil
 	case *BinaryExpr:
-		if err := checkExprDefined(e.Left, defined); err != nil {
-			return err
+		l, err := c.checkNumeric(e.Left, e.Op)
+		if err != nil {
+			return TYPE_INT, err
 		}
-		if err := checkExprDefined(e.Right, defined); err != nil {
-			return err
+		r, err := c.checkNumeric(e.Right, e.Op)
+		if err != nil {
+			return TYPE_INT, err
 		}
+		return widen(l, r), nil
 	case *ComparisonExpr:
-		if err := checkExprDefined(e.Left, defined); err != nil {
-			return err
+		l, err := c.exprType(e.Left)
+		if err != nil {
+			return TYPE_BOOL, err
 		}
-		if err := checkExprDefined(e.Right, defined); err != nil {
-			return err
+		r, err := c.exprType(e.Right)
+		if err != nil {
+			return TYPE_BOOL, err
 		}
-	}
-	return nil
-}
-
-func checkStatementDefined(stmt Statement, defined map[string]bool) error {
-	switch s := stmt.(type) {
-	case *Assignment:
-		if err := checkExprDefined(s.Value, defined); err != nil {
-			return err
+		if l != r && !(l.numeric() && r.numeric()) {
+			return TYPE_BOOL, newSemanticError(e.Pos, msg.SEM_TYPE_COMPARE, l, r)
 		}
-		defined[s.Ident] = true
-	case *PrintStatement:
-		if err := checkExprDefined(s.Expr, defined); err != nil {
-			return err
+		if l == TYPE_BOOL && e.Op != "==" && e.Op != "!=" {
+			return TYPE_BOOL, newSemanticError(e.Pos, msg.SEM_TYPE_ORDER, l, e.Op)
 		}
-	case *InputStatement:
-		defined[s.Ident] = true
+		return TYPE_BOOL, nil
+	case *UnaryExpr:
+		return c.checkNumeric(e.Operand, e.Op)
 	}
-	return nil
+	return TYPE_INT, nil
 }
@@ -0,0 +1,42 @@
+package parser
+
+// Type 是变量和表达式的静态类型。零值是 int，没有声明类型的变量按 int 处理
+type Type int
+
+const (
+	TYPE_INT  Type = iota // 16 位有符号整数
+	TYPE_BOOL             // true 或 false，运行时为 0 或 1
+	TYPE_CHAR             // 一个 ASCII 字符，占一个字节
+	TYPE_LONG             // 32 位有符号整数
+)
+
+var typeNames = map[Type]string{
+	TYPE_INT:  "int",
+	TYPE_BOOL: "bool",
+	TYPE_CHAR: "char",
+	TYPE_LONG: "long",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+// numeric 判断 t 是否是可以参与算术运算的整数类型
+func (t Type) numeric() bool {
+	return t == TYPE_INT || t == TYPE_LONG
+}
+
+// assignable 判断 t 类型的值能否赋给 to 类型的变量：类型相同，或者把 int 扩展为 long
+func (t Type) assignable(to Type) bool {
+	return t == to || t == TYPE_INT && to == TYPE_LONG
+}
+
+// LookupType 返回类型名对应的类型
+func LookupType(name string) (Type, bool) {
+	for t, n := range typeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return TYPE_INT, false
+}
@@ -0,0 +1,323 @@
+package repl
+
+import (
+	"bufio"
+	"compiler/bytecode"
+	"compiler/codegen"
+	"compiler/lexer"
+	"compiler/msg"
+	"compiler/parser"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// REPL 逐条读取语句并在字节码虚拟机中执行，变量和常量在多次输入之间保持
+type REPL struct {
+	in          *bufio.Reader
+	out         io.Writer
+	vars        map[string]int16
+	consts      map[string]int16
+	types       map[string]parser.Type // 变量和常量的类型
+	history     []string
+	historyFile string
+	last        string // 上一次执行的代码
+}
+
+// New 创建 REPL，程序中的 input 语句与 REPL 共用输入 in
+func New(in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		in:     bufio.NewReader(in),
+		out:    out,
+		vars:   make(map[string]int16),
+		consts: make(map[string]int16),
+		types:  make(map[string]parser.Type),
+	}
+}
+
+// SetHistoryFile 载入历史文件，之后的每条输入都会追加到该文件。
+// 文件中每行是一条用 strconv.Quote 转义的输入；不是带引号的字符串的行是旧格式，只把 \n 换成换行
+func (r *REPL) SetHistoryFile(path string) {
+	r.historyFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		entry, err := strconv.Unquote(line)
+		if err != nil {
+			entry = strings.ReplaceAll(line, `\n`, "\n")
+		}
+		r.history = append(r.history, entry)
+	}
+}
+
+// Run 运行读取-执行-输出循环，直到输入结束或 :quit
+func (r *REPL) Run() error {
+	fmt.Fprintln(r.out, msg.Get(msg.REPL_BANNER))
+	for {
+		chunk, ok := r.readChunk()
+		if !ok {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+		r.addHistory(chunk)
+		if strings.HasPrefix(chunk, ":") {
+			if !r.meta(chunk) {
+				return nil
+			}
+			continue
+		}
+		r.eval(chunk)
+	}
+}
+
+// readChunk 读取一条完整的输入，花括号未配对或 do 循环体之后还没有 while 时继续读取下一行
+func (r *REPL) readChunk() (string, bool) {
+	var sb strings.Builder
+	prompt := ">>> "
+	for {
+		fmt.Fprint(r.out, prompt)
+		line, err := r.in.ReadString('\n')
+		if err != nil && line == "" {
+			return sb.String(), sb.Len() > 0
+		}
+		sb.WriteString(line)
+		if strings.HasPrefix(strings.TrimSpace(sb.String()), ":") || !incomplete(sb.String()) {
+			return sb.String(), true
+		}
+		prompt = "... "
+	}
+}
+
+// incomplete 判断代码是否还没写完：有未闭合的左花括号（忽略注释中的括号），
+// 或者以 do 循环体的右花括号结尾
+func incomplete(code string) bool {
+	var isDo []bool // 每个未闭合的左花括号是否是 do 循环体的开始
+	afterDo := false
+	var prev lexer.Token
+	l := lexer.NewLexer(code)
+	for tok := l.NextToken(); tok.Type != lexer.TOKEN_EOF; tok = l.NextToken() {
+		afterDo = false
+		switch tok.Type {
+		case lexer.TOKEN_LBRACE:
+			isDo = append(isDo, prev.Type == lexer.TOKEN_KEYWORD && prev.Literal == "do")
+		case lexer.TOKEN_RBRACE:
+			if len(isDo) > 0 {
+				afterDo = isDo[len(isDo)-1]
+				isDo = isDo[:len(isDo)-1]
+			}
+		}
+		prev = tok
+	}
+	return len(isDo) > 0 || afterDo
+}
+
+func (r *REPL) addHistory(chunk string) {
+	r.history = append(r.history, chunk)
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strconv.Quote(chunk))
+}
+
+// eval 编译并执行一段代码，执行后保存变量的值
+func (r *REPL) eval(code string) {
+	ast, err := r.parse(code)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	r.last = code
+
+	c := bytecode.NewCompiler()
+	for name := range r.vars {
+		c.DefineVar(name, r.types[name])
+	}
+	for name, v := range r.consts {
+		c.DefineConst(name, r.types[name], v)
+	}
+	prog, err := c.Compile(ast)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+	// 常量和变量的类型在编译期就已确定，运行出错也保留
+	for _, stmt := range ast.Statements {
+		if d, ok := stmt.(*parser.ConstDeclaration); ok {
+			r.consts[d.Name], _ = parser.EvalConst(d.Value, r.consts)
+		}
+	}
+	for name, t := range c.Types() {
+		r.types[name] = t
+	}
+	vm := bytecode.NewVM(prog, r.in, r.out)
+	for name, v := range r.vars {
+		vm.SetGlobal(name, v)
+	}
+	err = vm.Run()
+	// 出错时也保留出错之前对变量的修改
+	for name, v := range vm.Globals() {
+		r.vars[name] = v
+	}
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+	}
+}
+
+// parse 把代码解析为语句；不是合法语句但是合法表达式时，解析为输出该表达式的 print 语句
+func (r *REPL) parse(code string) (*parser.AST, error) {
+	ast, stmtErr := r.parseWith(code, func(p *parser.Parser) (*parser.AST, error) {
+		return p.Parse()
+	})
+	if stmtErr == nil {
+		return ast, nil
+	}
+	ast, exprErr := r.parseWith(code, func(p *parser.Parser) (*parser.AST, error) {
+		expr, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &parser.AST{Statements: []parser.Statement{
+			&parser.PrintStatement{Pos: expr.Position(), Expr: expr},
+		}}, nil
+	})
+	if exprErr == nil {
+		return ast, nil
+	}
+	if looksLikeStatement(code) {
+		return nil, stmtErr
+	}
+	return nil, exprErr
+}
+
+func (r *REPL) parseWith(code string, parse func(p *parser.Parser) (*parser.AST, error)) (*parser.AST, error) {
+	l := lexer.NewLexer(code)
+	p := parser.NewParser(l)
+	for name := range r.vars {
+		p.DefineVar(name, r.types[name])
+	}
+	for name, v := range r.consts {
+		p.DefineConst(name, r.types[name], v)
+	}
+	ast, err := parse(p)
+	if l.HasErrors() {
+		return nil, l.GetErrors()[0]
+	}
+	return ast, err
+}
+
+// looksLikeStatement 根据开头的词法单元判断输入是想写语句还是表达式，用于选择报告哪个错误
+func looksLikeStatement(code string) bool {
+	l := lexer.NewLexer(code)
+	first := l.NextToken()
+	switch first.Type {
+	case lexer.TOKEN_KEYWORD:
+		return first.Literal != "true" && first.Literal != "false"
+	case lexer.TOKEN_INCREMENT, lexer.TOKEN_DECREMENT:
+		return true
+	case lexer.TOKEN_IDENT:
+		switch l.NextToken().Type {
+		case lexer.TOKEN_ASSIGN, lexer.TOKEN_PLUS_ASSIGN, lexer.TOKEN_MINUS_ASSIGN, lexer.TOKEN_MULTIPLY_ASSIGN,
+			lexer.TOKEN_DIVIDE_ASSIGN, lexer.TOKEN_MODULO_ASSIGN, lexer.TOKEN_INCREMENT, lexer.TOKEN_DECREMENT:
+			return true
+		}
+	}
+	return false
+}
+
+// value 按变量或常量 name 的类型写出它的值 v
+func (r *REPL) value(name string, v int16) string {
+	switch r.types[name] {
+	case parser.TYPE_BOOL:
+		return fmt.Sprint(v != 0)
+	case parser.TYPE_CHAR:
+		return lexer.QuoteChar(byte(v))
+	}
+	return fmt.Sprint(v)
+}
+
+// constNames 返回按名字排序的全部常量名
+func (r *REPL) constNames() []string {
+	names := make([]string, 0, len(r.consts))
+	for name := range r.consts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// meta 执行元命令，返回 false 表示退出
+func (r *REPL) meta(line string) bool {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		arg = r.last
+	}
+
+	switch cmd {
+	case ":quit", ":q", ":exit":
+		return false
+	case ":help":
+		fmt.Fprint(r.out, msg.Get(msg.REPL_HELP))
+	case ":vars":
+		names := make([]string, 0, len(r.vars))
+		for name := range r.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s %s = %s\n", r.types[name], name, r.value(name, r.vars[name]))
+		}
+		for _, name := range r.constNames() {
+			fmt.Fprintf(r.out, "const %s = %s\n", name, r.value(name, r.consts[name]))
+		}
+	case ":history":
+		for i, entry := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, strings.ReplaceAll(entry, "\n", "\n      "))
+		}
+	case ":tokens":
+		l := lexer.NewLexer(arg)
+		for tok := l.NextToken(); tok.Type != lexer.TOKEN_EOF; tok = l.NextToken() {
+			fmt.Fprintf(r.out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		}
+	case ":ast", ":asm":
+		ast, err := r.parse(arg)
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			break
+		}
+		if cmd == ":ast" {
+			fmt.Fprint(r.out, parser.Dump(ast))
+			break
+		}
+		cg := codegen.NewCodeGenerator()
+		for name := range r.vars {
+			cg.DefineVar(name, r.types[name])
+		}
+		for _, name := range r.constNames() {
+			cg.DefineConst(name, r.types[name], r.consts[name])
+		}
+		for _, line := range cg.Generate(ast) {
+			fmt.Fprintln(r.out, line)
+		}
+	default:
+		fmt.Fprintln(r.out, msg.Get(msg.REPL_UNKNOWN_COMMAND, cmd))
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAsmUsesSessionTables 检查 :asm 使用之前输入的变量类型和常量
+func TestAsmUsesSessionTables(t *testing.T) {
+	in := strings.NewReader("char c = 'A';\nconst N = 5;\nint k = 2;\n:asm print c; print N + k;\n")
+	var out bytes.Buffer
+	if err := New(in, &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{"N equ 5", "c db 0", "k dw 0", "mov al, c"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("输出中没有 %q：\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "N dw 0") {
+		t.Errorf("常量 N 被定义为变量：\n%s", got)
+	}
+}
+
+// TestIncomplete 检查花括号未配对和 do 循环体后还没有 while 时继续读取
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"print 1;", false},
+		{"while (x < 3) {", true},
+		{"while (x < 3) {\n    if (x) {\n    }", true},
+		{"while (x < 3) {\n}", false},
+		{"// {\nprint 1;", false},
+		{"if (x) { // }", true},
+		{"do {\n    x++;\n}", true},
+		{"do {\n    x++;\n} while (x < 3);", false},
+		{"do {\n    if (x) {\n    }", true},
+		{"do {\n    while (x) {\n    }\n    x++;\n}", true},
+		{"}", false},
+	}
+	for _, tt := range tests {
+		if got := incomplete(tt.code); got != tt.want {
+			t.Errorf("incomplete(%q) = %v，期望 %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+// session 运行一次 REPL，返回去掉欢迎信息和提示符之后的输出
+func session(t *testing.T, r *REPL) string {
+	t.Helper()
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.ReplaceAll(r.out.(*bytes.Buffer).String(), ">>> ", "")
+	got = strings.ReplaceAll(got, "... ", "")
+	_, got, _ = strings.Cut(got, "\n")
+	return got
+}
+
+// TestEval 检查变量在多次输入之间保持、表达式输出它的值、多行输入和 :vars
+func TestEval(t *testing.T) {
+	in := strings.NewReader("int x = 2;\nx = x * 5;\nx + 1\n'A'\nx > 3\n" +
+		"do {\n    x -= 4;\n}\nwhile (x > 0);\nconst N = 7;\nchar c = 'z';\nbool b = x < 0;\n:vars\n")
+	got := session(t, New(in, &bytes.Buffer{}))
+	want := "11\nA\n1\nbool b = true\nchar c = 'z'\nint x = -2\nconst N = 7\n\n"
+	if got != want {
+		t.Errorf("输出 %q，期望 %q", got, want)
+	}
+}
+
+// TestHistoryFile 检查写入历史文件的输入能原样读回，包括含换行和反斜杠的输入，以及旧格式的历史文件
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	entries := []string{`char c = '\n';`, "char b = '\\\\';", "while (x < 3) {\n    x++;\n}", `print "\n";`}
+	r := New(strings.NewReader(strings.Join(entries, "\n")+"\n"), &bytes.Buffer{})
+	r.SetHistoryFile(path)
+	session(t, r)
+
+	r = New(strings.NewReader(""), &bytes.Buffer{})
+	r.SetHistoryFile(path)
+	if !reflect.DeepEqual(r.history, entries) {
+		t.Errorf("读回的历史 %q，期望 %q", r.history, entries)
+	}
+
+	if err := os.WriteFile(path, []byte(`while (x < 3) {\n    x++;\n}`+"\nprint 1;\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r = New(strings.NewReader(""), &bytes.Buffer{})
+	r.SetHistoryFile(path)
+	want := []string{"while (x < 3) {\n    x++;\n}", "print 1;"}
+	if !reflect.DeepEqual(r.history, want) {
+		t.Errorf("旧格式读回的历史 %q，期望 %q", r.history, want)
+	}
+}
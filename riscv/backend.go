@@ -0,0 +1,24 @@
+package riscv
+
+import (
+	"compiler/backend"
+	"compiler/parser"
+	"strings"
+)
+
+// riscvBackend 输出 RV32IM 的 GNU 汇编（.s）
+type riscvBackend struct{}
+
+func init() {
+	backend.Register(riscvBackend{})
+}
+
+func (riscvBackend) Name() string              { return "riscv32" }
+func (riscvBackend) Extension() string         { return ".s" }
+func (riscvBackend) Features() backend.Feature { return backend.FEATURE_ALL }
+
+func (riscvBackend) Generate(ast *parser.AST) ([]byte, error) {
+	cg := NewCodeGenerator()
+	lines := cg.Generate(ast)
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
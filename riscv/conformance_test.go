@@ -0,0 +1,85 @@
+package riscv
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConformance 在 machine 中执行 code/conformance 中的程序生成的 RISC-V 汇编，输出与 .out 比较。
+// 字节码虚拟机不支持 long，long.src 只在这里执行。RARS 的 ReadInt 系统调用自己拒绝不是整数的输入并终止程序，
+// 所以有 .in 文件的程序不在这里执行，只在字节码虚拟机上检查（见 compiler 包的 TestConformance）
+func TestConformance(t *testing.T) {
+	sources, err := filepath.Glob("../code/conformance/*.src")
+	if err != nil || len(sources) == 0 {
+		t.Fatalf("找不到一致性测试程序：%v", err)
+	}
+	for _, path := range sources {
+		base := strings.TrimSuffix(path, ".src")
+		if _, err := os.Stat(base + ".in"); err == nil {
+			continue
+		}
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(base + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := parser.NewParser(lexer.NewLexer(string(source))).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := riscvBackend{}.Generate(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := newMachine(string(data), strings.NewReader(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.run()
+			if err != nil {
+				t.Fatalf("运行出错：%v\n输出：\n%s", err, got)
+			}
+			if got != string(want) {
+				t.Errorf("输出\n%s\n期望\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestMachine 检查 machine 本身：除数为 0 时转到 div_by_zero，read_int 对超出 16 位的数提示重新输入
+func TestMachine(t *testing.T) {
+	tests := []struct {
+		source string
+		input  string
+		want   string
+	}{
+		{"int a = 7; int b = 0; print a / b; print 1;", "", "Error: Division by zero!\n"},
+		{"int x; input x; print x * 2;", "40000\n-3\n", "Invalid number, try again: -6\n"},
+		{"char c = 'B'; print c;", "", "B\n"},
+	}
+	for _, tt := range tests {
+		ast, err := parser.NewParser(lexer.NewLexer(tt.source)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := riscvBackend{}.Generate(ast)
+		m, err := newMachine(string(data), strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.run()
+		if err != nil {
+			t.Errorf("%s：运行出错：%v", tt.source, err)
+		} else if got != tt.want {
+			t.Errorf("%s：输出 %q，期望 %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package riscv
+
+import (
+	"bytes"
+	"compiler/lexer"
+	"compiler/parser"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "用当前输出重写 testdata 中的 golden 文件")
+
+// TestGolden 把 code/ 下的全部示例生成 RISC-V 汇编，与 testdata/riscv32.golden 比较。
+// 修改代码生成后用 go test ./riscv -update 重新生成 golden 文件并检查差异。
+func TestGolden(t *testing.T) {
+	samples, err := filepath.Glob("../code/*.src")
+	if err != nil || len(samples) == 0 {
+		t.Fatalf("找不到示例程序：%v", err)
+	}
+	var got bytes.Buffer
+	for _, sample := range samples {
+		source, err := os.ReadFile(sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := parser.NewParser(lexer.NewLexer(string(source))).Parse()
+		if err != nil {
+			t.Fatalf("%s：%v", sample, err)
+		}
+		data, err := riscvBackend{}.Generate(ast)
+		if err != nil {
+			t.Fatalf("%s：%v", sample, err)
+		}
+		fmt.Fprintf(&got, "# ==== %s ====\n", filepath.Base(sample))
+		got.Write(data)
+	}
+
+	golden := filepath.Join("testdata", "riscv32.golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v（用 -update 生成 golden 文件）", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("输出与 %s 不同，检查改动后用 -update 更新", golden)
+	}
+}
+
+// TestDivisorCheckInRange 检查除数为 0 的检查不用条件分支直接跳转到程序末尾的 div_by_zero
+func TestDivisorCheckInRange(t *testing.T) {
+	ast, err := parser.NewParser(lexer.NewLexer("int a = 7; int b = 2; print a / b; print a % b;")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range NewCodeGenerator().Generate(ast) {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "b") && strings.HasSuffix(line, "div_by_zero") {
+			t.Errorf("条件分支只能跳转 ±4 KiB：%s", line)
+		}
+	}
+}
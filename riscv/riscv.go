@@ -0,0 +1,498 @@
+package riscv
+
+import (
+	"compiler/parser"
+	"fmt"
+	"sort"
+)
+
+// CodeGenerator 将 AST 翻译为 RV32IM 的 GNU 汇编。
+//
+// 表达式的值放在 a0 中，二元运算的左操作数临时压栈；
+// 输入输出使用 RARS/SPIM 风格的 ecall（a7 为调用号）：
+// 1 输出整数，4 输出字符串，5 读入整数，10 退出，11 输出字符。
+// 寄存器和变量都是 32 位，long 与 int 使用相同的指令；
+// int 的加减乘除和左移的结果再用 slli/srai 截成 16 位有符号数，与 8086 和字节码一致。
+type CodeGenerator struct {
+	code       []string
+	varMap     map[string]string
+	consts     map[string]int16       // 常量的值，使用常量的地方直接生成立即数
+	types      map[string]parser.Type // 变量和常量的类型，char 变量只占一个字节
+	labelCount int
+	loops      []loopLabels // 正在生成的循环和 switch，最内层在最后
+}
+
+// loopLabels 是 break 和 continue 跳转的目标
+type loopLabels struct {
+	breakLabel    string
+	continueLabel string
+}
+
+func NewCodeGenerator() *CodeGenerator {
+	return &CodeGenerator{
+		code:   make([]string, 0),
+		varMap: make(map[string]string),
+		consts: make(map[string]int16),
+	}
+}
+
+func (cg *CodeGenerator) Generate(ast *parser.AST) []string {
+	cg.types = parser.VarTypes(ast)
+	cg.collectVars(ast.Statements)
+
+	cg.code = append(cg.code,
+		"    .data",
+		"msg_div_by_zero:",
+		"    .asciz \"Error: Division by zero!\\n\"",
+		"msg_invalid_number:",
+		"    .asciz \"Invalid number, try again: \"",
+		"    .align 2",
+	)
+	names := make([]string, 0, len(cg.varMap))
+	for name := range cg.varMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 字对齐的 int 变量在前，char 变量放在最后，不影响其他变量的对齐
+	for _, name := range names {
+		if cg.types[name] != parser.TYPE_CHAR {
+			cg.code = append(cg.code, fmt.Sprintf("%s:", cg.varMap[name]), "    .word 0")
+		}
+	}
+	for _, name := range names {
+		if cg.types[name] == parser.TYPE_CHAR {
+			cg.code = append(cg.code, fmt.Sprintf("%s:", cg.varMap[name]), "    .byte 0")
+		}
+	}
+
+	cg.code = append(cg.code,
+		"",
+		"    .text",
+		"    .globl main",
+		"main:",
+		"    addi sp, sp, -16",
+		"    sw ra, 12(sp)",
+	)
+
+	for _, stmt := range ast.Statements {
+		cg.genStatement(stmt)
+	}
+
+	cg.code = append(cg.code,
+		"    lw ra, 12(sp)",
+		"    addi sp, sp, 16",
+		"    li a7, 10", // exit
+		"    ecall",
+		"",
+	)
+	cg.addHelperFunctions()
+	return cg.code
+}
+
+func (cg *CodeGenerator) collectVars(stmts []parser.Statement) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *parser.ConstDeclaration:
+			v, _ := parser.EvalConst(s.Value, cg.consts)
+			cg.consts[s.Name] = v
+		case *parser.VarDeclaration:
+			cg.varMap[s.Name] = "v_" + s.Name
+			if s.Value != nil {
+				cg.collectVarsFromExpr(s.Value)
+			}
+		case *parser.Assignment:
+			cg.varMap[s.Ident] = "v_" + s.Ident
+			cg.collectVarsFromExpr(s.Value)
+		case *parser.CompoundAssignment:
+			cg.varMap[s.Ident] = "v_" + s.Ident
+			cg.collectVarsFromExpr(s.Value)
+		case *parser.IncDecStatement:
+			cg.varMap[s.Ident] = "v_" + s.Ident
+		case *parser.PrintStatement:
+			cg.collectVarsFromExpr(s.Expr)
+		case *parser.InputStatement:
+			cg.varMap[s.Ident] = "v_" + s.Ident
+		case *parser.IfStatement:
+			cg.collectVarsFromExpr(s.Condition)
+			cg.collectVars(s.Then)
+			cg.collectVars(s.Else)
+		case *parser.WhileStatement:
+			cg.collectVarsFromExpr(s.Condition)
+			cg.collectVars(s.Body)
+		case *parser.DoWhileStatement:
+			cg.collectVars(s.Body)
+			cg.collectVarsFromExpr(s.Condition)
+		case *parser.SwitchStatement:
+			cg.collectVarsFromExpr(s.Value)
+			for _, c := range s.Cases {
+				cg.collectVars(c.Body)
+			}
+		case *parser.ForStatement:
+			if s.Init != nil {
+				cg.collectVars([]parser.Statement{s.Init})
+			}
+			if s.Condition != nil {
+				cg.collectVarsFromExpr(s.Condition)
+			}
+			if s.Step != nil {
+				cg.collectVars([]parser.Statement{s.Step})
+			}
+			cg.collectVars(s.Body)
+		}
+	}
+}
+
+func (cg *CodeGenerator) collectVarsFromExpr(expr parser.Expr) {
+	switch e := expr.(type) {
+	case *parser.IdentExpr:
+		if _, ok := cg.consts[e.Name]; !ok {
+			cg.varMap[e.Name] = "v_" + e.Name
+		}
+	case *parser.BinaryExpr:
+		cg.collectVarsFromExpr(e.Left)
+		cg.collectVarsFromExpr(e.Right)
+	case *parser.ComparisonExpr:
+		cg.collectVarsFromExpr(e.Left)
+		cg.collectVarsFromExpr(e.Right)
+	case *parser.UnaryExpr:
+		cg.collectVarsFromExpr(e.Operand)
+	}
+}
+
+func (cg *CodeGenerator) genStatement(stmt parser.Statement) {
+	switch s := stmt.(type) {
+	case *parser.ConstDeclaration:
+		// 常量不占用存储，使用处直接生成立即数
+	case *parser.VarDeclaration:
+		cg.genStatement(s.Desugar())
+	case *parser.Assignment:
+		cg.genExpr(s.Value)
+		store := "sw"
+		if cg.types[s.Ident] == parser.TYPE_CHAR {
+			store = "sb"
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    la t1, %s", cg.varMap[s.Ident]),
+			fmt.Sprintf("    %s a0, 0(t1)", store),
+		)
+	case *parser.CompoundAssignment:
+		cg.genStatement(s.Desugar())
+	case *parser.IncDecStatement:
+		delta := 1
+		if s.Op == "--" {
+			delta = -1
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    la t1, %s", cg.varMap[s.Ident]),
+			"    lw a0, 0(t1)",
+			fmt.Sprintf("    addi a0, a0, %d", delta),
+		)
+		cg.wrap(cg.types[s.Ident])
+		cg.code = append(cg.code, "    sw a0, 0(t1)")
+	case *parser.PrintStatement:
+		cg.genExpr(s.Expr)
+		if parser.TypeOf(s.Expr, cg.types) == parser.TYPE_CHAR {
+			cg.code = append(cg.code, "    call print_char")
+		} else {
+			cg.code = append(cg.code, "    call print_number")
+		}
+	case *parser.InputStatement:
+		read := "read_int"
+		if cg.types[s.Ident] == parser.TYPE_LONG {
+			read = "read_number"
+		}
+		cg.code = append(cg.code,
+			"    call "+read,
+			fmt.Sprintf("    la t1, %s", cg.varMap[s.Ident]),
+			"    sw a0, 0(t1)",
+		)
+	case *parser.IfStatement:
+		elseLabel := cg.newLabel()
+		endLabel := cg.newLabel()
+		cg.genExpr(s.Condition)
+		cg.code = append(cg.code, fmt.Sprintf("    beqz a0, %s", elseLabel))
+		for _, stmt := range s.Then {
+			cg.genStatement(stmt)
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    j %s", endLabel),
+			fmt.Sprintf("%s:", elseLabel),
+		)
+		for _, stmt := range s.Else {
+			cg.genStatement(stmt)
+		}
+		cg.code = append(cg.code, fmt.Sprintf("%s:", endLabel))
+	case *parser.WhileStatement:
+		startLabel := cg.newLabel()
+		endLabel := cg.newLabel()
+		cg.code = append(cg.code, fmt.Sprintf("%s:", startLabel))
+		cg.genExpr(s.Condition)
+		cg.code = append(cg.code, fmt.Sprintf("    beqz a0, %s", endLabel))
+		cg.genLoopBody(s.Body, loopLabels{breakLabel: endLabel, continueLabel: startLabel})
+		cg.code = append(cg.code,
+			fmt.Sprintf("    j %s", startLabel),
+			fmt.Sprintf("%s:", endLabel),
+		)
+	case *parser.DoWhileStatement:
+		startLabel := cg.newLabel()
+		condLabel := cg.newLabel()
+		endLabel := cg.newLabel()
+		cg.code = append(cg.code, fmt.Sprintf("%s:", startLabel))
+		cg.genLoopBody(s.Body, loopLabels{breakLabel: endLabel, continueLabel: condLabel})
+		cg.code = append(cg.code, fmt.Sprintf("%s:", condLabel))
+		cg.genExpr(s.Condition)
+		cg.code = append(cg.code,
+			fmt.Sprintf("    bnez a0, %s", startLabel),
+			fmt.Sprintf("%s:", endLabel),
+		)
+	case *parser.ForStatement:
+		if s.Init != nil {
+			cg.genStatement(s.Init)
+		}
+		startLabel := cg.newLabel()
+		stepLabel := cg.newLabel()
+		endLabel := cg.newLabel()
+		cg.code = append(cg.code, fmt.Sprintf("%s:", startLabel))
+		if s.Condition != nil {
+			cg.genExpr(s.Condition)
+			cg.code = append(cg.code, fmt.Sprintf("    beqz a0, %s", endLabel))
+		}
+		cg.genLoopBody(s.Body, loopLabels{breakLabel: endLabel, continueLabel: stepLabel})
+		cg.code = append(cg.code, fmt.Sprintf("%s:", stepLabel))
+		if s.Step != nil {
+			cg.genStatement(s.Step)
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    j %s", startLabel),
+			fmt.Sprintf("%s:", endLabel),
+		)
+	case *parser.SwitchStatement:
+		cg.genSwitch(s)
+	case *parser.BreakStatement:
+		cg.code = append(cg.code, fmt.Sprintf("    j %s", cg.loops[len(cg.loops)-1].breakLabel))
+	case *parser.ContinueStatement:
+		cg.code = append(cg.code, fmt.Sprintf("    j %s", cg.loops[len(cg.loops)-1].continueLabel))
+	}
+}
+
+// genSwitch 用比较链生成 switch，各分支执行完后跳到 switch 之后
+func (cg *CodeGenerator) genSwitch(s *parser.SwitchStatement) {
+	labels := make([]string, len(s.Cases))
+	for i := range s.Cases {
+		labels[i] = cg.newLabel()
+	}
+	endLabel := cg.newLabel()
+	defaultLabel := endLabel
+	cg.genExpr(s.Value)
+	for i, c := range s.Cases {
+		if len(c.Values) == 0 {
+			defaultLabel = labels[i]
+		}
+		for _, v := range c.Values {
+			n, _ := parser.EvalConst(v, cg.consts)
+			cg.code = append(cg.code,
+				fmt.Sprintf("    li t0, %d", n),
+				fmt.Sprintf("    beq a0, t0, %s", labels[i]),
+			)
+		}
+	}
+	cg.code = append(cg.code, fmt.Sprintf("    j %s", defaultLabel))
+
+	// break 跳出 switch，continue 仍然作用于外层循环
+	body := loopLabels{breakLabel: endLabel}
+	if len(cg.loops) > 0 {
+		body.continueLabel = cg.loops[len(cg.loops)-1].continueLabel
+	}
+	for i, c := range s.Cases {
+		cg.code = append(cg.code, fmt.Sprintf("%s:", labels[i]))
+		cg.genLoopBody(c.Body, body)
+		if i < len(s.Cases)-1 {
+			cg.code = append(cg.code, fmt.Sprintf("    j %s", endLabel))
+		}
+	}
+	cg.code = append(cg.code, fmt.Sprintf("%s:", endLabel))
+}
+
+// genLoopBody 生成循环体或 switch 的分支，其中的 break 和 continue 跳到 labels
+func (cg *CodeGenerator) genLoopBody(body []parser.Statement, labels loopLabels) {
+	cg.loops = append(cg.loops, labels)
+	for _, stmt := range body {
+		cg.genStatement(stmt)
+	}
+	cg.loops = cg.loops[:len(cg.loops)-1]
+}
+
+// genExpr 计算表达式，结果放在 a0
+func (cg *CodeGenerator) genExpr(expr parser.Expr) {
+	switch e := expr.(type) {
+	case *parser.NumberExpr:
+		cg.code = append(cg.code, fmt.Sprintf("    li a0, %s", e.Value))
+	case *parser.BooleanExpr:
+		if e.Value {
+			cg.code = append(cg.code, "    li a0, 1")
+		} else {
+			cg.code = append(cg.code, "    li a0, 0")
+		}
+	case *parser.CharExpr:
+		cg.code = append(cg.code, fmt.Sprintf("    li a0, %d", e.Value))
+	case *parser.IdentExpr:
+		if v, ok := cg.consts[e.Name]; ok {
+			cg.code = append(cg.code, fmt.Sprintf("    li a0, %d", v))
+			break
+		}
+		load := "lw"
+		if cg.types[e.Name] == parser.TYPE_CHAR {
+			load = "lbu"
+		}
+		cg.code = append(cg.code,
+			fmt.Sprintf("    la t1, %s", cg.varMap[e.Name]),
+			fmt.Sprintf("    %s a0, 0(t1)", load),
+		)
+	case *parser.BinaryExpr:
+		cg.genOperands(e.Left, e.Right)
+		switch e.Op {
+		case "+":
+			cg.code = append(cg.code, "    add a0, t0, a0")
+			cg.wrap(parser.TypeOf(e, cg.types))
+		case "-":
+			cg.code = append(cg.code, "    sub a0, t0, a0")
+			cg.wrap(parser.TypeOf(e, cg.types))
+		case "*":
+			cg.code = append(cg.code, "    mul a0, t0, a0")
+			cg.wrap(parser.TypeOf(e, cg.types))
+		case "/":
+			// -32768 / -1 的商 32768 回绕为 -32768
+			cg.genDivisorCheck()
+			cg.code = append(cg.code, "    div a0, t0, a0")
+			cg.wrap(parser.TypeOf(e, cg.types))
+		case "%":
+			cg.genDivisorCheck()
+			cg.code = append(cg.code, "    rem a0, t0, a0")
+		case "&":
+			cg.code = append(cg.code, "    and a0, t0, a0")
+		case "|":
+			cg.code = append(cg.code, "    or a0, t0, a0")
+		case "^":
+			cg.code = append(cg.code, "    xor a0, t0, a0")
+		case "<<", ">>":
+			// 移位次数 int 只取低 4 位，long 只取低 5 位
+			mask := 15
+			if parser.TypeOf(e, cg.types) == parser.TYPE_LONG {
+				mask = 31
+			}
+			instr := "sll"
+			if e.Op == ">>" {
+				instr = "sra"
+			}
+			cg.code = append(cg.code, fmt.Sprintf("    andi a0, a0, %d", mask), fmt.Sprintf("    %s a0, t0, a0", instr))
+			cg.wrap(parser.TypeOf(e, cg.types))
+		}
+	case *parser.UnaryExpr:
+		cg.genExpr(e.Operand)
+		cg.code = append(cg.code, "    not a0, a0")
+	case *parser.ComparisonExpr:
+		cg.genOperands(e.Left, e.Right)
+		switch e.Op {
+		case "==":
+			cg.code = append(cg.code, "    sub a0, t0, a0", "    seqz a0, a0")
+		case "!=":
+			cg.code = append(cg.code, "    sub a0, t0, a0", "    snez a0, a0")
+		case "<":
+			cg.code = append(cg.code, "    slt a0, t0, a0")
+		case ">":
+			cg.code = append(cg.code, "    slt a0, a0, t0")
+		case "<=":
+			cg.code = append(cg.code, "    slt a0, a0, t0", "    xori a0, a0, 1")
+		case ">=":
+			cg.code = append(cg.code, "    slt a0, t0, a0", "    xori a0, a0, 1")
+		}
+	}
+}
+
+// wrap 在结果类型 t 为 int 时把 a0 截成 16 位有符号数
+func (cg *CodeGenerator) wrap(t parser.Type) {
+	if t == parser.TYPE_INT {
+		cg.code = append(cg.code, "    slli a0, a0, 16", "    srai a0, a0, 16")
+	}
+}
+
+// genOperands 计算两个操作数：左操作数放入 t0，右操作数放入 a0
+func (cg *CodeGenerator) genOperands(left, right parser.Expr) {
+	cg.genExpr(left)
+	cg.code = append(cg.code,
+		"    addi sp, sp, -4",
+		"    sw a0, 0(sp)",
+	)
+	cg.genExpr(right)
+	cg.code = append(cg.code,
+		"    lw t0, 0(sp)",
+		"    addi sp, sp, 4",
+	)
+}
+
+// genDivisorCheck 在除数 a0 为 0 时转到 div_by_zero。
+// 条件分支只能跳转 ±4 KiB，而 div_by_zero 在程序末尾，所以用条件分支跳过一条 j 指令
+func (cg *CodeGenerator) genDivisorCheck() {
+	skip := cg.newLabel()
+	cg.code = append(cg.code,
+		fmt.Sprintf("    bnez a0, %s", skip),
+		"    j div_by_zero",
+		skip+":",
+	)
+}
+
+func (cg *CodeGenerator) newLabel() string {
+	label := fmt.Sprintf(".L%d", cg.labelCount)
+	cg.labelCount++
+	return label
+}
+
+// addHelperFunctions 添加运行时辅助函数，均为叶子函数，只使用调用者保存的寄存器
+func (cg *CodeGenerator) addHelperFunctions() {
+	cg.code = append(cg.code,
+		"# print_number: 输出 a0 中的整数并换行",
+		"print_number:",
+		"    li a7, 1",
+		"    ecall",
+		"    li a0, 10",
+		"    li a7, 11",
+		"    ecall",
+		"    ret",
+		"",
+		"# print_char: 输出 a0 中的字符并换行",
+		"print_char:",
+		"    li a7, 11",
+		"    ecall",
+		"    li a0, 10",
+		"    ecall",
+		"    ret",
+		"",
+		"# read_number: 读入一个整数到 a0",
+		"read_number:",
+		"    li a7, 5",
+		"    ecall",
+		"    ret",
+		"",
+		"# read_int: 读入一个 int 到 a0，超出 16 位范围时提示重新输入",
+		"read_int:",
+		"    li a7, 5",
+		"    ecall",
+		"    slli t0, a0, 16",
+		"    srai t0, t0, 16",
+		"    bne t0, a0, read_int_retry",
+		"    ret",
+		"read_int_retry:",
+		"    la a0, msg_invalid_number",
+		"    li a7, 4",
+		"    ecall",
+		"    j read_int",
+		"",
+		"# div_by_zero: 输出错误信息并退出",
+		"div_by_zero:",
+		"    la a0, msg_div_by_zero",
+		"    li a7, 4",
+		"    ecall",
+		"    li a7, 10",
+		"    ecall",
+	)
+}
@@ -0,0 +1,323 @@
+package riscv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// machine 解释执行代码生成器输出的 RV32IM 汇编，用于测试。
+// 只支持生成的代码用到的指令、伪指令和 RARS 的系统调用：1 输出整数、4 输出字符串、
+// 5 读入整数、10 退出、11 输出字符
+type machine struct {
+	code   [][]string       // 每条指令的助记符和操作数
+	text   map[string]int   // 代码标号对应的指令下标
+	data   map[string]int32 // 数据标号对应的内存地址
+	mem    []byte
+	reg    [32]int32
+	in     *bufio.Reader
+	out    bytes.Buffer
+	steps  int // 最多执行的指令条数，防止死循环
+	exited bool
+}
+
+// 数据段的起始地址，sp 从内存末尾开始向下增长
+const (
+	simDataBase = 0x100
+	simMemSize  = 0x10000
+	simMaxSteps = 10000000
+)
+
+var simRegs = map[string]int{"zero": 0, "ra": 1, "sp": 2, "t0": 5, "t1": 6, "a0": 10, "a7": 17}
+
+// newMachine 汇编 source：数据段的内容写入内存，代码段的指令按顺序记录
+func newMachine(source string, in io.Reader) (*machine, error) {
+	m := &machine{
+		text:  make(map[string]int),
+		data:  make(map[string]int32),
+		mem:   make([]byte, simMemSize),
+		in:    bufio.NewReader(in),
+		steps: simMaxSteps,
+	}
+	m.reg[2] = simMemSize
+	addr := int32(simDataBase)
+	inData := false
+	for n, line := range strings.Split(source, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 && !strings.Contains(line, "\"") {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasSuffix(line, ":") {
+			label := strings.TrimSuffix(line, ":")
+			if inData {
+				m.data[label] = addr
+			} else {
+				m.text[label] = len(m.code)
+			}
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		var operands []string
+		if len(fields) == 2 {
+			for _, op := range strings.Split(fields[1], ",") {
+				operands = append(operands, strings.TrimSpace(op))
+			}
+		}
+		switch fields[0] {
+		case ".data":
+			inData = true
+		case ".text":
+			inData = false
+		case ".globl":
+		case ".align":
+			shift, err := strconv.Atoi(operands[0])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行：%v", n+1, err)
+			}
+			size := int32(1) << uint(shift)
+			addr = (addr + size - 1) &^ (size - 1)
+		case ".word":
+			addr += 4
+		case ".byte":
+			addr++
+		case ".asciz":
+			s, err := strconv.Unquote(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行：%v", n+1, err)
+			}
+			addr += int32(copy(m.mem[addr:], s)) + 1
+		default:
+			if inData {
+				return nil, fmt.Errorf("第 %d 行：数据段中不支持 %q", n+1, line)
+			}
+			m.code = append(m.code, append([]string{fields[0]}, operands...))
+		}
+	}
+	return m, nil
+}
+
+// run 从 main 开始执行到系统调用 10，返回程序的输出
+func (m *machine) run() (string, error) {
+	pc, ok := m.text["main"]
+	if !ok {
+		return "", fmt.Errorf("没有 main")
+	}
+	for !m.exited {
+		if pc < 0 || pc >= len(m.code) {
+			return m.out.String(), fmt.Errorf("pc %d 超出代码范围", pc)
+		}
+		if m.steps--; m.steps < 0 {
+			return m.out.String(), fmt.Errorf("执行的指令超过 %d 条", simMaxSteps)
+		}
+		next, err := m.step(pc)
+		if err != nil {
+			return m.out.String(), fmt.Errorf("%v：%s", err, strings.Join(m.code[pc], " "))
+		}
+		pc = next
+	}
+	return m.out.String(), nil
+}
+
+// step 执行第 pc 条指令，返回下一条指令的下标
+func (m *machine) step(pc int) (int, error) {
+	ins := m.code[pc]
+	args := ins[1:]
+	bad := ""
+	index := func(name string) int {
+		n, ok := simRegs[name]
+		if !ok {
+			bad = name
+		}
+		return n
+	}
+	r := func(i int) int32 { return m.reg[index(args[i])] }
+	set := func(v int32) {
+		if n := index(args[0]); n != 0 {
+			m.reg[n] = v
+		}
+	}
+	next, err := m.exec(pc, ins[0], args, r, set)
+	if err == nil && bad != "" {
+		err = fmt.Errorf("不支持的寄存器 %s", bad)
+	}
+	return next, err
+}
+
+// exec 执行一条指令，r(i) 读第 i 个操作数寄存器，set 写目的寄存器
+func (m *machine) exec(pc int, op string, args []string, r func(int) int32, set func(int32)) (int, error) {
+	switch op {
+	case "li":
+		v, err := strconv.ParseInt(args[1], 0, 64)
+		if err != nil {
+			return 0, err
+		}
+		set(int32(v))
+	case "la":
+		addr, ok := m.data[args[1]]
+		if !ok {
+			return 0, fmt.Errorf("未定义的数据标号 %s", args[1])
+		}
+		set(addr)
+	case "lw", "lbu", "sw", "sb":
+		addr, err := m.address(args[1])
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "lw":
+			set(int32(uint32(m.mem[addr]) | uint32(m.mem[addr+1])<<8 | uint32(m.mem[addr+2])<<16 | uint32(m.mem[addr+3])<<24))
+		case "lbu":
+			set(int32(m.mem[addr]))
+		case "sw":
+			v := r(0)
+			m.mem[addr], m.mem[addr+1], m.mem[addr+2], m.mem[addr+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		case "sb":
+			m.mem[addr] = byte(r(0))
+		}
+	case "addi", "andi", "xori", "slli", "srai":
+		imm, err := strconv.ParseInt(args[2], 0, 32)
+		if err != nil {
+			return 0, err
+		}
+		a, b := r(1), int32(imm)
+		switch op {
+		case "addi":
+			set(a + b)
+		case "andi":
+			set(a & b)
+		case "xori":
+			set(a ^ b)
+		case "slli":
+			set(a << uint(b&31))
+		case "srai":
+			set(a >> uint(b&31))
+		}
+	case "add", "sub", "and", "or", "xor", "mul", "div", "rem", "sll", "sra", "slt":
+		a, b := r(1), r(2)
+		switch op {
+		case "add":
+			set(a + b)
+		case "sub":
+			set(a - b)
+		case "and":
+			set(a & b)
+		case "or":
+			set(a | b)
+		case "xor":
+			set(a ^ b)
+		case "mul":
+			set(a * b)
+		case "div":
+			// 除数为 0 时商为 -1；Go 与 RISC-V 一样，最小值除以 -1 得到最小值
+			if b == 0 {
+				set(-1)
+			} else {
+				set(a / b)
+			}
+		case "rem":
+			if b == 0 {
+				set(a)
+			} else {
+				set(a % b)
+			}
+		case "sll":
+			set(a << uint(b&31))
+		case "sra":
+			set(a >> uint(b&31))
+		case "slt":
+			if a < b {
+				set(1)
+			} else {
+				set(0)
+			}
+		}
+	case "not":
+		set(^r(1))
+	case "seqz", "snez":
+		if (r(1) == 0) == (op == "seqz") {
+			set(1)
+		} else {
+			set(0)
+		}
+	case "beqz", "bnez", "beq", "bne":
+		a, b, target := r(0), int32(0), args[1]
+		if len(args) == 3 {
+			b, target = r(1), args[2]
+		}
+		if (a == b) == (op == "beqz" || op == "beq") {
+			return m.jump(target)
+		}
+	case "j":
+		return m.jump(args[0])
+	case "call":
+		m.reg[1] = int32(pc + 1)
+		return m.jump(args[0])
+	case "ret":
+		return int(m.reg[1]), nil
+	case "ecall":
+		return pc + 1, m.ecall()
+	default:
+		return 0, fmt.Errorf("不支持的指令")
+	}
+	return pc + 1, nil
+}
+
+// address 计算 "12(sp)" 形式的内存操作数的地址
+func (m *machine) address(operand string) (int32, error) {
+	open := strings.Index(operand, "(")
+	if open < 0 || !strings.HasSuffix(operand, ")") {
+		return 0, fmt.Errorf("不合法的内存操作数 %s", operand)
+	}
+	offset, err := strconv.Atoi(operand[:open])
+	if err != nil {
+		return 0, err
+	}
+	addr := m.reg[simRegs[operand[open+1:len(operand)-1]]] + int32(offset)
+	if addr < 0 || addr+4 > int32(len(m.mem)) {
+		return 0, fmt.Errorf("地址 %d 超出内存范围", addr)
+	}
+	return addr, nil
+}
+
+func (m *machine) jump(label string) (int, error) {
+	pc, ok := m.text[label]
+	if !ok {
+		return 0, fmt.Errorf("未定义的代码标号 %s", label)
+	}
+	return pc, nil
+}
+
+// ecall 执行 a7 指定的系统调用。与 RARS 一样，读入的一行不是 32 位整数时出错
+func (m *machine) ecall() error {
+	a0 := m.reg[10]
+	switch m.reg[17] {
+	case 1:
+		fmt.Fprint(&m.out, a0)
+	case 4:
+		end := bytes.IndexByte(m.mem[a0:], 0)
+		m.out.Write(m.mem[a0 : a0+int32(end)])
+	case 5:
+		line, err := m.in.ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(line), 10, 32)
+		if err != nil {
+			return err
+		}
+		m.reg[10] = int32(n)
+	case 10:
+		m.exited = true
+	case 11:
+		m.out.WriteByte(byte(a0))
+	default:
+		return fmt.Errorf("不支持的系统调用 %d", m.reg[17])
+	}
+	return nil
+}